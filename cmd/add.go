@@ -6,11 +6,18 @@ package cmd
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/ethanbao27/gotodo/internal/storage"
 	"github.com/spf13/cobra"
 )
 
+var addTags []string
+var addPriority string
+var addProject string
+var addDue string
+var addScheduled string
+
 // addCmd represents the add command
 var addCmd = &cobra.Command{
 	Use:   "add <task>",
@@ -18,7 +25,28 @@ var addCmd = &cobra.Command{
 	Args:  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		content := strings.Join(args, " ")
-		t, err := storage.Add(content)
+		due := ""
+		if addDue != "" {
+			var err error
+			if due, err = parseDateArg(addDue, time.Now()); err != nil {
+				return err
+			}
+		}
+		scheduled := ""
+		if addScheduled != "" {
+			var err error
+			if scheduled, err = parseDateArg(addScheduled, time.Now()); err != nil {
+				return err
+			}
+		}
+		t, err := storage.AddTask(storage.Task{
+			Content:   content,
+			Tags:      addTags,
+			Priority:  addPriority,
+			Project:   addProject,
+			Due:       due,
+			Scheduled: scheduled,
+		})
 		if err != nil {
 			return err
 		}
@@ -28,5 +56,10 @@ var addCmd = &cobra.Command{
 }
 
 func init() {
+	addCmd.Flags().StringSliceVarP(&addTags, "tag", "t", nil, "tag the task (repeatable or comma separated)")
+	addCmd.Flags().StringVarP(&addPriority, "priority", "p", "", "task priority: high, medium or low")
+	addCmd.Flags().StringVar(&addProject, "project", "", "project the task belongs to")
+	addCmd.Flags().StringVar(&addDue, "due", "", "due date: YYYY-MM-DD, today, tomorrow, a weekday or +Nd")
+	addCmd.Flags().StringVar(&addScheduled, "scheduled", "", "day to start on the task, same forms as --due")
 	rootCmd.AddCommand(addCmd)
 }
@@ -0,0 +1,131 @@
+/*
+Copyright © 2025 Ethan Bao 522425561@qq.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// a titled group of tasks in the agenda
+type agendaSection struct {
+	Title string
+	Tasks []storage.Task
+}
+
+// agendaCmd represents the agenda command
+var agendaCmd = &cobra.Command{
+	Use:   "agenda",
+	Short: "Show open tasks that are overdue, scheduled or due this week",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tasks, err := storage.List()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println()
+		color.New(color.FgBlue, color.Bold).Printf("  AGENDA  ")
+		color.New(color.FgWhite, color.Faint).Printf("  %s\n", time.Now().Format("Mon Jan 02"))
+
+		empty := true
+		for _, s := range agendaSections(tasks, time.Now()) {
+			if len(s.Tasks) == 0 {
+				continue
+			}
+			empty = false
+			fmt.Println()
+			style := color.New(color.FgCyan, color.Bold)
+			if s.Title == "Overdue" {
+				style = color.New(color.FgRed, color.Bold)
+			}
+			style.Printf("  %s (%d)\n", s.Title, len(s.Tasks))
+			for _, t := range s.Tasks {
+				printDatedTask(t)
+			}
+		}
+		if empty {
+			fmt.Println()
+			color.New(color.FgYellow).Println("Nothing scheduled or due this week.")
+		}
+		fmt.Println()
+		return nil
+	},
+}
+
+// split open tasks into overdue, today, tomorrow and the rest of the week
+// (through Sunday). Overdue goes by the due date, the rest by the earlier of
+// the scheduled and due dates; a scheduled day that has passed counts as today
+func agendaSections(tasks []storage.Task, now time.Time) []agendaSection {
+	today := startOfDay(now)
+	tomorrow := today.AddDate(0, 0, 1)
+	// first day of next week, weeks end on Sunday
+	weekEnd := today.AddDate(0, 0, (7-int(today.Weekday()))%7+1)
+
+	sections := []agendaSection{{Title: "Overdue"}, {Title: "Today"}, {Title: "Tomorrow"}, {Title: "This week"}}
+	for _, t := range tasks {
+		day, ok := agendaDate(t)
+		if !ok || t.Done {
+			continue
+		}
+		due, hasDue := t.DueDate()
+		switch {
+		case hasDue && due.Before(today):
+			sections[0].Tasks = append(sections[0].Tasks, t)
+		case !day.After(today):
+			sections[1].Tasks = append(sections[1].Tasks, t)
+		case day.Equal(tomorrow):
+			sections[2].Tasks = append(sections[2].Tasks, t)
+		case day.Before(weekEnd):
+			sections[3].Tasks = append(sections[3].Tasks, t)
+		}
+	}
+	for _, s := range sections {
+		sort.SliceStable(s.Tasks, func(i, j int) bool {
+			a, _ := agendaDate(s.Tasks[i])
+			b, _ := agendaDate(s.Tasks[j])
+			return a.Before(b)
+		})
+	}
+	return sections
+}
+
+// the earlier of a task's scheduled and due dates, ok is false when it has
+// neither
+func agendaDate(t storage.Task) (time.Time, bool) {
+	due, hasDue := t.DueDate()
+	scheduled, hasScheduled := t.ScheduledDate()
+	if hasScheduled && (!hasDue || scheduled.Before(due)) {
+		return scheduled, true
+	}
+	return due, hasDue
+}
+
+// one task line with its scheduled and due dates, used by agenda and calendar
+func printDatedTask(t storage.Task) {
+	statusIcon := "[ ]"
+	statusColor := color.FgWhite
+	if t.Done {
+		statusIcon = "[✓]"
+		statusColor = color.FgGreen
+	}
+	color.New(statusColor).Printf(" %s %3d ", statusIcon, t.ID)
+	color.New(color.FgWhite).Print(t.Content)
+	if scheduled, ok := t.ScheduledDate(); ok {
+		color.New(color.FgMagenta, color.Faint).Printf("  scheduled %s", scheduled.Format("Mon Jan 02"))
+	}
+	if due, ok := t.DueDate(); ok {
+		color.New(color.FgCyan, color.Faint).Printf("  due %s", due.Format("Mon Jan 02"))
+	}
+	fmt.Println()
+}
+
+func init() {
+	rootCmd.AddCommand(agendaCmd)
+}
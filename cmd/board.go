@@ -0,0 +1,140 @@
+/*
+Copyright © 2025 Ethan Bao 522425561@qq.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/ethanbao27/gotodo/internal/ui"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var boardBy string
+
+// boardCmd represents the board command
+var boardCmd = &cobra.Command{
+	Use:   "board",
+	Short: "Show tasks as a kanban board",
+	Long: `Show tasks in side-by-side columns grouped by status, tag, priority or project.
+
+Each column shows its task count. WIP limits are set per column with
+'gotodo config set-wip <column> <limit>' and flagged when exceeded.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tasks, err := storage.List()
+		if err != nil {
+			return err
+		}
+		columns, err := boardColumns(tasks, boardBy)
+		if err != nil {
+			return err
+		}
+		if len(tasks) == 0 {
+			color.New(color.FgYellow).Println("No tasks.")
+			return nil
+		}
+
+		config, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		limits := wipLimits(config)
+		for i := range columns {
+			columns[i].Limit = limits[strings.ToLower(columns[i].Title)]
+		}
+
+		fmt.Println()
+		color.New(color.FgBlue, color.Bold).Printf("  BOARD  ")
+		color.New(color.FgWhite, color.Faint).Printf("  by %s, %d tasks\n", boardBy, len(tasks))
+		fmt.Println()
+		ui.PrintBoard(columns, ui.TerminalWidth())
+		return nil
+	},
+}
+
+// group tasks into board columns
+func boardColumns(tasks []storage.Task, by string) ([]ui.BoardColumn, error) {
+	var keys func(t storage.Task) []string
+	var order []string
+	var fallback string
+
+	switch by {
+	case "status":
+		keys = func(t storage.Task) []string {
+			if t.Done {
+				return []string{"done"}
+			}
+			return []string{"todo"}
+		}
+		order = []string{"todo", "done"}
+	case "tag":
+		keys = func(t storage.Task) []string { return t.Tags }
+		fallback = "untagged"
+	case "priority":
+		keys = func(t storage.Task) []string {
+			if t.Priority == "" {
+				return nil
+			}
+			return []string{t.Priority}
+		}
+		order = storage.Priorities
+		fallback = "none"
+	case "project":
+		keys = func(t storage.Task) []string {
+			if t.Project == "" {
+				return nil
+			}
+			return []string{t.Project}
+		}
+		fallback = "no project"
+	default:
+		return nil, fmt.Errorf("invalid --by value %q (want status, tag, priority or project)", by)
+	}
+
+	cards := map[string][]ui.BoardCard{}
+	for _, t := range tasks {
+		card := ui.BoardCard{Text: fmt.Sprintf("#%d %s", t.ID, t.Content), Done: t.Done}
+		ks := keys(t)
+		if len(ks) == 0 {
+			ks = []string{fallback}
+		}
+		for _, k := range ks {
+			cards[k] = append(cards[k], card)
+		}
+	}
+
+	// fixed columns first, then the rest alphabetically, the fallback last
+	var titles []string
+	seen := map[string]bool{}
+	for _, k := range order {
+		titles = append(titles, k)
+		seen[k] = true
+	}
+	var rest []string
+	for k := range cards {
+		if !seen[k] && k != fallback {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	titles = append(titles, rest...)
+	if fallback != "" && len(cards[fallback]) > 0 {
+		titles = append(titles, fallback)
+	}
+
+	columns := make([]ui.BoardColumn, 0, len(titles))
+	for _, title := range titles {
+		columns = append(columns, ui.BoardColumn{Title: title, Cards: cards[title]})
+	}
+	return columns, nil
+}
+
+func init() {
+	boardCmd.Flags().StringVar(&boardBy, "by", "status", "group columns by status, tag, priority or project")
+	rootCmd.AddCommand(boardCmd)
+}
@@ -0,0 +1,119 @@
+/*
+Copyright © 2025 Ethan Bao 522425561@qq.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/ethanbao27/gotodo/internal/ui"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var calendarMonth string
+var calendarDay string
+
+// calendarCmd represents the calendar command
+var calendarCmd = &cobra.Command{
+	Use:   "calendar",
+	Short: "Show a month calendar with the number of tasks due or scheduled each day",
+	Long: `Show a month calendar with the number of tasks due or scheduled each day.
+
+Use --month 2026-11 to pick another month and --day 2026-11-05 (or today,
+tomorrow, ...) to list the tasks due or scheduled on a single day.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tasks, err := storage.List()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+
+		if calendarDay != "" {
+			day, err := parseDateArg(calendarDay, now)
+			if err != nil {
+				return err
+			}
+			return printCalendarDay(tasks, day)
+		}
+
+		month := now
+		if calendarMonth != "" {
+			month, err = time.ParseInLocation("2006-01", calendarMonth, time.Local)
+			if err != nil {
+				return fmt.Errorf("invalid month %q (want YYYY-MM)", calendarMonth)
+			}
+		}
+
+		fmt.Println()
+		ui.PrintCalendar(month, dayCounts(tasks, month), now)
+		fmt.Println()
+		return nil
+	},
+}
+
+// number of open tasks due or scheduled on each day of month, a task due
+// and scheduled on the same day counts once
+func dayCounts(tasks []storage.Task, month time.Time) map[int]int {
+	counts := map[int]int{}
+	for _, t := range tasks {
+		if t.Done {
+			continue
+		}
+		for _, day := range taskDays(t) {
+			if day.Year() == month.Year() && day.Month() == month.Month() {
+				counts[day.Day()]++
+			}
+		}
+	}
+	return counts
+}
+
+// the distinct days a task is scheduled or due on
+func taskDays(t storage.Task) []time.Time {
+	var days []time.Time
+	if d, ok := t.ScheduledDate(); ok {
+		days = append(days, d)
+	}
+	if d, ok := t.DueDate(); ok && t.Due != t.Scheduled {
+		days = append(days, d)
+	}
+	return days
+}
+
+func printCalendarDay(tasks []storage.Task, day string) error {
+	d, err := time.ParseInLocation(storage.DateLayout, day, time.Local)
+	if err != nil {
+		return err
+	}
+
+	var dated []storage.Task
+	for _, t := range tasks {
+		if t.Due == day || t.Scheduled == day {
+			dated = append(dated, t)
+		}
+	}
+
+	fmt.Println()
+	color.New(color.FgBlue, color.Bold).Printf("  %s  ", d.Format("Monday, January 02 2006"))
+	color.New(color.FgWhite, color.Faint).Printf("  %d tasks due or scheduled\n", len(dated))
+	fmt.Println()
+	if len(dated) == 0 {
+		color.New(color.FgYellow).Println("No tasks due or scheduled.")
+		return nil
+	}
+	for _, t := range dated {
+		printDatedTask(t)
+	}
+	fmt.Println()
+	return nil
+}
+
+func init() {
+	calendarCmd.Flags().StringVar(&calendarMonth, "month", "", "month to show as YYYY-MM (default current month)")
+	calendarCmd.Flags().StringVar(&calendarDay, "day", "", "list the tasks due or scheduled on this day instead")
+	rootCmd.AddCommand(calendarCmd)
+}
@@ -3,7 +3,9 @@ package cmd
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethanbao27/gotodo/internal/storage"
 )
@@ -270,4 +272,289 @@ func TestClearCommand(t *testing.T) {
 			t.Error("All tasks should have been cleared")
 		}
 	})
-}
\ No newline at end of file
+}
+func TestShellCommand(t *testing.T) {
+	// Create temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "gotodo-cmd-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Set temporary file path
+	testFile := filepath.Join(tempDir, "test_tasks.json")
+	storage.SetPath(testFile)
+
+	// Test splitting shell lines into arguments
+	t.Run("SplitArgs", func(t *testing.T) {
+		args, err := splitShellArgs(`add "buy milk" and 'eggs'`)
+		if err != nil {
+			t.Fatalf("Failed to split args: %v", err)
+		}
+		expected := []string{"add", "buy milk", "and", "eggs"}
+		if len(args) != len(expected) {
+			t.Fatalf("Expected %v, got %v", expected, args)
+		}
+		for i := range expected {
+			if args[i] != expected[i] {
+				t.Errorf("Expected arg %d to be '%s', got '%s'", i, expected[i], args[i])
+			}
+		}
+
+		if _, err := splitShellArgs(`add "unterminated`); err == nil {
+			t.Error("Expected error for unterminated quote")
+		}
+	})
+
+	// Test running commands in-process without flags leaking between lines
+	t.Run("RunLines", func(t *testing.T) {
+		inShell = true
+		defer func() { inShell = false }()
+		shellPath = testFile
+
+		runShellLine([]string{"add", "Shell task"})
+		runShellLine([]string{"clear"})
+
+		tasks, err := storage.List()
+		if err != nil {
+			t.Fatalf("Failed to list tasks: %v", err)
+		}
+		if len(tasks) != 1 || tasks[0].Content != "Shell task" {
+			t.Errorf("Expected the shell task to be kept, got %v", tasks)
+		}
+	})
+
+	// Test completing commands and task IDs
+	t.Run("Complete", func(t *testing.T) {
+		matches := shellComplete("do")
+		if len(matches) != 1 || matches[0] != "done" {
+			t.Errorf("Expected [done], got %v", matches)
+		}
+
+		matches = shellComplete("done ")
+		if len(matches) != 1 || matches[0] != "1" {
+			t.Errorf("Expected [1], got %v", matches)
+		}
+	})
+
+	// Test --db only applies to the line it is given on
+	t.Run("DBPerLine", func(t *testing.T) {
+		inShell = true
+		defer func() { inShell = false }()
+		shellPath = testFile
+		other := filepath.Join(tempDir, "other.json")
+
+		runShellLine([]string{"add", "--db", other, "Other task"})
+		runShellLine([]string{"add", "Second shell task"})
+
+		storage.SetPath(other)
+		tasks, _ := storage.List()
+		if len(tasks) != 1 || tasks[0].Content != "Other task" {
+			t.Errorf("Expected only the --db task in the other file, got %v", tasks)
+		}
+		storage.SetPath(testFile)
+		tasks, _ = storage.List()
+		if len(tasks) != 2 || tasks[1].Content != "Second shell task" {
+			t.Errorf("Expected the second task back in the shell's file, got %v", tasks)
+		}
+	})
+}
+
+func TestBoardCommand(t *testing.T) {
+	// Create temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "gotodo-cmd-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Set temporary file path
+	testFile := filepath.Join(tempDir, "test_tasks.json")
+	storage.SetPath(testFile)
+
+	tasks := []storage.Task{
+		{ID: 1, Content: "Write docs", Tags: []string{"docs"}, Priority: "low"},
+		{ID: 2, Content: "Fix bug", Tags: []string{"bug", "urgent"}, Priority: "high", Done: true},
+		{ID: 3, Content: "Plan sprint"},
+	}
+
+	// Test grouping by tag, a task appears once per tag
+	t.Run("GroupByTag", func(t *testing.T) {
+		columns, err := boardColumns(tasks, "tag")
+		if err != nil {
+			t.Fatalf("Failed to group tasks: %v", err)
+		}
+		var titles []string
+		for _, c := range columns {
+			titles = append(titles, c.Title)
+		}
+		expected := []string{"bug", "docs", "urgent", "untagged"}
+		if strings.Join(titles, ",") != strings.Join(expected, ",") {
+			t.Errorf("Expected columns %v, got %v", expected, titles)
+		}
+	})
+
+	// Test grouping by priority keeps the fixed order
+	t.Run("GroupByPriority", func(t *testing.T) {
+		columns, err := boardColumns(tasks, "priority")
+		if err != nil {
+			t.Fatalf("Failed to group tasks: %v", err)
+		}
+		if columns[0].Title != "high" || len(columns[0].Cards) != 1 {
+			t.Errorf("Expected first column to be high with 1 card, got %s with %d", columns[0].Title, len(columns[0].Cards))
+		}
+		if last := columns[len(columns)-1]; last.Title != "none" || len(last.Cards) != 1 {
+			t.Errorf("Expected last column to be none with 1 card, got %s with %d", last.Title, len(last.Cards))
+		}
+	})
+
+	// Test running the board with an invalid grouping
+	t.Run("InvalidGrouping", func(t *testing.T) {
+		testRootCmd := rootCmd
+		testRootCmd.SetArgs([]string{"--db", testFile, "board", "--by", "colour"})
+		err := testRootCmd.Execute()
+		if err == nil {
+			t.Error("Expected error for invalid --by value")
+		}
+	})
+}
+
+func TestAgendaAndCalendar(t *testing.T) {
+	// Wednesday
+	now := time.Date(2026, 10, 21, 15, 0, 0, 0, time.Local)
+
+	// Test parsing relative and absolute dates
+	t.Run("ParseDates", func(t *testing.T) {
+		cases := map[string]string{
+			"today":      "2026-10-21",
+			"tomorrow":   "2026-10-22",
+			"+3d":        "2026-10-24",
+			"fri":        "2026-10-23",
+			"wednesday":  "2026-10-28",
+			"2026-11-05": "2026-11-05",
+		}
+		for in, expected := range cases {
+			got, err := parseDateArg(in, now)
+			if err != nil {
+				t.Errorf("Failed to parse %s: %v", in, err)
+				continue
+			}
+			if got != expected {
+				t.Errorf("Expected %s to parse as %s, got %s", in, expected, got)
+			}
+		}
+
+		if _, err := parseDateArg("someday", now); err == nil {
+			t.Error("Expected error for invalid date")
+		}
+	})
+
+	// Test splitting tasks into agenda sections
+	t.Run("Sections", func(t *testing.T) {
+		tasks := []storage.Task{
+			{ID: 1, Content: "Late", Due: "2026-10-19"},
+			{ID: 2, Content: "Now", Due: "2026-10-21"},
+			{ID: 3, Content: "Next", Due: "2026-10-22"},
+			{ID: 4, Content: "Weekend", Due: "2026-10-25"},
+			{ID: 5, Content: "Next week", Due: "2026-10-26"},
+			{ID: 6, Content: "Finished", Due: "2026-10-19", Done: true},
+			{ID: 7, Content: "Undated"},
+		}
+		sections := agendaSections(tasks, now)
+		expected := map[string]int{"Overdue": 1, "Today": 2, "Tomorrow": 3, "This week": 4}
+		for _, s := range sections {
+			if len(s.Tasks) != 1 || s.Tasks[0].ID != expected[s.Title] {
+				t.Errorf("Expected %s to hold task %d, got %v", s.Title, expected[s.Title], s.Tasks)
+			}
+		}
+	})
+
+	// Test scheduled dates place tasks in the agenda before they are due
+	t.Run("Scheduled", func(t *testing.T) {
+		tasks := []storage.Task{
+			{ID: 1, Content: "Started late", Scheduled: "2026-10-19", Due: "2026-11-10"},
+			{ID: 2, Content: "Plan", Scheduled: "2026-10-22", Due: "2026-11-10"},
+			{ID: 3, Content: "Missed", Scheduled: "2026-10-22", Due: "2026-10-20"},
+			{ID: 4, Content: "Later", Scheduled: "2026-11-02"},
+		}
+		sections := agendaSections(tasks, now)
+		expected := map[string]int{"Overdue": 3, "Today": 1, "Tomorrow": 2}
+		for _, s := range sections {
+			if id, ok := expected[s.Title]; ok && (len(s.Tasks) != 1 || s.Tasks[0].ID != id) {
+				t.Errorf("Expected %s to hold task %d, got %v", s.Title, id, s.Tasks)
+			} else if !ok && len(s.Tasks) != 0 {
+				t.Errorf("Expected %s to be empty, got %v", s.Title, s.Tasks)
+			}
+		}
+	})
+
+	// Test counting due and scheduled tasks per day of the month
+	t.Run("DayCounts", func(t *testing.T) {
+		tasks := []storage.Task{
+			{ID: 1, Due: "2026-11-05"},
+			{ID: 2, Due: "2026-11-05"},
+			{ID: 3, Due: "2026-12-05"},
+			{ID: 4, Scheduled: "2026-11-03", Due: "2026-11-05"},
+			{ID: 5, Scheduled: "2026-11-03", Due: "2026-11-03"},
+			{ID: 6, Scheduled: "2026-11-03", Done: true},
+		}
+		counts := dayCounts(tasks, time.Date(2026, 11, 1, 0, 0, 0, 0, time.Local))
+		if counts[5] != 3 || counts[3] != 2 || len(counts) != 2 {
+			t.Errorf("Expected 3 tasks on the 5th and 2 on the 3rd, got %v", counts)
+		}
+	})
+}
+
+func TestApplyEvent(t *testing.T) {
+	tasks := []storage.Task{{ID: 1, Content: "One"}, {ID: 2, Content: "Two"}}
+
+	tasks = applyEvent(tasks, storage.Event{Action: storage.ActionAdd, Task: storage.Task{ID: 3, Content: "Three"}})
+	tasks = applyEvent(tasks, storage.Event{Action: storage.ActionDone, Task: storage.Task{ID: 1, Content: "One", Done: true}})
+	tasks = applyEvent(tasks, storage.Event{Action: storage.ActionDelete, Task: storage.Task{ID: 2}})
+	tasks = applyEvent(tasks, storage.Event{Action: storage.ActionDelete, Task: storage.Task{ID: 9}})
+
+	if len(tasks) != 2 || tasks[0].ID != 1 || !tasks[0].Done || tasks[1].ID != 3 {
+		t.Errorf("Expected tasks 1 (done) and 3, got %+v", tasks)
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	now := time.Date(2026, 10, 21, 15, 0, 0, 0, time.Local)
+	entries := []dashboardEntry{
+		{Target: friendTarget{Label: "alice"}, Tasks: []storage.Task{
+			{ID: 1, Content: "Late", Due: "2026-10-19"},
+			{ID: 2, Content: "Finished", Due: "2026-10-01", Done: true},
+		}},
+		{Target: friendTarget{Label: "bob"}, StaleSince: now.Add(-time.Hour), Err: os.ErrDeadlineExceeded, Tasks: []storage.Task{
+			{ID: 1, Content: "Later", Due: "2026-10-20"},
+			{ID: 2, Content: "Oldest", Due: "2026-10-02"},
+			{ID: 3, Content: "Today", Due: "2026-10-21"},
+		}},
+		{Target: friendTarget{Label: "carol"}, Err: os.ErrDeadlineExceeded},
+	}
+
+	// Test totals count cached copies and skip unreachable friends
+	t.Run("Totals", func(t *testing.T) {
+		done, total := dashboardTotals(entries)
+		if done != 1 || total != 5 {
+			t.Errorf("Expected 1/5 done, got %d/%d", done, total)
+		}
+		if p := percent(0, 0); p != 0 {
+			t.Errorf("Expected 0%% of nothing, got %v", p)
+		}
+	})
+
+	// Test overdue tasks are merged and sorted by due date
+	t.Run("Overdue", func(t *testing.T) {
+		overdue := dashboardOverdue(entries, now)
+		expected := []string{"bob Oldest", "alice Late", "bob Later"}
+		if len(overdue) != len(expected) {
+			t.Fatalf("Expected %d overdue tasks, got %v", len(expected), overdue)
+		}
+		for i, o := range overdue {
+			if got := o.Friend + " " + o.Task.Content; got != expected[i] {
+				t.Errorf("Expected %s at %d, got %s", expected[i], i, got)
+			}
+		}
+	})
+}
@@ -0,0 +1,61 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var commentCmd = &cobra.Command{
+	Use:   "comment <id> [text]",
+	Short: "Comment on a task, or show its comments",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+		if len(args) > 1 {
+			if _, err := storage.AddComment(id, storage.Comment{Text: strings.Join(args[1:], " ")}); err != nil {
+				return err
+			}
+			fmt.Printf("Commented on task %d.\n", id)
+			return nil
+		}
+
+		t, err := storage.Get(id)
+		if err != nil {
+			return err
+		}
+		color.New(color.FgWhite, color.Bold).Printf("[%d] %s\n", t.ID, t.Content)
+		if len(t.Comments) == 0 {
+			color.New(color.FgYellow).Println("No comments.")
+			return nil
+		}
+		for _, c := range t.Comments {
+			author := c.Author
+			if author == "" {
+				author = "me"
+			}
+			when := c.CreatedAt
+			if at, err := storage.ParseTime(c.CreatedAt); err == nil {
+				when = at.Format("Jan 02 15:04")
+			}
+			color.New(color.FgCyan).Printf("  %s", author)
+			color.New(color.FgWhite, color.Faint).Printf(" %s\n", when)
+			fmt.Printf("    %s\n", c.Text)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(commentCmd)
+}
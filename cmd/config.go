@@ -8,7 +8,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/ethanbao27/gotodo/internal/network"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
@@ -42,27 +46,13 @@ var setDbPathCmd = &cobra.Command{
 		os.Remove(testFile)
 		
 		// Save config to ~/.gotodo/config.json
-		home, err := os.UserHomeDir()
+		config, err := loadConfig()
 		if err != nil {
-			return fmt.Errorf("failed to get home directory: %v", err)
+			return err
 		}
-		
-		configDir := filepath.Join(home, ".gotodo")
-		if err := os.MkdirAll(configDir, 0755); err != nil {
-			return fmt.Errorf("failed to create config directory: %v", err)
-		}
-		
-		configFile := filepath.Join(configDir, "config.json")
-		config := map[string]string{"db_path": path}
-		
-		// Save config
-		data, err := json.MarshalIndent(config, "", " ")
-		if err != nil {
-			return fmt.Errorf("failed to marshal config: %v", err)
-		}
-		
-		if err := os.WriteFile(configFile, data, 0644); err != nil {
-			return fmt.Errorf("failed to save config: %v", err)
+		config["db_path"] = path
+		if err := saveConfig(config); err != nil {
+			return err
 		}
 		
 		color.New(color.FgGreen).Printf("✓ Database path set to: %s\n", path)
@@ -75,38 +65,191 @@ var showConfigCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Show current configuration",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		home, err := os.UserHomeDir()
+		configFile, err := configFilePath()
 		if err != nil {
-			return fmt.Errorf("failed to get home directory: %v", err)
+			return err
 		}
 		
-		configFile := filepath.Join(home, ".gotodo", "config.json")
-		
 		if _, err := os.Stat(configFile); os.IsNotExist(err) {
 			color.New(color.FgYellow).Println("No configuration file found, using default settings")
 			return nil
 		}
 		
-		data, err := os.ReadFile(configFile)
+		config, err := loadConfig()
 		if err != nil {
-			return fmt.Errorf("failed to read config file: %v", err)
-		}
-		
-		var config map[string]string
-		if err := json.Unmarshal(data, &config); err != nil {
-			return fmt.Errorf("failed to parse config file: %v", err)
+			return err
 		}
 		
 		color.New(color.FgGreen).Println("Current configuration:")
 		if dbPath, exists := config["db_path"]; exists {
 			color.New(color.FgCyan).Printf("Database path: %s\n", dbPath)
 		}
+		if port, exists := config["friend_port"]; exists {
+			color.New(color.FgCyan).Printf("Friend port: %s\n", port)
+		}
+		if remote, exists := config[remoteConfigKey]; exists {
+			color.New(color.FgCyan).Printf("Remote list: %s\n", remote)
+		}
+		if store, err := webhookStore(); err == nil {
+			if subs, err := store.List(); err == nil && len(subs) > 0 {
+				color.New(color.FgCyan).Printf("Webhooks: %d (see 'gotodo webhook list')\n", len(subs))
+			}
+		}
+		for _, column := range sortedKeys(wipLimits(config)) {
+			color.New(color.FgCyan).Printf("WIP limit for %s: %s\n", column, config[wipKeyPrefix+column])
+		}
+		return nil
+	},
+}
+
+var setWipCmd = &cobra.Command{
+	Use:   "set-wip <column> <limit>",
+	Short: "Set the WIP limit of a board column",
+	Long: `Set the work-in-progress limit shown by 'gotodo board' for a column,
+e.g. 'gotodo config set-wip todo 5'. A limit of 0 removes it.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		column := strings.ToLower(args[0])
+		limit, err := strconv.Atoi(args[1])
+		if err != nil || limit < 0 {
+			return fmt.Errorf("invalid limit %q, expected a non-negative number", args[1])
+		}
+
+		config, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if limit == 0 {
+			delete(config, wipKeyPrefix+column)
+		} else {
+			config[wipKeyPrefix+column] = strconv.Itoa(limit)
+		}
+		if err := saveConfig(config); err != nil {
+			return err
+		}
+
+		if limit == 0 {
+			color.New(color.FgGreen).Printf("✓ WIP limit for %s removed\n", column)
+		} else {
+			color.New(color.FgGreen).Printf("✓ WIP limit for %s set to %d\n", column, limit)
+		}
 		return nil
 	},
 }
 
+var setPortCmd = &cobra.Command{
+	Use:   "set-port <port>",
+	Short: "Set the default port for friend serve and connect",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := network.ValidatePort(args[0]); err != nil {
+			return err
+		}
+		config, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		config["friend_port"] = args[0]
+		if err := saveConfig(config); err != nil {
+			return err
+		}
+		color.New(color.FgGreen).Printf("✓ Friend port set to: %s\n", args[0])
+		return nil
+	},
+}
+
+// config keys holding board WIP limits look like "wip.todo"
+const wipKeyPrefix = "wip."
+
+// directory holding gotodo's own files, ~/.gotodo
+func gotodoDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+	return filepath.Join(home, ".gotodo"), nil
+}
+
+// path of a file inside gotodoDir
+func gotodoFile(name string) (string, error) {
+	dir, err := gotodoDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// location of the config file, ~/.gotodo/config.json
+func configFilePath() (string, error) {
+	return gotodoFile("config.json")
+}
+
+// load the config file, a missing file gives an empty config
+func loadConfig() (map[string]string, error) {
+	configFile, err := configFilePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(configFile)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	config := map[string]string{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	}
+	return config, nil
+}
+
+// write the whole config back to disk
+func saveConfig(config map[string]string) error {
+	configFile, err := configFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+	data, err := json.MarshalIndent(config, "", " ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %v", err)
+	}
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to save config: %v", err)
+	}
+	return nil
+}
+
+// WIP limits by lower-case column name
+func wipLimits(config map[string]string) map[string]int {
+	limits := map[string]int{}
+	for k, v := range config {
+		if !strings.HasPrefix(k, wipKeyPrefix) {
+			continue
+		}
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limits[strings.TrimPrefix(k, wipKeyPrefix)] = n
+		}
+	}
+	return limits
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func init() {
 	rootCmd.AddCommand(configCmd)
 	configCmd.AddCommand(setDbPathCmd)
 	configCmd.AddCommand(showConfigCmd)
+	configCmd.AddCommand(setWipCmd)
+	configCmd.AddCommand(setPortCmd)
 }
\ No newline at end of file
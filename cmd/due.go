@@ -0,0 +1,90 @@
+/*
+Copyright © 2025 Ethan Bao 522425561@qq.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// dueCmd represents the due command
+var dueCmd = &cobra.Command{
+	Use:   "due <id> <date|none>",
+	Short: "Set or clear the due date of a task",
+	Long: `Set the due date of a task. Dates can be YYYY-MM-DD, today, tomorrow,
+a weekday such as fri, or an offset such as +3d. Use 'none' to clear it.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+		due := ""
+		if args[1] != "none" {
+			if due, err = parseDateArg(args[1], time.Now()); err != nil {
+				return err
+			}
+		}
+		if err := storage.SetDue(id, due); err != nil {
+			return err
+		}
+		if due == "" {
+			fmt.Printf("Task %d has no due date.\n", id)
+		} else {
+			fmt.Printf("Task %d is due %s.\n", id, due)
+		}
+		return nil
+	},
+}
+
+// turn a user supplied date into storage.DateLayout, relative to now
+func parseDateArg(s string, now time.Time) (string, error) {
+	today := startOfDay(now)
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	switch s {
+	case "today":
+		return today.Format(storage.DateLayout), nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1).Format(storage.DateLayout), nil
+	}
+
+	if strings.HasPrefix(s, "+") && strings.HasSuffix(s, "d") {
+		if n, err := strconv.Atoi(s[1 : len(s)-1]); err == nil {
+			return today.AddDate(0, 0, n).Format(storage.DateLayout), nil
+		}
+	}
+
+	for i := 0; i < 7; i++ {
+		wd := time.Weekday(i)
+		name := strings.ToLower(wd.String())
+		if s == name || s == name[:3] {
+			days := (int(wd) - int(today.Weekday()) + 7) % 7
+			if days == 0 {
+				days = 7
+			}
+			return today.AddDate(0, 0, days).Format(storage.DateLayout), nil
+		}
+	}
+
+	if d, err := time.ParseInLocation(storage.DateLayout, s, time.Local); err == nil {
+		return d.Format(storage.DateLayout), nil
+	}
+	return "", fmt.Errorf("invalid date %q (want YYYY-MM-DD, today, tomorrow, a weekday or +Nd)", s)
+}
+
+// local midnight of the day containing t
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func init() {
+	rootCmd.AddCommand(dueCmd)
+}
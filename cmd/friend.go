@@ -4,12 +4,30 @@ Copyright © 2025 Ethan Bao
 package cmd
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
+var friendListen string
+var friendPort int
+var friendToken string
+var serveMaxConns int
+var serveIdleTimeout time.Duration
+var serveRateLimit float64
+
+// how long friend serve waits for requests in progress when stopped
+const serveShutdownTimeout = 10 * time.Second
+
 // friendCmd represents the friend command
 var friendCmd = &cobra.Command{
 	Use:   "friend",
@@ -17,27 +35,218 @@ var friendCmd = &cobra.Command{
 }
 
 var serveCmd = &cobra.Command{
-	Use:   "serve [ip]",
+	Use:   "serve [address]",
 	Short: "Start a friend server to share your todo list",
-	Args:  cobra.ExactArgs(1),
+	Long: `Start a friend server to share your todo list.
+
+The address is an IP or host name, optionally with a port
+(192.168.1.20, 0.0.0.0:9000, [::1]:9000). It can also be given with --listen.
+Without a port, --port or the friend_port config key is used (default 8088).`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ip := args[0]
-		addr := fmt.Sprintf("%s:8088", ip)
-		return network.StartServer(addr)
+		listen := friendListen
+		if len(args) == 1 {
+			if listen != "" {
+				return fmt.Errorf("give the address either as an argument or with --listen, not both")
+			}
+			listen = args[0]
+		}
+		if listen == "" {
+			return fmt.Errorf("no listen address given, e.g. 'gotodo friend serve 0.0.0.0'")
+		}
+		addr, err := friendAddr(cmd, listen, true)
+		if err != nil {
+			return err
+		}
+		tokens, err := friendTokenStore()
+		if err != nil {
+			return err
+		}
+		shares, err := friendShareStore()
+		if err != nil {
+			return err
+		}
+		cert, err := friendCertificate()
+		if err != nil {
+			return err
+		}
+		logger, access, closeLogs, err := serverLoggers()
+		if err != nil {
+			return err
+		}
+		defer closeLogs()
+		srv, err := network.StartServer(addr, network.ServerConfig{
+			Tokens:      tokens,
+			Certificate: cert,
+			Shares:      shares,
+			MaxConns:    serveMaxConns,
+			IdleTimeout: serveIdleTimeout,
+			RateLimit:   serveRateLimit,
+			Logger:      logger,
+			AccessLog:   access,
+		})
+		if err != nil {
+			return err
+		}
+		admin, err := startAdmin(srv, logger)
+		if err != nil {
+			srv.Close()
+			return err
+		}
+		if admin != nil {
+			defer admin.Close()
+		}
+		if logFile != "" {
+			color.New(color.FgBlue, color.Bold).Printf("Friend server started on %s, logging to %s\n", srv.Addr(), logFile)
+		}
+		if !serveNoAdvertise {
+			adv, err := advertiseServer(addr, cert)
+			if err != nil {
+				color.New(color.FgYellow).Printf("Not advertising on the local network: %v\n", err)
+			} else if adv != nil {
+				defer adv.Close()
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		<-ctx.Done()
+		stop()
+		color.New(color.FgBlue).Println("\nShutting down, finishing requests in progress...")
+		ctx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			color.New(color.FgYellow).Println("Closed connections that did not finish in time")
+		}
+		return nil
 	},
 }
 
 var connectCmd = &cobra.Command{
-	Use:   "connect [ip]",
-	Short: "Connect to a friend and fetch their todo list (ip address)",
-	Args:  cobra.ExactArgs(1),
+	Use:   "connect <name|address>",
+	Short: "Connect to a friend and fetch their todo list",
+	Long: `Connect to a friend and fetch their todo list.
+
+Give the name of a friend saved with 'gotodo friend add', or an address:
+an IP or host name, optionally with a port (192.168.1.20, example.com:9000,
+[fe80::1]:9000). Without a port, --port or the friend_port config key is
+used (default 8088). If your friend issued you a token, pass it with --token.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return network.ConnectAndFetch(args[0])
+		target, err := resolveFriend(cmd, args[0], friendToken)
+		if err != nil {
+			return err
+		}
+		tasks, err := network.FetchTasks(target.Addr, target.Opts)
+		if err != nil {
+			if cached, ok := target.cached(); ok && unreachable(err) {
+				network.PrintTasks(target.Label, cached.Tasks)
+				color.New(color.FgYellow).Printf("\n ⚠ Could not reach %s: %v\n", target.Label, err)
+				color.New(color.FgYellow).Printf("   Showing the copy fetched last, stale since %s (%s)\n",
+					cached.FetchedAt.Local().Format("Jan 02 15:04"), ago(cached.FetchedAt))
+				return nil
+			}
+			return target.dialError(err)
+		}
+		target.seen()
+		target.remember(tasks)
+		network.PrintTasks(target.Label, tasks)
+		return nil
 	},
 }
 
+var forgetCmd = &cobra.Command{
+	Use:   "forget <name|address>",
+	Short: "Forget the pinned certificate of a friend",
+	Long: `Forget the certificate fingerprint pinned for a friend, so the next
+'gotodo friend connect' trusts whatever certificate it is shown.
+Only do this after checking the new fingerprint with your friend.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, err := resolveFriend(cmd, args[0], "")
+		if err != nil {
+			return err
+		}
+		if target.Name != "" {
+			book, err := addressBook()
+			if err != nil {
+				return err
+			}
+			err = book.ForgetFingerprint(target.Name)
+		} else {
+			pins, err := knownFriends()
+			if err != nil {
+				return err
+			}
+			err = pins.Forget(target.Addr)
+		}
+		if err != nil {
+			return err
+		}
+		color.New(color.FgGreen).Printf("✓ Forgot the certificate of %s\n", target.Label)
+		return nil
+	},
+}
+
+// resolve a friend address to host:port using --port, the friend_port
+// config key or the default port
+func friendAddr(cmd *cobra.Command, addr string, listen bool) (string, error) {
+	port := network.DefaultPort
+	if config, err := loadConfig(); err == nil {
+		if p, ok := config["friend_port"]; ok {
+			if err := network.ValidatePort(p); err != nil {
+				return "", fmt.Errorf("friend_port in config: %v", err)
+			}
+			port, _ = strconv.Atoi(p)
+		}
+	}
+	if cmd.Flags().Changed("port") {
+		if network.HasPort(addr) {
+			return "", fmt.Errorf("address %q already has a port, drop --port or the port in the address", addr)
+		}
+		if err := network.ValidatePort(strconv.Itoa(friendPort)); err != nil {
+			return "", err
+		}
+		port = friendPort
+	}
+	return network.NormalizeAddr(addr, port, listen)
+}
+
+// the friend server certificate, ~/.gotodo/friend_cert.pem and
+// friend_key.pem, created on first use
+func friendCertificate() (tls.Certificate, error) {
+	certPath, err := gotodoFile("friend_cert.pem")
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPath, err := gotodoFile("friend_key.pem")
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return network.LoadOrCreateCertificate(certPath, keyPath)
+}
+
+// fingerprints of friends connected to before, ~/.gotodo/known_friends
+func knownFriends() (*network.KnownFriends, error) {
+	path, err := gotodoFile("known_friends")
+	if err != nil {
+		return nil, err
+	}
+	return network.NewKnownFriends(path), nil
+}
+
 func init() {
+	serveCmd.Flags().StringVar(&friendListen, "listen", "", "address to listen on, e.g. 0.0.0.0 or [::]:9000")
+	serveCmd.Flags().IntVar(&serveMaxConns, "max-conns", network.DefaultMaxConns, "most friends connected at once")
+	serveCmd.Flags().DurationVar(&serveIdleTimeout, "idle-timeout", network.DefaultIdleTimeout, "close connections idle for this long")
+	serveCmd.Flags().Float64Var(&serveRateLimit, "rate-limit", network.DefaultRateLimit, "requests per second allowed from one address, -1 for no limit")
+	addLogFlags(serveCmd)
+	connectCmd.Flags().StringVar(&friendToken, "token", "", "token issued by your friend")
+	for _, c := range []*cobra.Command{serveCmd, connectCmd, forgetCmd} {
+		c.Flags().IntVar(&friendPort, "port", network.DefaultPort, "port to use when the address has none")
+	}
 	friendCmd.AddCommand(serveCmd)
 	friendCmd.AddCommand(connectCmd)
+	friendCmd.AddCommand(forgetCmd)
 	rootCmd.AddCommand(friendCmd)
 }
@@ -0,0 +1,51 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/network"
+)
+
+// port of the admin listener when --admin has none
+const defaultAdminPort = 9090
+
+var serveAdmin string
+
+// serve /healthz and /metrics of the friend server on --admin, nil when
+// the flag is not set
+func startAdmin(srv *network.Server, logger *slog.Logger) (*http.Server, error) {
+	if serveAdmin == "" {
+		return nil, nil
+	}
+	addr, err := network.NormalizeAddr(serveAdmin, defaultAdminPort, true)
+	if err != nil {
+		return nil, fmt.Errorf("--admin: %v", err)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("admin listen error: %v", err)
+	}
+	admin := &http.Server{
+		Handler:           srv.AdminHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+	}
+	go func() {
+		if err := admin.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Error("admin listener failed", "err", err)
+		}
+	}()
+	logger.Info("admin listener started", "addr", ln.Addr().String(), "paths", "/healthz /metrics")
+	return admin, nil
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAdmin, "admin", "", "serve /healthz and /metrics on this address, e.g. 127.0.0.1:9090")
+}
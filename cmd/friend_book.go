@@ -0,0 +1,262 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var friendAddToken string
+
+var friendAddCmd = &cobra.Command{
+	Use:   "add <name> <address>",
+	Short: "Save a friend's address under a name",
+	Long: `Save a friend's address (and optionally the token they gave you)
+under a name, so you can run 'gotodo friend connect alice'.`,
+	Example: `  gotodo friend add alice 192.168.1.20:8088 --token gtd_...`,
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if err := network.ValidateFriendName(name); err != nil {
+			return err
+		}
+		addr, err := friendAddr(cmd, args[1], false)
+		if err != nil {
+			return err
+		}
+		book, err := addressBook()
+		if err != nil {
+			return err
+		}
+
+		f, exists, err := book.Get(name)
+		if err != nil {
+			return err
+		}
+		// a pinned certificate only carries over while the address stays the same
+		if !exists || f.Addr != addr {
+			f = network.Friend{Name: name, Token: f.Token}
+		}
+		f.Addr = addr
+		if cmd.Flags().Changed("token") {
+			f.Token = friendAddToken
+		}
+		if err := book.Put(f); err != nil {
+			return err
+		}
+		if exists {
+			color.New(color.FgGreen).Printf("✓ Updated %s (%s)\n", name, addr)
+		} else {
+			color.New(color.FgGreen).Printf("✓ Added %s (%s)\n", name, addr)
+		}
+		return nil
+	},
+}
+
+var friendListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved friends",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		book, err := addressBook()
+		if err != nil {
+			return err
+		}
+		friends, err := book.List()
+		if err != nil {
+			return err
+		}
+		if len(friends) == 0 {
+			color.New(color.FgYellow).Println("No friends saved, add one with 'gotodo friend add <name> <address>'.")
+			return nil
+		}
+		for _, f := range friends {
+			lastSeen := "never"
+			if t, err := storage.ParseTime(f.LastSeen); err == nil {
+				lastSeen = t.Format("Jan 02 15:04")
+			}
+			color.New(color.FgWhite, color.Bold).Printf("  %-12s", f.Name)
+			color.New(color.FgWhite).Printf(" %-28s", f.Addr)
+			color.New(color.FgCyan, color.Faint).Printf(" last seen %s", lastSeen)
+			if f.Token != "" {
+				color.New(color.FgCyan, color.Faint).Print(", token")
+			}
+			if f.Fingerprint != "" {
+				color.New(color.FgCyan, color.Faint).Print(", pinned")
+			}
+			fmt.Println()
+		}
+		return nil
+	},
+}
+
+var friendRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a saved friend",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		book, err := addressBook()
+		if err != nil {
+			return err
+		}
+		if err := book.Remove(args[0]); err != nil {
+			return err
+		}
+		if cache, err := friendCache(); err == nil {
+			_ = cache.Remove(args[0])
+		}
+		color.New(color.FgGreen).Printf("✓ Removed %s\n", args[0])
+		return nil
+	},
+}
+
+// where and how to reach a friend given by name or address
+type friendTarget struct {
+	Name  string // empty when given as an address
+	Addr  string
+	Label string // name or address, for display
+	Opts  network.DialOptions
+}
+
+// resolve a saved friend name, or otherwise an address, into a target.
+// token is used when not empty, overriding a saved one.
+func resolveFriend(cmd *cobra.Command, nameOrAddr, token string) (friendTarget, error) {
+	book, err := addressBook()
+	if err != nil {
+		return friendTarget{}, err
+	}
+	if f, ok, err := book.Get(nameOrAddr); err != nil {
+		return friendTarget{}, err
+	} else if ok {
+		t := friendTarget{Name: f.Name, Addr: f.Addr, Label: f.Name}
+		t.Opts = network.DialOptions{Token: f.Token, Pins: book.Pinner(f.Name)}
+		if token != "" {
+			t.Opts.Token = token
+		}
+		return t, nil
+	}
+
+	addr, err := friendAddr(cmd, nameOrAddr, false)
+	if err != nil {
+		return friendTarget{}, fmt.Errorf("%q is neither a saved friend nor a valid address: %v", nameOrAddr, err)
+	}
+	pins, err := knownFriends()
+	if err != nil {
+		return friendTarget{}, err
+	}
+	return friendTarget{Addr: addr, Label: addr, Opts: network.DialOptions{Token: token, Pins: pins}}, nil
+}
+
+// note a successful connection in the address book
+func (t friendTarget) seen() {
+	if t.Name == "" {
+		return
+	}
+	if book, err := addressBook(); err == nil {
+		_ = book.Touch(t.Name, time.Now())
+	}
+}
+
+// refer to the friend by the name the user typed in a certificate warning
+func (t friendTarget) dialError(err error) error {
+	var mismatch *network.FingerprintMismatchError
+	if errors.As(err, &mismatch) {
+		mismatch.Addr = t.Label
+	}
+	return err
+}
+
+// whether err means the friend could not be reached, rather than that it
+// refused us, so trying again later or showing a cached copy makes sense
+func unreachable(err error) bool {
+	var mismatch *network.FingerprintMismatchError
+	if errors.As(err, &mismatch) {
+		return false
+	}
+	var perr *network.Error
+	if errors.As(err, &perr) {
+		return perr.Code == network.ErrBusy || perr.Code == network.ErrRateLimited
+	}
+	return true
+}
+
+// the friend's entry in the friend cache
+func (t friendTarget) cacheKey() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.Addr
+}
+
+// keep a fetched list for when the friend is unreachable
+func (t friendTarget) remember(tasks []storage.Task) {
+	if cache, err := friendCache(); err == nil {
+		_ = cache.Save(t.cacheKey(), network.CachedList{Friend: t.Name, Addr: t.Addr, FetchedAt: time.Now(), Tasks: tasks})
+	}
+}
+
+// the list fetched last time, ok is false when there is none
+func (t friendTarget) cached() (network.CachedList, bool) {
+	cache, err := friendCache()
+	if err != nil {
+		return network.CachedList{}, false
+	}
+	list, ok, err := cache.Load(t.cacheKey())
+	return list, ok && err == nil
+}
+
+// e.g. "3 hours ago"
+func ago(at time.Time) string {
+	d := time.Since(at)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return plural(int(d.Minutes()), "minute") + " ago"
+	case d < 48*time.Hour:
+		return plural(int(d.Hours()), "hour") + " ago"
+	default:
+		return plural(int(d.Hours()/24), "day") + " ago"
+	}
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// copies of friends' lists, ~/.gotodo/friends/<name>.json
+func friendCache() (*network.FriendCache, error) {
+	dir, err := gotodoFile("friends")
+	if err != nil {
+		return nil, err
+	}
+	return network.NewFriendCache(dir), nil
+}
+
+// saved friends, ~/.gotodo/friends.json
+func addressBook() (*network.AddressBook, error) {
+	path, err := gotodoFile("friends.json")
+	if err != nil {
+		return nil, err
+	}
+	return network.NewAddressBook(path), nil
+}
+
+func init() {
+	friendAddCmd.Flags().StringVar(&friendAddToken, "token", "", "token the friend gave you")
+	friendAddCmd.Flags().IntVar(&friendPort, "port", network.DefaultPort, "port to use when the address has none")
+	friendCmd.AddCommand(friendAddCmd)
+	friendCmd.AddCommand(friendListCmd)
+	friendCmd.AddCommand(friendRemoveCmd)
+}
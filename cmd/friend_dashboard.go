@@ -0,0 +1,238 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/ethanbao27/gotodo/internal/ui"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var dashboardAll bool
+var dashboardTimeout time.Duration
+
+// one friend's part of the dashboard
+type dashboardEntry struct {
+	Target friendTarget
+	Tasks  []storage.Task
+	// set when the friend could not be reached
+	Err error
+	// when the tasks shown were fetched, set for a cached copy
+	StaleSince time.Time
+}
+
+// an overdue task and whose it is
+type overdueTask struct {
+	Friend string
+	Task   storage.Task
+}
+
+var dashboardCmd = &cobra.Command{
+	Use:   "dashboard [name...]",
+	Short: "Show several friends' progress side by side",
+	Long: `Show several friends' progress side by side: a progress bar per friend,
+the progress of everyone together and all overdue tasks. Friends are fetched
+at the same time, each with its own --timeout; for friends that cannot be
+reached the copy fetched last is used.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if dashboardAll == (len(args) > 0) {
+			return fmt.Errorf("give the friends to show, or --all for every saved friend")
+		}
+		names := args
+		if dashboardAll {
+			book, err := addressBook()
+			if err != nil {
+				return err
+			}
+			friends, err := book.List()
+			if err != nil {
+				return err
+			}
+			if len(friends) == 0 {
+				return fmt.Errorf("no friends saved, add one with 'gotodo friend add <name> <address>'")
+			}
+			names = nil
+			for _, f := range friends {
+				names = append(names, f.Name)
+			}
+		}
+
+		entries := make([]dashboardEntry, len(names))
+		// pins of several friends can live in the same file
+		pinMu := &sync.Mutex{}
+		for i, name := range names {
+			target, err := resolveFriend(cmd, name, "")
+			if err != nil {
+				return err
+			}
+			target.Opts.Timeout = dashboardTimeout
+			target.Opts.Pins = lockedPinner{mu: pinMu, pins: target.Opts.Pins}
+			entries[i].Target = target
+		}
+		var wg sync.WaitGroup
+		for i := range entries {
+			wg.Add(1)
+			go func(e *dashboardEntry) {
+				defer wg.Done()
+				e.fetch()
+			}(&entries[i])
+		}
+		wg.Wait()
+		// the address book and cache are written one friend at a time
+		for _, e := range entries {
+			if e.Err == nil {
+				e.Target.seen()
+				e.Target.remember(e.Tasks)
+			}
+		}
+
+		printDashboard(entries, time.Now())
+		return nil
+	},
+}
+
+// fetch the friend's tasks, falling back to the cached copy
+func (e *dashboardEntry) fetch() {
+	tasks, err := network.FetchTasks(e.Target.Addr, e.Target.Opts)
+	if err == nil {
+		e.Tasks = tasks
+		return
+	}
+	e.Err = e.Target.dialError(err)
+	if cached, ok := e.Target.cached(); ok && unreachable(err) {
+		e.Tasks = cached.Tasks
+		e.StaleSince = cached.FetchedAt
+	}
+}
+
+// a Pinner shared by goroutines
+type lockedPinner struct {
+	mu   *sync.Mutex
+	pins network.Pinner
+}
+
+func (p lockedPinner) Pinned(addr string) (string, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pins.Pinned(addr)
+}
+
+func (p lockedPinner) Pin(addr, fingerprint string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pins.Pin(addr, fingerprint)
+}
+
+// whether there are tasks to show for the friend
+func (e dashboardEntry) ok() bool {
+	return e.Err == nil || !e.StaleSince.IsZero()
+}
+
+// done and total tasks of the entries with tasks to show
+func dashboardTotals(entries []dashboardEntry) (done, total int) {
+	for _, e := range entries {
+		if !e.ok() {
+			continue
+		}
+		for _, t := range e.Tasks {
+			total++
+			if t.Done {
+				done++
+			}
+		}
+	}
+	return done, total
+}
+
+// open tasks due before today across all friends, the oldest first
+func dashboardOverdue(entries []dashboardEntry, now time.Time) []overdueTask {
+	today := startOfDay(now)
+	var overdue []overdueTask
+	for _, e := range entries {
+		for _, t := range e.Tasks {
+			if due, ok := t.DueDate(); ok && !t.Done && due.Before(today) {
+				overdue = append(overdue, overdueTask{Friend: e.Target.Label, Task: t})
+			}
+		}
+	}
+	sort.SliceStable(overdue, func(i, j int) bool {
+		return overdue[i].Task.Due < overdue[j].Task.Due
+	})
+	return overdue
+}
+
+func percent(done, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(done) / float64(total) * 100
+}
+
+func printDashboard(entries []dashboardEntry, now time.Time) {
+	width := len("Overall")
+	for _, e := range entries {
+		width = max(width, len(e.Target.Label))
+	}
+
+	fmt.Println()
+	color.New(color.FgBlue, color.Bold).Printf("  TEAM DASHBOARD  ")
+	color.New(color.FgWhite, color.Faint).Printf("  %s\n\n", now.Format("Mon Jan 02 15:04"))
+
+	for _, e := range entries {
+		color.New(color.FgWhite, color.Bold).Printf("  %-*s  ", width, e.Target.Label)
+		if !e.ok() {
+			color.New(color.FgRed).Printf("unreachable: %v\n", e.Err)
+			continue
+		}
+		done, total := dashboardTotals([]dashboardEntry{e})
+		ui.PrintBar(percent(done, total), 24)
+		color.New(color.FgWhite).Printf(" %5.1f%%", percent(done, total))
+		color.New(color.FgWhite, color.Faint).Printf("  %d/%d", done, total)
+		if !e.StaleSince.IsZero() {
+			color.New(color.FgYellow).Printf("  stale since %s", ago(e.StaleSince))
+		}
+		fmt.Println()
+	}
+
+	done, total := dashboardTotals(entries)
+	fmt.Println()
+	color.New(color.FgWhite, color.Bold).Printf("  %-*s  ", width, "Overall")
+	ui.PrintBar(percent(done, total), 24)
+	color.New(color.FgWhite).Printf(" %5.1f%%", percent(done, total))
+	color.New(color.FgWhite, color.Faint).Printf("  %d/%d\n", done, total)
+
+	overdue := dashboardOverdue(entries, now)
+	fmt.Println()
+	if len(overdue) == 0 {
+		color.New(color.FgGreen).Println("  Nothing overdue.")
+		fmt.Println()
+		return
+	}
+	color.New(color.FgRed, color.Bold).Printf("  Overdue (%d)\n", len(overdue))
+	for _, o := range overdue {
+		color.New(color.FgWhite, color.Bold).Printf("   %-*s ", width, o.Friend)
+		color.New(color.FgWhite).Printf(" %3d %s", o.Task.ID, o.Task.Content)
+		if due, ok := o.Task.DueDate(); ok {
+			days := int(math.Round(startOfDay(now).Sub(due).Hours() / 24))
+			color.New(color.FgRed, color.Faint).Printf("  due %s, %s late", due.Format("Mon Jan 02"), plural(days, "day"))
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+}
+
+func init() {
+	dashboardCmd.Flags().BoolVar(&dashboardAll, "all", false, "show every saved friend")
+	dashboardCmd.Flags().DurationVar(&dashboardTimeout, "timeout", 5*time.Second, "give up on a friend after this long")
+	dashboardCmd.Flags().IntVar(&friendPort, "port", network.DefaultPort, "port to use when an address has none")
+	friendCmd.AddCommand(dashboardCmd)
+}
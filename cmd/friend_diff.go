@@ -0,0 +1,74 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <name|address>",
+	Short: "Show what changed in a friend's list since the last fetch",
+	Long: `Fetch a friend's todo list and show the tasks added, completed,
+reopened, changed and removed since it was last fetched with
+'gotodo friend connect' or 'gotodo friend diff'.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, err := resolveFriend(cmd, args[0], friendToken)
+		if err != nil {
+			return err
+		}
+		before, ok := target.cached()
+		tasks, err := network.FetchTasks(target.Addr, target.Opts)
+		if err != nil {
+			if ok && unreachable(err) {
+				return fmt.Errorf("could not reach %s: %v (last fetched %s)", target.Label, err, ago(before.FetchedAt))
+			}
+			return target.dialError(err)
+		}
+		target.seen()
+		target.remember(tasks)
+		if !ok {
+			color.New(color.FgYellow).Printf("No earlier copy of %s's list, saved %d tasks to compare with next time.\n", target.Label, len(tasks))
+			return nil
+		}
+
+		d := network.DiffTasks(before.Tasks, tasks)
+		color.New(color.FgBlue, color.Bold).Printf(" Changes @ %s since %s (%s)\n\n",
+			target.Label, before.FetchedAt.Local().Format("Jan 02 15:04"), ago(before.FetchedAt))
+		if d.Empty() {
+			color.New(color.FgWhite, color.Faint).Println("  Nothing changed.")
+			return nil
+		}
+		printDiffGroup("+", "Added", color.FgGreen, d.Added)
+		printDiffGroup("✓", "Completed", color.FgGreen, d.Completed)
+		printDiffGroup("↺", "Reopened", color.FgYellow, d.Reopened)
+		printDiffGroup("~", "Changed", color.FgCyan, d.Changed)
+		printDiffGroup("-", "Removed", color.FgRed, d.Removed)
+		return nil
+	},
+}
+
+func printDiffGroup(mark, title string, c color.Attribute, tasks []storage.Task) {
+	if len(tasks) == 0 {
+		return
+	}
+	color.New(c, color.Bold).Printf("  %s (%d)\n", title, len(tasks))
+	for _, t := range tasks {
+		color.New(c).Printf("   %s %3d ", mark, t.ID)
+		color.New(color.FgWhite).Println(t.Content)
+	}
+	fmt.Println()
+}
+
+func init() {
+	diffCmd.Flags().StringVar(&friendToken, "token", "", "token issued by your friend")
+	diffCmd.Flags().IntVar(&friendPort, "port", network.DefaultPort, "port to use when the address has none")
+	friendCmd.AddCommand(diffCmd)
+}
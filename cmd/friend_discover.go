@@ -0,0 +1,123 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/discovery"
+	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var discoverTimeout time.Duration
+var serveName string
+var serveNoAdvertise bool
+
+var discoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Find friend servers on the local network",
+	Long: `Find friend servers on the local network. Friend servers advertise
+themselves with multicast DNS (like printers and AirPlay speakers), so
+friends on the same LAN show up here without swapping IP addresses.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		services, err := discovery.Browse(discoverTimeout)
+		if err != nil {
+			return err
+		}
+		if len(services) == 0 {
+			color.New(color.FgYellow).Println("No friend servers found on the local network.")
+			return nil
+		}
+
+		saved := map[string]string{}
+		if book, err := addressBook(); err == nil {
+			if friends, err := book.List(); err == nil {
+				for _, f := range friends {
+					saved[f.Addr] = f.Name
+				}
+			}
+		}
+
+		color.New(color.FgBlue, color.Bold).Printf("Found %d friend server(s):\n", len(services))
+		for _, s := range services {
+			color.New(color.FgWhite, color.Bold).Printf("  %-24s ", s.Name)
+			color.New(color.FgCyan).Printf("%-22s ", s.Addr())
+			if s.Version == network.ProtocolVersion {
+				color.New(color.FgGreen).Printf("protocol v%d", s.Version)
+			} else {
+				color.New(color.FgYellow).Printf("protocol v%d (this gotodo speaks v%d)", s.Version, network.ProtocolVersion)
+			}
+			if name, ok := saved[s.Addr()]; ok {
+				color.New(color.FgWhite, color.Faint).Printf("  saved as %s", name)
+			}
+			fmt.Println()
+			if s.Fingerprint != "" {
+				color.New(color.FgWhite, color.Faint).Printf("  %-24s %s\n", "", s.Fingerprint)
+			}
+		}
+		color.New(color.FgWhite, color.Faint).Println("\nSave one with 'gotodo friend add <name> <address>'.")
+		return nil
+	},
+}
+
+// advertise the friend server listening on addr, nil when it is not
+// reachable from the network
+func advertiseServer(addr string, cert tls.Certificate) (*discovery.Advertiser, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	svc := discovery.Service{
+		Name:        serveName,
+		Version:     network.ProtocolVersion,
+		Fingerprint: network.Fingerprint(cert.Certificate[0]),
+	}
+	svc.Port, _ = strconv.Atoi(port)
+	if ip := net.ParseIP(host); ip != nil {
+		switch {
+		case ip.IsLoopback():
+			return nil, nil
+		case ip.IsUnspecified():
+			// all interfaces
+		case ip.To4() != nil:
+			svc.IPs = []net.IP{ip}
+		default:
+			return nil, fmt.Errorf("only IPv4 addresses can be advertised")
+		}
+	}
+	if svc.Name == "" {
+		svc.Name = defaultServeName()
+	}
+	return discovery.Advertise(svc)
+}
+
+// e.g. "ethan on laptop"
+func defaultServeName() string {
+	host, _ := os.Hostname()
+	host, _, _ = strings.Cut(host, ".")
+	if usr, err := user.Current(); err == nil && usr.Username != "" {
+		if host == "" {
+			return usr.Username
+		}
+		return usr.Username + " on " + host
+	}
+	return host
+}
+
+func init() {
+	discoverCmd.Flags().DurationVar(&discoverTimeout, "timeout", 2*time.Second, "how long to wait for answers")
+	serveCmd.Flags().StringVar(&serveName, "name", "", "name shown to friends running 'gotodo friend discover'")
+	serveCmd.Flags().BoolVar(&serveNoAdvertise, "no-advertise", false, "do not announce the server on the local network")
+	friendCmd.AddCommand(discoverCmd)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var logLevel string
+var logFormat string
+var logFile string
+var accessLogFile string
+
+// the server and access loggers configured with the --log-* flags; close
+// releases the log files
+func serverLoggers() (logger, access *slog.Logger, close func(), err error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid --log-level %q (want debug, info, warn or error)", logLevel)
+	}
+	format := strings.ToLower(logFormat)
+	if format != "text" && format != "json" {
+		return nil, nil, nil, fmt.Errorf("invalid --log-format %q (want text or json)", logFormat)
+	}
+
+	var files []*os.File
+	close = func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	open := func(path string) (io.Writer, error) {
+		if path == "" || path == "-" {
+			return os.Stderr, nil
+		}
+		// logs name friends and their addresses
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %v", err)
+		}
+		files = append(files, f)
+		return f, nil
+	}
+	newLogger := func(w io.Writer) *slog.Logger {
+		opts := &slog.HandlerOptions{Level: level}
+		if format == "json" {
+			return slog.New(slog.NewJSONHandler(w, opts))
+		}
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+
+	w, err := open(logFile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	logger = newLogger(w)
+	access = logger
+	if accessLogFile != "" {
+		w, err := open(accessLogFile)
+		if err != nil {
+			close()
+			return nil, nil, nil, err
+		}
+		access = newLogger(w)
+	}
+	return logger, access, close, nil
+}
+
+// register the --log-* flags on a server command
+func addLogFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&logLevel, "log-level", "info", "log level: debug, info, warn or error")
+	cmd.Flags().StringVar(&logFormat, "log-format", "text", "log format: text or json")
+	cmd.Flags().StringVar(&logFile, "log-file", "", "append logs to this file instead of stderr")
+	cmd.Flags().StringVar(&accessLogFile, "access-log", "", "write the per-request access log to this file instead of the log")
+}
@@ -0,0 +1,151 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sendTags      []string
+	sendPriority  string
+	sendProject   string
+	sendDue       string
+	sendScheduled string
+)
+
+var friendSendCmd = &cobra.Command{
+	Use:   "send <name|address> <task>",
+	Short: "Add a task to a friend's list",
+	Long: `Add a task to a friend's list. The task is tagged with your name on
+their side, and may wait in their inbox until they accept it.
+Your friend has to allow it with 'gotodo friend share set <you> --allow add'.`,
+	Example: `  gotodo friend send alice "Review the release notes" -t release --due friday`,
+	Args:    cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		due := ""
+		if sendDue != "" {
+			var err error
+			if due, err = parseDateArg(sendDue, time.Now()); err != nil {
+				return err
+			}
+		}
+		scheduled := ""
+		if sendScheduled != "" {
+			var err error
+			if scheduled, err = parseDateArg(sendScheduled, time.Now()); err != nil {
+				return err
+			}
+		}
+		nt := storage.Task{
+			Content:   strings.Join(args[1:], " "),
+			Tags:      sendTags,
+			Priority:  sendPriority,
+			Project:   sendProject,
+			Due:       due,
+			Scheduled: scheduled,
+		}
+		if err := storage.ValidateTask(nt); err != nil {
+			return err
+		}
+		return withFriend(cmd, args[0], func(c *network.Client, target friendTarget) error {
+			res, err := c.AddTask(nt)
+			if err != nil {
+				return err
+			}
+			what := fmt.Sprintf("Added %q", nt.Content)
+			if res.Task != nil {
+				what = fmt.Sprintf("Added [%d] %s", res.Task.ID, res.Task.Content)
+			}
+			printChangeResult(target, res, what)
+			return nil
+		})
+	},
+}
+
+var friendDoneCmd = &cobra.Command{
+	Use:   "done <name|address> <id>",
+	Short: "Mark a task on a friend's list as done",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+		return withFriend(cmd, args[0], func(c *network.Client, target friendTarget) error {
+			res, err := c.SetDone(id)
+			if err != nil {
+				return err
+			}
+			printChangeResult(target, res, fmt.Sprintf("Marked task %d as done", id))
+			return nil
+		})
+	},
+}
+
+var friendCommentCmd = &cobra.Command{
+	Use:   "comment <name|address> <id> <text>",
+	Short: "Comment on a task on a friend's list",
+	Args:  cobra.MinimumNArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+		text := strings.Join(args[2:], " ")
+		return withFriend(cmd, args[0], func(c *network.Client, target friendTarget) error {
+			res, err := c.Comment(id, text)
+			if err != nil {
+				return err
+			}
+			printChangeResult(target, res, fmt.Sprintf("Commented on task %d", id))
+			return nil
+		})
+	},
+}
+
+// connect to a friend given by name or address, --token and --port
+// apply, and run fn with the connection
+func withFriend(cmd *cobra.Command, nameOrAddr string, fn func(*network.Client, friendTarget) error) error {
+	target, err := resolveFriend(cmd, nameOrAddr, friendToken)
+	if err != nil {
+		return err
+	}
+	c, err := network.Dial(target.Addr, target.Opts)
+	if err != nil {
+		return target.dialError(err)
+	}
+	defer c.Close()
+	target.seen()
+	return fn(c, target)
+}
+
+func printChangeResult(target friendTarget, res network.ChangeResult, what string) {
+	if res.Queued {
+		color.New(color.FgYellow).Printf("✓ Sent to %s, waiting for their approval\n", target.Label)
+		return
+	}
+	color.New(color.FgGreen).Printf("✓ %s on %s's list\n", what, target.Label)
+}
+
+func init() {
+	friendSendCmd.Flags().StringSliceVarP(&sendTags, "tag", "t", nil, "tag the task (repeatable or comma separated)")
+	friendSendCmd.Flags().StringVarP(&sendPriority, "priority", "p", "", "task priority: high, medium or low")
+	friendSendCmd.Flags().StringVar(&sendProject, "project", "", "project the task belongs to")
+	friendSendCmd.Flags().StringVar(&sendDue, "due", "", "due date: YYYY-MM-DD, today, tomorrow, a weekday or +Nd")
+	friendSendCmd.Flags().StringVar(&sendScheduled, "scheduled", "", "day to start on the task, same forms as --due")
+	for _, c := range []*cobra.Command{friendSendCmd, friendDoneCmd, friendCommentCmd} {
+		c.Flags().StringVar(&friendToken, "token", "", "token issued by your friend")
+		c.Flags().IntVar(&friendPort, "port", network.DefaultPort, "port to use when the address has none")
+		friendCmd.AddCommand(c)
+	}
+}
@@ -0,0 +1,233 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	shareDefault     bool
+	shareTags        []string
+	shareProjects    []string
+	shareExcludeTags []string
+	shareRedact      bool
+	shareHide        []string
+	shareAllow       []string
+	shareReview      bool
+)
+
+var shareCmd = &cobra.Command{
+	Use:   "share",
+	Short: "Choose which tasks each friend can see",
+	Long: `Choose which tasks each friend can see.
+
+Rules are set per friend, by the name their token was created with,
+and also decide which changes the friend may send (--allow).
+Friends without a rule of their own get the default rule (--default),
+and without any rule the whole list is shared. Rules are checked by
+'gotodo friend serve' on every request, so changes apply right away.`,
+}
+
+var shareSetCmd = &cobra.Command{
+	Use:   "set [friend]",
+	Short: "Set the sharing rule of a friend, or the default rule",
+	Example: `  gotodo friend share set alice --tag team --project website
+  gotodo friend share set --default --exclude-tag private --hide dates
+  gotodo friend share set bob --redact
+  gotodo friend share set carol --allow add,comment --review`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, err := shareRuleName(args)
+		if err != nil {
+			return err
+		}
+		store, err := friendShareStore()
+		if err != nil {
+			return err
+		}
+		rule := network.ShareRule{
+			Tags:        shareTags,
+			Projects:    shareProjects,
+			ExcludeTags: shareExcludeTags,
+			Redact:      shareRedact,
+			Hide:        shareHide,
+			Allow:       shareAllow,
+			Review:      shareReview,
+		}
+		if err := store.Set(name, rule); err != nil {
+			return err
+		}
+		color.New(color.FgGreen).Printf("✓ Rule for %s: %s\n", shareLabel(name), describeShareRule(rule))
+		return nil
+	},
+}
+
+var shareListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List sharing rules",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := friendShareStore()
+		if err != nil {
+			return err
+		}
+		rules, err := store.Rules()
+		if err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			color.New(color.FgYellow).Println("No sharing rules, friends see your whole list.")
+			return nil
+		}
+		for _, name := range network.SortedRuleNames(rules) {
+			color.New(color.FgWhite, color.Bold).Printf("  %-16s", shareLabel(name))
+			color.New(color.FgWhite).Printf(" %s\n", describeShareRule(rules[name]))
+		}
+		return nil
+	},
+}
+
+var shareRemoveCmd = &cobra.Command{
+	Use:   "remove [friend]",
+	Short: "Remove the sharing rule of a friend, or the default rule",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, err := shareRuleName(args)
+		if err != nil {
+			return err
+		}
+		store, err := friendShareStore()
+		if err != nil {
+			return err
+		}
+		if err := store.Remove(name); err != nil {
+			return err
+		}
+		color.New(color.FgGreen).Printf("✓ Removed the rule for %s\n", shareLabel(name))
+		return nil
+	},
+}
+
+var sharePreviewCmd = &cobra.Command{
+	Use:   "preview <friend>",
+	Short: "Show the tasks a friend would see",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := friendShareStore()
+		if err != nil {
+			return err
+		}
+		rule, err := store.RuleFor(args[0])
+		if err != nil {
+			return err
+		}
+		tasks, err := storage.List()
+		if err != nil {
+			return fmt.Errorf("failed to load tasks: %v", err)
+		}
+		shared := rule.Filter(tasks)
+		color.New(color.FgBlue, color.Bold).Printf("%s sees %d of %d tasks\n", args[0], len(shared), len(tasks))
+		for _, t := range shared {
+			status := "[ ]"
+			if t.Done {
+				status = "[✓]"
+			}
+			color.New(color.FgWhite).Printf(" %s %3d %s", status, t.ID, t.Content)
+			if meta := taskMeta(t); meta != "" {
+				color.New(color.FgCyan, color.Faint).Printf("  %s", meta)
+			}
+			fmt.Println()
+		}
+		return nil
+	},
+}
+
+// the rule name given as argument or with --default
+func shareRuleName(args []string) (string, error) {
+	switch {
+	case shareDefault && len(args) == 1:
+		return "", fmt.Errorf("give either a friend or --default, not both")
+	case shareDefault:
+		return network.DefaultShareRule, nil
+	case len(args) == 1:
+		return args[0], nil
+	default:
+		return "", fmt.Errorf("no friend given, use --default for the default rule")
+	}
+}
+
+func shareLabel(name string) string {
+	if name == network.DefaultShareRule {
+		return "everyone else"
+	}
+	return name
+}
+
+// one line summary of a rule, e.g. "tasks in #team, content redacted"
+func describeShareRule(r network.ShareRule) string {
+	var parts []string
+	var only []string
+	for _, tag := range r.Tags {
+		only = append(only, "#"+tag)
+	}
+	for _, p := range r.Projects {
+		only = append(only, "@"+p)
+	}
+	if len(only) == 0 {
+		parts = append(parts, "all tasks")
+	} else {
+		parts = append(parts, "tasks in "+strings.Join(only, ", "))
+	}
+	if len(r.ExcludeTags) > 0 {
+		parts = append(parts, "except #"+strings.Join(r.ExcludeTags, ", #"))
+	}
+	if r.Redact {
+		parts = append(parts, "content redacted")
+	}
+	if len(r.Hide) > 0 {
+		parts = append(parts, "hiding "+strings.Join(r.Hide, ", "))
+	}
+	if len(r.Allow) > 0 {
+		allowed := "may " + strings.Join(r.Allow, ", ")
+		if r.Review {
+			allowed += " (after approval)"
+		}
+		parts = append(parts, allowed)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sharing rules, kept in ~/.gotodo/friend_shares.json
+func friendShareStore() (*network.ShareStore, error) {
+	path, err := gotodoFile("friend_shares.json")
+	if err != nil {
+		return nil, err
+	}
+	return network.NewShareStore(path), nil
+}
+
+func init() {
+	for _, c := range []*cobra.Command{shareSetCmd, shareRemoveCmd} {
+		c.Flags().BoolVar(&shareDefault, "default", false, "the rule for friends without their own")
+	}
+	shareSetCmd.Flags().StringSliceVar(&shareTags, "tag", nil, "share only tasks with one of these tags")
+	shareSetCmd.Flags().StringSliceVar(&shareProjects, "project", nil, "share only tasks in one of these projects")
+	shareSetCmd.Flags().StringSliceVar(&shareExcludeTags, "exclude-tag", nil, "never share tasks with these tags")
+	shareSetCmd.Flags().BoolVar(&shareRedact, "redact", false, "hide the content and comments of shared tasks")
+	shareSetCmd.Flags().StringSliceVar(&shareHide, "hide", nil, "fields to hide: "+strings.Join(network.HideableFields, ", "))
+	shareSetCmd.Flags().StringSliceVar(&shareAllow, "allow", nil, "changes the friend may send: "+strings.Join(network.Permissions, ", "))
+	shareSetCmd.Flags().BoolVar(&shareReview, "review", false, "hold changes in 'gotodo inbox' for approval")
+	shareCmd.AddCommand(shareSetCmd)
+	shareCmd.AddCommand(shareListCmd)
+	shareCmd.AddCommand(shareRemoveCmd)
+	shareCmd.AddCommand(sharePreviewCmd)
+	friendCmd.AddCommand(shareCmd)
+}
@@ -0,0 +1,103 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var tokenCmd = newTokenCmd(friendTokenStore, "friend server", `Manage the tokens friends use to connect to your server.
+
+Once any token exists, 'gotodo friend serve' only accepts friends that
+present a valid one with 'gotodo friend connect --token ...'.`)
+
+// token create/revoke/list commands for the tokens in store, server names
+// the server they grant access to in messages
+func newTokenCmd(store func() (*network.TokenStore, error), server, long string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Manage the tokens clients use to connect to your " + server,
+		Long:  long,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "create <name>",
+		Short: "Create (or replace) a token",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ts, err := store()
+			if err != nil {
+				return err
+			}
+			token, err := ts.Create(args[0])
+			if err != nil {
+				return err
+			}
+			color.New(color.FgGreen).Printf("✓ Token for %s:\n", args[0])
+			fmt.Printf("  %s\n", token)
+			color.New(color.FgYellow).Println("Copy it now, it cannot be shown again.")
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "revoke <name>",
+		Short: "Revoke a token",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ts, err := store()
+			if err != nil {
+				return err
+			}
+			if err := ts.Revoke(args[0]); err != nil {
+				return err
+			}
+			color.New(color.FgGreen).Printf("✓ Token for %s revoked\n", args[0])
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List issued tokens",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ts, err := store()
+			if err != nil {
+				return err
+			}
+			tokens, err := ts.List()
+			if err != nil {
+				return err
+			}
+			if len(tokens) == 0 {
+				color.New(color.FgYellow).Printf("No tokens issued, your %s is open to anyone who can reach it.\n", server)
+				return nil
+			}
+			for _, t := range tokens {
+				color.New(color.FgWhite).Printf("  %-16s", t.Name)
+				color.New(color.FgCyan, color.Faint).Printf("  created %s\n", t.CreatedAt)
+			}
+			return nil
+		},
+	})
+	return cmd
+}
+
+// tokens issued by this user, kept in ~/.gotodo/friend_tokens.json
+func friendTokenStore() (*network.TokenStore, error) {
+	path, err := gotodoFile("friend_tokens.json")
+	if err != nil {
+		return nil, err
+	}
+	return network.NewTokenStore(path), nil
+}
+
+func init() {
+	friendCmd.AddCommand(tokenCmd)
+}
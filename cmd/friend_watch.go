@@ -0,0 +1,116 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// how long to wait before reconnecting after the friend went away
+const watchRetry = 5 * time.Second
+
+// number of recent changes shown under the list
+const watchRecent = 5
+
+var watchCmd = &cobra.Command{
+	Use:   "watch <name|address>",
+	Short: "Watch a friend's todo list change live",
+	Long: `Watch a friend's todo list change live. The list is redrawn whenever
+your friend adds, completes, changes or deletes a task, and the connection
+is retried when it drops. Stop with Ctrl-C.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target, err := resolveFriend(cmd, args[0], friendToken)
+		if err != nil {
+			return err
+		}
+		var recent []storage.Event
+		for {
+			err := watchFriend(target, &recent)
+			// retrying cannot fix refusals
+			if !unreachable(err) {
+				return target.dialError(err)
+			}
+			color.New(color.FgYellow).Printf("%v, retrying in %s\n", err, watchRetry)
+			time.Sleep(watchRetry)
+		}
+	},
+}
+
+// show the friend's list and redraw it on every change until the
+// connection fails
+func watchFriend(target friendTarget, recent *[]storage.Event) error {
+	c, err := network.Dial(target.Addr, target.Opts)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	tasks, err := c.Subscribe()
+	if err != nil {
+		return err
+	}
+	target.seen()
+
+	for {
+		drawWatch(target.Label, tasks, *recent)
+		e, err := c.NextEvent()
+		if err != nil {
+			return err
+		}
+		tasks = applyEvent(tasks, e)
+		*recent = append(*recent, e)
+		if len(*recent) > watchRecent {
+			*recent = (*recent)[len(*recent)-watchRecent:]
+		}
+	}
+}
+
+// the task list after a change, events carry the task after the change
+func applyEvent(tasks []storage.Task, e storage.Event) []storage.Task {
+	for i, t := range tasks {
+		if t.ID != e.Task.ID {
+			continue
+		}
+		if e.Action == storage.ActionDelete {
+			return append(tasks[:i:i], tasks[i+1:]...)
+		}
+		tasks[i] = e.Task
+		return tasks
+	}
+	if e.Action == storage.ActionDelete {
+		return tasks
+	}
+	return append(tasks, e.Task)
+}
+
+func drawWatch(label string, tasks []storage.Task, recent []storage.Event) {
+	// clear the screen and move to the top left
+	fmt.Print("\033[H\033[2J")
+	network.PrintTasks(label, tasks)
+	if len(recent) > 0 {
+		fmt.Println()
+		color.New(color.FgBlue, color.Bold).Println(" Recent changes")
+		for _, e := range recent {
+			when := ""
+			if at, err := storage.ParseTime(e.Time); err == nil {
+				when = at.Format("15:04:05")
+			}
+			color.New(color.FgCyan, color.Faint).Printf("  %8s ", when)
+			color.New(color.FgWhite).Printf("%-8s %s\n", e.Action, e.Task.Content)
+		}
+	}
+	color.New(color.FgWhite, color.Faint).Printf("\n Watching %s, Ctrl-C to stop\n", label)
+}
+
+func init() {
+	watchCmd.Flags().StringVar(&friendToken, "token", "", "token issued by your friend")
+	watchCmd.Flags().IntVar(&friendPort, "port", network.DefaultPort, "port to use when the address has none")
+	friendCmd.AddCommand(watchCmd)
+}
@@ -0,0 +1,328 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/hosted"
+	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/ethanbao27/gotodo/internal/ui"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// default address of a hosted server, local only
+const defaultHostListen = "127.0.0.1:8090"
+
+var hostDir string
+var hostListen string
+var hostCertFile string
+var hostKeyFile string
+
+var hostCmd = &cobra.Command{
+	Use:   "host",
+	Short: "Host todo lists for a team on one server",
+	Long: `Host todo lists for a team on one server. Every user has a list of their
+own, shared projects have a list all their members can change. Team members
+use it from their own gotodo with 'gotodo remote login'.
+
+Accounts and lists are kept in --dir (default ~/.gotodo/host).`,
+}
+
+var hostServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Start the hosted server",
+	Long: `Start the hosted server. Passwords and tokens travel with every request,
+so outside of a trusted network serve over HTTPS with --cert and --key, or
+behind a reverse proxy that terminates TLS.`,
+	Example: `  gotodo host serve --listen 0.0.0.0:8090 --cert cert.pem --key key.pem`,
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addr, err := network.NormalizeAddr(hostListen, 8090, true)
+		if err != nil {
+			return err
+		}
+		if (hostCertFile == "") != (hostKeyFile == "") {
+			return fmt.Errorf("give both --cert and --key to serve over HTTPS")
+		}
+		accounts, err := hostAccounts()
+		if err != nil {
+			return err
+		}
+		logger, access, closeLogs, err := serverLoggers()
+		if err != nil {
+			return err
+		}
+		defer closeLogs()
+
+		srv := &http.Server{
+			Addr:              addr,
+			Handler:           hosted.NewHandler(hosted.Config{Accounts: accounts, Logger: logger, AccessLog: access}),
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      30 * time.Second,
+		}
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %v", addr, err)
+		}
+		scheme := "http"
+		if hostCertFile != "" {
+			scheme = "https"
+		}
+		color.New(color.FgBlue, color.Bold).Printf("Hosting todo lists on %s://%s (accounts in %s)\n", scheme, ln.Addr(), accounts.Dir())
+		if users, err := accounts.Users(); err == nil && len(users) == 0 {
+			color.New(color.FgYellow).Println("No users yet, add one with 'gotodo host user add <name>'.")
+		}
+		if host, _, _ := net.SplitHostPort(addr); scheme == "http" && !isLoopback(host) {
+			color.New(color.FgYellow).Println("Serving plain HTTP beyond this machine, passwords can be read on the network. Use --cert and --key.")
+		}
+
+		errc := make(chan error, 1)
+		go func() {
+			if hostCertFile != "" {
+				errc <- srv.ServeTLS(ln, hostCertFile, hostKeyFile)
+			} else {
+				errc <- srv.Serve(ln)
+			}
+		}()
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		select {
+		case err := <-errc:
+			return fmt.Errorf("hosted server error: %v", err)
+		case <-ctx.Done():
+		}
+		stop()
+		color.New(color.FgBlue).Println("\nShutting down, finishing requests in progress...")
+		ctx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		return srv.Shutdown(ctx)
+	},
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+var hostUserCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage the users of the hosted server",
+}
+
+var hostUserAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add a user, asking for their password",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		accounts, err := hostAccounts()
+		if err != nil {
+			return err
+		}
+		if err := hosted.ValidateName(args[0]); err != nil {
+			return err
+		}
+		password, err := newPassword()
+		if err != nil {
+			return err
+		}
+		if err := accounts.AddUser(args[0], password); err != nil {
+			return err
+		}
+		color.New(color.FgGreen).Printf("✓ User %s added\n", args[0])
+		return nil
+	},
+}
+
+var hostUserPasswdCmd = &cobra.Command{
+	Use:   "passwd <name>",
+	Short: "Change a user's password and log out their devices",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		accounts, err := hostAccounts()
+		if err != nil {
+			return err
+		}
+		password, err := newPassword()
+		if err != nil {
+			return err
+		}
+		if err := accounts.SetPassword(args[0], password); err != nil {
+			return err
+		}
+		color.New(color.FgGreen).Printf("✓ Password of %s changed\n", args[0])
+		return nil
+	},
+}
+
+var hostUserRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a user, their list stays on disk",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		accounts, err := hostAccounts()
+		if err != nil {
+			return err
+		}
+		if err := accounts.RemoveUser(args[0]); err != nil {
+			return err
+		}
+		color.New(color.FgGreen).Printf("✓ User %s removed, their list is kept in %s\n", args[0], accounts.UserPath(args[0]))
+		return nil
+	},
+}
+
+var hostUserListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List users",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		accounts, err := hostAccounts()
+		if err != nil {
+			return err
+		}
+		users, err := accounts.Users()
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			color.New(color.FgYellow).Println("No users, add one with 'gotodo host user add <name>'")
+			return nil
+		}
+		for _, u := range users {
+			projects, err := accounts.ProjectsOf(u.Name)
+			if err != nil {
+				return err
+			}
+			color.New(color.FgWhite, color.Bold).Printf("  %-16s", u.Name)
+			color.New(color.FgCyan).Printf(" %s", strings.Join(projects, ", "))
+			fmt.Println()
+		}
+		return nil
+	},
+}
+
+var hostProjectCmd = &cobra.Command{
+	Use:   "project",
+	Short: "Manage shared projects",
+}
+
+var hostProjectSetCmd = &cobra.Command{
+	Use:   "set <project> <member>...",
+	Short: "Create a shared project or replace its members",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		accounts, err := hostAccounts()
+		if err != nil {
+			return err
+		}
+		if err := accounts.SetProject(args[0], args[1:]); err != nil {
+			return err
+		}
+		color.New(color.FgGreen).Printf("✓ Project %s shared by %s\n", args[0], strings.Join(args[1:], ", "))
+		return nil
+	},
+}
+
+var hostProjectRemoveCmd = &cobra.Command{
+	Use:   "remove <project>",
+	Short: "Remove a shared project, its list stays on disk",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		accounts, err := hostAccounts()
+		if err != nil {
+			return err
+		}
+		if err := accounts.RemoveProject(args[0]); err != nil {
+			return err
+		}
+		color.New(color.FgGreen).Printf("✓ Project %s removed\n", args[0])
+		return nil
+	},
+}
+
+var hostProjectListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List shared projects and their members",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		accounts, err := hostAccounts()
+		if err != nil {
+			return err
+		}
+		projects, err := accounts.Projects()
+		if err != nil {
+			return err
+		}
+		if len(projects) == 0 {
+			color.New(color.FgYellow).Println("No shared projects, create one with 'gotodo host project set <project> <member>...'")
+			return nil
+		}
+		for _, p := range projects {
+			color.New(color.FgWhite, color.Bold).Printf("  %-16s", p.Name)
+			color.New(color.FgCyan).Printf(" %s\n", strings.Join(p.Members, ", "))
+		}
+		return nil
+	},
+}
+
+// the accounts in --dir, ~/.gotodo/host by default
+func hostAccounts() (*hosted.Accounts, error) {
+	dir := hostDir
+	if dir == "" {
+		var err error
+		if dir, err = gotodoFile("host"); err != nil {
+			return nil, err
+		}
+	}
+	return hosted.NewAccounts(dir), nil
+}
+
+// ask for a new password twice
+func newPassword() (string, error) {
+	password, err := ui.ReadPassword("Password: ")
+	if err != nil {
+		return "", err
+	}
+	if len(password) < hosted.MinPasswordLength {
+		return "", fmt.Errorf("password is too short (at least %d characters)", hosted.MinPasswordLength)
+	}
+	again, err := ui.ReadPassword("Repeat password: ")
+	if err != nil {
+		return "", err
+	}
+	if again != password {
+		return "", fmt.Errorf("passwords do not match")
+	}
+	return password, nil
+}
+
+func init() {
+	hostCmd.PersistentFlags().StringVar(&hostDir, "dir", "", "directory of accounts and lists (default ~/.gotodo/host)")
+
+	hostServeCmd.Flags().StringVar(&hostListen, "listen", defaultHostListen, "address to listen on, e.g. 0.0.0.0:8090")
+	hostServeCmd.Flags().StringVar(&hostCertFile, "cert", "", "TLS certificate file, serve HTTPS")
+	hostServeCmd.Flags().StringVar(&hostKeyFile, "key", "", "TLS key file")
+	addLogFlags(hostServeCmd)
+	hostCmd.AddCommand(hostServeCmd)
+
+	hostUserCmd.AddCommand(hostUserAddCmd, hostUserPasswdCmd, hostUserRemoveCmd, hostUserListCmd)
+	hostCmd.AddCommand(hostUserCmd)
+	hostProjectCmd.AddCommand(hostProjectSetCmd, hostProjectRemoveCmd, hostProjectListCmd)
+	hostCmd.AddCommand(hostProjectCmd)
+	rootCmd.AddCommand(hostCmd)
+}
@@ -0,0 +1,146 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var inboxAll bool
+
+var inboxCmd = &cobra.Command{
+	Use:   "inbox",
+	Short: "Show changes from friends waiting for approval",
+	Long: `Show changes from friends waiting for approval.
+
+Friends whose sharing rule has --review can still send tasks, completions
+and comments, but they wait here until you accept or reject them.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		items, err := storage.Inbox()
+		if err != nil {
+			return fmt.Errorf("failed to load inbox: %v", err)
+		}
+		if len(items) == 0 {
+			color.New(color.FgGreen).Println("Inbox is empty.")
+			return nil
+		}
+		for _, it := range items {
+			color.New(color.FgWhite, color.Bold).Printf(" %3d ", it.ID)
+			color.New(color.FgCyan).Printf("%-10s ", it.From)
+			fmt.Println(describeInboxItem(it))
+		}
+		color.New(color.FgWhite, color.Faint).Println("\nAccept with 'gotodo inbox accept <id>', reject with 'gotodo inbox reject <id>'.")
+		return nil
+	},
+}
+
+var inboxAcceptCmd = &cobra.Command{
+	Use:   "accept [id...]",
+	Short: "Apply changes from the inbox",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ids, err := inboxIDs(args)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			t, err := storage.AcceptInbox(id)
+			if err != nil {
+				return err
+			}
+			color.New(color.FgGreen).Printf("✓ Accepted %d: [%d] %s\n", id, t.ID, t.Content)
+		}
+		return nil
+	},
+}
+
+var inboxRejectCmd = &cobra.Command{
+	Use:   "reject [id...]",
+	Short: "Drop changes from the inbox",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ids, err := inboxIDs(args)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			it, err := storage.RejectInbox(id)
+			if err != nil {
+				return err
+			}
+			color.New(color.FgYellow).Printf("✗ Rejected %d from %s\n", id, it.From)
+		}
+		return nil
+	},
+}
+
+// the item IDs given as arguments, or all of them with --all
+func inboxIDs(args []string) ([]int, error) {
+	if inboxAll {
+		if len(args) > 0 {
+			return nil, fmt.Errorf("give either IDs or --all, not both")
+		}
+		items, err := storage.Inbox()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load inbox: %v", err)
+		}
+		ids := make([]int, len(items))
+		for i, it := range items {
+			ids[i] = it.ID
+		}
+		return ids, nil
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no inbox item given, use --all for every item")
+	}
+	ids := make([]int, len(args))
+	for i, a := range args {
+		id, err := strconv.Atoi(a)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func describeInboxItem(it storage.InboxItem) string {
+	switch it.Action {
+	case storage.ActionAdd:
+		if it.Task == nil {
+			return "add (empty)"
+		}
+		s := "add " + it.Task.Content
+		if meta := taskMeta(*it.Task); meta != "" {
+			s += "  " + meta
+		}
+		return s
+	case storage.ActionDone:
+		return fmt.Sprintf("done task %d%s", it.TaskID, inboxTaskTitle(it.TaskID))
+	case storage.ActionComment:
+		return fmt.Sprintf("comment on task %d%s: %s", it.TaskID, inboxTaskTitle(it.TaskID), it.Text)
+	default:
+		return it.Action
+	}
+}
+
+func inboxTaskTitle(id int) string {
+	t, err := storage.Get(id)
+	if err != nil {
+		return " (deleted)"
+	}
+	return fmt.Sprintf(" (%s)", t.Content)
+}
+
+func init() {
+	inboxAcceptCmd.Flags().BoolVar(&inboxAll, "all", false, "accept every item")
+	inboxRejectCmd.Flags().BoolVar(&inboxAll, "all", false, "reject every item")
+	inboxCmd.AddCommand(inboxAcceptCmd)
+	inboxCmd.AddCommand(inboxRejectCmd)
+	rootCmd.AddCommand(inboxCmd)
+}
@@ -6,7 +6,6 @@ package cmd
 import (
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/ethanbao27/gotodo/internal/storage"
 	"github.com/ethanbao27/gotodo/internal/ui"
@@ -64,9 +63,7 @@ var listCmd = &cobra.Command{
 			// Parse and format creation time
 			createdAt := "Unknown"
 			if t.CreatedAt != "" {
-				if parsedTime, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", t.CreatedAt); err == nil {
-					createdAt = parsedTime.Format("Jan 02 15:04")
-				} else if parsedTime, err := time.Parse("2006-01-02 15:04:05 -0700 MST", t.CreatedAt); err == nil {
+				if parsedTime, ok := t.Created(); ok {
 					createdAt = parsedTime.Format("Jan 02 15:04")
 				} else {
 					createdAt = strings.Split(t.CreatedAt, ".")[0]
@@ -90,6 +87,9 @@ var listCmd = &cobra.Command{
 			// Print task with minimal styling
 			color.New(statusColor).Printf(" %s %3d ", statusIcon, t.ID)
 			color.New(color.FgWhite).Print(t.Content)
+			if meta := taskMeta(t); meta != "" {
+				color.New(color.FgMagenta, color.Faint).Printf("  %s", meta)
+			}
 			color.New(color.FgCyan, color.Faint).Printf("  %s\n", createdAt)
 		}
 
@@ -99,6 +99,33 @@ var listCmd = &cobra.Command{
 	},
 }
 
+// short form of a task's tags, project, priority and dates,
+// e.g. "#ops @infra !high scheduled 2026-10-30 due 2026-11-02"
+func taskMeta(t storage.Task) string {
+	var parts []string
+	for _, tag := range t.Tags {
+		parts = append(parts, "#"+tag)
+	}
+	if t.Project != "" {
+		parts = append(parts, "@"+t.Project)
+	}
+	if t.Priority != "" {
+		parts = append(parts, "!"+t.Priority)
+	}
+	if t.Scheduled != "" {
+		parts = append(parts, "scheduled "+t.Scheduled)
+	}
+	if t.Due != "" {
+		parts = append(parts, "due "+t.Due)
+	}
+	if n := len(t.Comments); n == 1 {
+		parts = append(parts, "1 comment")
+	} else if n > 1 {
+		parts = append(parts, fmt.Sprintf("%d comments", n))
+	}
+	return strings.Join(parts, " ")
+}
+
 func init() {
 	listCmd.Flags().BoolVar(&onlyDone, "done", false, "show done only")
 	listCmd.Flags().BoolVar(&onlyUndone, "undone", false, "show undone only")
@@ -0,0 +1,403 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ethanbao27/gotodo/internal/hosted"
+	"github.com/ethanbao27/gotodo/internal/ui"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// config key naming the remote the task commands work on
+const remoteConfigKey = "remote"
+
+var remoteUser string
+var remoteProject string
+
+var remoteCmd = &cobra.Command{
+	Use:   "remote",
+	Short: "Use a todo list on a hosted server",
+	Long: `Use a todo list on a server started with 'gotodo host serve'.
+
+After 'gotodo remote login', add, list, done, delete, clear, due, schedule,
+comment, board, agenda, calendar and shell work on the list on the server
+instead of the local file, until 'gotodo remote use none'. --remote <name>
+picks a saved remote for one command, --remote none the local file.
+Statistics, reports, the inbox, sync and friend mode keep using the local file.
+
+A copy of the list is kept in ~/.gotodo/remotes. While the server cannot be
+reached, the task commands work on the copy and queue their changes, which
+are sent by the first command that reaches the server again. Tasks added
+offline have negative IDs until then (gotodo done -- -1). Changes to tasks
+someone else changed or deleted in the meantime are refused.`,
+}
+
+var remoteLoginCmd = &cobra.Command{
+	Use:   "login <name> <url>",
+	Short: "Log in to a hosted server and use its list",
+	Long: `Log in to a hosted server, save it under a name and use its list from
+now on. With --project the list of a shared project is used instead of your
+own; log in once more under another name for each list you want.`,
+	Example: `  gotodo remote login work https://todo.example.com --user alice
+  gotodo remote login website https://todo.example.com --user alice --project website`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, url := args[0], args[1]
+		if err := hosted.ValidateName(name); err != nil {
+			return err
+		}
+		if err := hosted.ValidateURL(url); err != nil {
+			return err
+		}
+		user := remoteUser
+		if user == "" {
+			return fmt.Errorf("give your user name on the server with --user")
+		}
+		password, err := ui.ReadPassword(fmt.Sprintf("Password for %s: ", user))
+		if err != nil {
+			return err
+		}
+		token, err := hosted.Login(url, user, password, remoteDevice(name))
+		if err != nil {
+			return fmt.Errorf("login failed: %v", err)
+		}
+		remote := hosted.Remote{Name: name, URL: url, User: user, Token: token, Project: remoteProject}
+		me, err := remote.Client().Me()
+		if err != nil {
+			return err
+		}
+		if remoteProject != "" && !slices.Contains(me.Projects, remoteProject) {
+			remote.Client().Logout()
+			return fmt.Errorf("you are not a member of project %q on %s", remoteProject, url)
+		}
+
+		book, err := remoteBook()
+		if err != nil {
+			return err
+		}
+		old, hadOld, err := book.Get(name)
+		if err != nil {
+			return err
+		}
+		if err := book.Put(remote); err != nil {
+			return err
+		}
+		if err := setCurrentRemote(name); err != nil {
+			return err
+		}
+		// a login to the same list again, e.g. after the token was revoked,
+		// keeps the copy so its queued changes are sent now; a login to
+		// another list under the name drops it
+		copyPath, err := offlineCopyPath(name)
+		if err != nil {
+			return err
+		}
+		sameList := hadOld && old.URL == remote.URL && old.User == remote.User && old.Project == remote.Project
+		if !sameList {
+			if err := discardOfflineCopy(copyPath); err != nil {
+				return err
+			}
+		}
+		o := hosted.NewOfflineRemote(remote.Client(), copyPath)
+		if _, err := o.List(); err != nil {
+			color.New(color.FgYellow).Printf("Could not fetch the list: %v\n", err)
+		}
+		reportRejected(o)
+		color.New(color.FgGreen).Printf("✓ Logged in to %s as %s, task commands now use %s\n", url, user, remote)
+		if len(me.Projects) > 0 && remoteProject == "" {
+			color.New(color.FgCyan).Printf("Projects shared with you: %s (log in with --project to use one)\n", strings.Join(me.Projects, ", "))
+		}
+		return nil
+	},
+}
+
+var remoteUseCmd = &cobra.Command{
+	Use:   "use <name|none>",
+	Short: "Choose the remote list the task commands use, or none for the local file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if args[0] == "none" {
+			if err := setCurrentRemote(""); err != nil {
+				return err
+			}
+			color.New(color.FgGreen).Println("✓ Task commands use the local file again")
+			return nil
+		}
+		book, err := remoteBook()
+		if err != nil {
+			return err
+		}
+		remote, ok, err := book.Get(args[0])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("no remote named %q, log in with 'gotodo remote login'", args[0])
+		}
+		if err := setCurrentRemote(remote.Name); err != nil {
+			return err
+		}
+		color.New(color.FgGreen).Printf("✓ Task commands now use %s\n", remote)
+		return nil
+	},
+}
+
+var remoteListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved remotes",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		book, err := remoteBook()
+		if err != nil {
+			return err
+		}
+		remotes, err := book.List()
+		if err != nil {
+			return err
+		}
+		if len(remotes) == 0 {
+			color.New(color.FgYellow).Println("No remotes, log in to one with 'gotodo remote login <name> <url> --user <user>'")
+			return nil
+		}
+		current, _ := currentRemoteName()
+		for _, r := range remotes {
+			mark := " "
+			if r.Name == current {
+				mark = "*"
+			}
+			color.New(color.FgGreen).Printf("%s ", mark)
+			color.New(color.FgWhite, color.Bold).Printf("%-12s", r.Name)
+			color.New(color.FgCyan).Printf(" %s\n", r)
+		}
+		return nil
+	},
+}
+
+var remoteLogoutCmd = &cobra.Command{
+	Use:   "logout <name>",
+	Short: "Log out of a remote and forget it",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		book, err := remoteBook()
+		if err != nil {
+			return err
+		}
+		remote, ok, err := book.Get(args[0])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("no remote named %q", args[0])
+		}
+		if err := remote.Client().Logout(); err != nil {
+			color.New(color.FgYellow).Printf("Could not revoke the token on the server: %v\n", err)
+		}
+		if err := book.Remove(remote.Name); err != nil {
+			return err
+		}
+		copyPath, err := offlineCopyPath(remote.Name)
+		if err != nil {
+			return err
+		}
+		if err := discardOfflineCopy(copyPath); err != nil {
+			return err
+		}
+		if current, _ := currentRemoteName(); current == remote.Name {
+			if err := setCurrentRemote(""); err != nil {
+				return err
+			}
+		}
+		color.New(color.FgGreen).Printf("✓ Logged out of %s\n", remote.Name)
+		return nil
+	},
+}
+
+var remoteStatusCmd = &cobra.Command{
+	Use:   "status [name]",
+	Short: "Send queued changes and show what is still waiting for the server",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var remote hosted.Remote
+		var ok bool
+		var err error
+		if len(args) == 1 {
+			book, berr := remoteBook()
+			if berr != nil {
+				return berr
+			}
+			if remote, ok, err = book.Get(args[0]); err == nil && !ok {
+				err = fmt.Errorf("no remote named %q", args[0])
+			}
+		} else if remote, ok, err = currentRemote(); err == nil && !ok {
+			err = fmt.Errorf("no remote in use, name one or log in with 'gotodo remote login'")
+		}
+		if err != nil {
+			return err
+		}
+		o, err := openRemote(remote)
+		if err != nil {
+			return err
+		}
+		reachable, flushErr := o.Flush()
+		color.New(color.FgWhite, color.Bold).Printf("%s: %s\n", remote.Name, remote)
+		_, fetched := o.Offline()
+		if flushErr != nil {
+			// the queue is kept, e.g. until a new login
+			color.New(color.FgRed).Printf("✗ %v\n", flushErr)
+		} else if reachable {
+			color.New(color.FgGreen).Println("✓ Server reachable")
+		} else {
+			color.New(color.FgYellow).Println("⚠ Server unreachable")
+		}
+		if fetched.IsZero() {
+			color.New(color.FgYellow).Println("No offline copy yet")
+		} else {
+			color.New(color.FgCyan).Printf("Offline copy from %s\n", ago(fetched))
+		}
+		queue, err := o.Queue()
+		if err != nil {
+			return err
+		}
+		for _, ch := range queue {
+			color.New(color.FgYellow).Printf("  queued %s, %s\n", ago(ch.QueuedAt), ch)
+		}
+		reportRejected(o)
+		return nil
+	},
+}
+
+// the remote's list through its offline copy
+func openRemote(remote hosted.Remote) (*hosted.OfflineRemote, error) {
+	path, err := offlineCopyPath(remote.Name)
+	if err != nil {
+		return nil, err
+	}
+	return hosted.NewOfflineRemote(remote.Client(), path), nil
+}
+
+// offline copy of a remote's list, kept in ~/.gotodo/remotes/<name>.json
+func offlineCopyPath(name string) (string, error) {
+	return gotodoFile(filepath.Join("remotes", name+".json"))
+}
+
+// remove an offline copy, warning about changes that were never sent
+func discardOfflineCopy(path string) error {
+	queue, err := hosted.NewOfflineRemote(nil, path).Queue()
+	if err == nil && len(queue) > 0 {
+		color.New(color.FgYellow).Printf("Discarding %s never sent to the server:\n", plural(len(queue), "change"))
+		for _, ch := range queue {
+			color.New(color.FgYellow).Printf("  %s\n", ch)
+		}
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove offline copy: %v", err)
+	}
+	return nil
+}
+
+// tell the user a command worked on the offline copy, and about queued
+// changes the server refused
+func reportRemote(o *hosted.OfflineRemote) {
+	reportRejected(o)
+	offline, fetched := o.Offline()
+	if !offline || fetched.IsZero() {
+		return
+	}
+	queue, _ := o.Queue()
+	color.New(color.FgYellow).Printf("⚠ Server unreachable, worked on the copy from %s; %s queued\n",
+		ago(fetched), plural(len(queue), "change"))
+}
+
+func reportRejected(o *hosted.OfflineRemote) {
+	for _, r := range o.Rejected() {
+		color.New(color.FgRed).Printf("✗ The server refused a queued change (%s): %v\n", r.Change, r.Err)
+	}
+}
+
+// saved remotes, kept in ~/.gotodo/remotes.json
+func remoteBook() (*hosted.RemoteBook, error) {
+	path, err := gotodoFile("remotes.json")
+	if err != nil {
+		return nil, err
+	}
+	return hosted.NewRemoteBook(path), nil
+}
+
+// the name of the remote in use, empty for the local file. --remote wins
+// over the config.
+func currentRemoteName() (string, error) {
+	switch remoteFlag {
+	case "none":
+		return "", nil
+	case "":
+	default:
+		return remoteFlag, nil
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+	return config[remoteConfigKey], nil
+}
+
+func setCurrentRemote(name string) error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		delete(config, remoteConfigKey)
+	} else {
+		config[remoteConfigKey] = name
+	}
+	return saveConfig(config)
+}
+
+// the remote in use, ok is false when the local file is used
+func currentRemote() (hosted.Remote, bool, error) {
+	name, err := currentRemoteName()
+	if err != nil || name == "" {
+		return hosted.Remote{}, false, err
+	}
+	book, err := remoteBook()
+	if err != nil {
+		return hosted.Remote{}, false, err
+	}
+	remote, ok, err := book.Get(name)
+	if err != nil {
+		return hosted.Remote{}, false, err
+	}
+	if !ok {
+		if remoteFlag != "" {
+			return hosted.Remote{}, false, fmt.Errorf("no remote named %q, log in with 'gotodo remote login'", name)
+		}
+		return hosted.Remote{}, false, fmt.Errorf("remote %q is not saved, log in again or run 'gotodo remote use none'", name)
+	}
+	return remote, true, nil
+}
+
+// the device name a remote's token is issued for, one per remote so logging
+// in to several lists on the same server keeps every token
+func remoteDevice(name string) string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "gotodo"
+	}
+	return host + "-" + name
+}
+
+func init() {
+	remoteLoginCmd.Flags().StringVarP(&remoteUser, "user", "u", "", "your user name on the server")
+	remoteLoginCmd.Flags().StringVar(&remoteProject, "project", "", "use this shared project's list instead of your own")
+	remoteCmd.AddCommand(remoteLoginCmd, remoteUseCmd, remoteListCmd, remoteLogoutCmd, remoteStatusCmd)
+	rootCmd.AddCommand(remoteCmd)
+}
@@ -0,0 +1,143 @@
+/*
+Copyright © 2025 Ethan Bao 522425561@qq.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/chart"
+	"github.com/ethanbao27/gotodo/internal/stats"
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/ethanbao27/gotodo/internal/ui"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var reportSince string
+var reportUntil string
+var reportTag string
+var reportSVG string
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Render charts of your task history",
+}
+
+var burndownCmd = &cobra.Command{
+	Use:   "burndown",
+	Short: "Chart the number of open tasks over time",
+	Example: `  gotodo report burndown --since 2026-10-01 --tag sprint-12
+  gotodo report burndown --since 2026-10-01 --svg burndown.svg`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runReport("Burndown", func(points []stats.DayPoint) []chart.Series {
+			open := chart.Series{Name: "open", Marker: '●', Color: "#d9534f"}
+			for _, p := range points {
+				open.Values = append(open.Values, p.Open)
+			}
+			return []chart.Series{open}
+		})
+	},
+}
+
+var burnupCmd = &cobra.Command{
+	Use:     "burnup",
+	Short:   "Chart total scope and completed tasks over time",
+	Example: `  gotodo report burnup --since 2026-10-01 --svg burnup.svg`,
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runReport("Burnup", func(points []stats.DayPoint) []chart.Series {
+			total := chart.Series{Name: "scope", Marker: '○', Color: "#5b8def"}
+			done := chart.Series{Name: "done", Marker: '●', Color: "#3fa34d"}
+			for _, p := range points {
+				total.Values = append(total.Values, p.Total)
+				done.Values = append(done.Values, p.Done)
+			}
+			return []chart.Series{total, done}
+		})
+	},
+}
+
+// load the history, build the series and render them to the terminal
+// and optionally to an SVG file
+func runReport(name string, build func([]stats.DayPoint) []chart.Series) error {
+	now := time.Now()
+	until := now
+	if reportUntil != "" {
+		d, err := parseDateArg(reportUntil, now)
+		if err != nil {
+			return err
+		}
+		until, _ = time.ParseInLocation(storage.DateLayout, d, time.Local)
+	}
+	since := until.AddDate(0, 0, -13)
+	if reportSince != "" {
+		d, err := parseDateArg(reportSince, now)
+		if err != nil {
+			return err
+		}
+		since, _ = time.ParseInLocation(storage.DateLayout, d, time.Local)
+	}
+	if since.After(until) {
+		return fmt.Errorf("--since must not be after --until")
+	}
+
+	tasks, err := storage.List()
+	if err != nil {
+		return err
+	}
+	events, err := storage.History()
+	if err != nil {
+		return err
+	}
+	records := stats.Collect(tasks, events)
+	if reportTag != "" {
+		records = stats.WithTag(records, reportTag)
+	}
+
+	points := stats.Daily(records, since, until)
+	labels := make([]string, len(points))
+	for i, p := range points {
+		labels[i] = p.Day.Format("Jan 02")
+	}
+	series := build(points)
+
+	title := name
+	if reportTag != "" {
+		title += " #" + reportTag
+	}
+	fmt.Println()
+	color.New(color.FgBlue, color.Bold).Printf("  %s  ", title)
+	color.New(color.FgWhite, color.Faint).Printf("  %s – %s\n\n", since.Format("Jan 02"), until.Format("Jan 02"))
+	chart.ASCII(os.Stdout, labels, series, 10, ui.TerminalWidth()-2)
+	fmt.Println()
+
+	if reportSVG != "" {
+		f, err := os.Create(reportSVG)
+		if err != nil {
+			return fmt.Errorf("failed to create svg file: %v", err)
+		}
+		defer f.Close()
+		svgTitle := fmt.Sprintf("%s %s – %s", title, since.Format(storage.DateLayout), until.Format(storage.DateLayout))
+		if err := chart.SVG(f, svgTitle, labels, series); err != nil {
+			return fmt.Errorf("failed to write svg file: %v", err)
+		}
+		color.New(color.FgGreen).Printf("✓ Chart written to %s\n", reportSVG)
+	}
+	return nil
+}
+
+func init() {
+	for _, c := range []*cobra.Command{burndownCmd, burnupCmd} {
+		c.Flags().StringVar(&reportSince, "since", "", "first day of the chart (default two weeks ago)")
+		c.Flags().StringVar(&reportUntil, "until", "", "last day of the chart (default today)")
+		c.Flags().StringVar(&reportTag, "tag", "", "only count tasks with this tag")
+		c.Flags().StringVar(&reportSVG, "svg", "", "also write the chart to this SVG file")
+		reportCmd.AddCommand(c)
+	}
+	rootCmd.AddCommand(reportCmd)
+}
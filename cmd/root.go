@@ -4,19 +4,23 @@ Copyright © 2025 Ethan Bao 522425561@qq.com
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/user"
 	"path/filepath"
 	"strings"
 
+	"github.com/ethanbao27/gotodo/internal/hosted"
 	"github.com/ethanbao27/gotodo/internal/storage"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
 var dbPath string
+var remoteFlag string
+
+// the remote list in use by this command, nil for the local file
+var offlineRemote *hosted.OfflineRemote
 
 var rootCmd = &cobra.Command{
 	Use:   "gotodo",
@@ -25,6 +29,14 @@ var rootCmd = &cobra.Command{
 		DisableDefaultCmd: true,
 	},
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// the shell has already done the setup below once
+		if inShell {
+			if cmd.Flags().Changed("db") {
+				storage.SetPath(dbPath)
+			}
+			return nil
+		}
+
 		usr, _ := user.Current()
 		marker := filepath.Join(usr.HomeDir, ".gotodo", "init_done")
 
@@ -40,18 +52,16 @@ var rootCmd = &cobra.Command{
 				return fmt.Errorf("failed to write marker file: %v", err)
 			}
 		}
+		// an explicit --db always means the local file
+		explicitDB := dbPath != ""
+		if explicitDB && remoteFlag != "" && remoteFlag != "none" {
+			return fmt.Errorf("--db and --remote cannot be used together")
+		}
 		// Load config if no --db flag is provided
 		if dbPath == "" {
-			home, err := os.UserHomeDir()
-			if err == nil {
-				configFile := filepath.Join(home, ".gotodo", "config.json")
-				if data, err := os.ReadFile(configFile); err == nil {
-					var config map[string]string
-					if json.Unmarshal(data, &config) == nil {
-						if configuredPath, exists := config["db_path"]; exists {
-							dbPath = configuredPath
-						}
-					}
+			if config, err := loadConfig(); err == nil {
+				if configuredPath, exists := config["db_path"]; exists {
+					dbPath = configuredPath
 				}
 			}
 		}
@@ -61,6 +71,27 @@ var rootCmd = &cobra.Command{
 		} else {
 			storage.SetPath(storage.GetCurrentPath())
 		}
+		// webhooks are for the owner's own list, not the lists a host serves
+		if !strings.HasPrefix(cmd.CommandPath(), "gotodo host") {
+			if err := startWebhooks(); err != nil {
+				return err
+			}
+		}
+
+		var remote hosted.Remote
+		useRemote := false
+		if !explicitDB && remoteCommands[cmd.CommandPath()] {
+			var err error
+			if remote, useRemote, err = currentRemote(); err != nil {
+				return err
+			}
+			if useRemote {
+				if offlineRemote, err = openRemote(remote); err != nil {
+					return err
+				}
+				storage.SetRemote(offlineRemote)
+			}
+		}
 
 		// Only show database path for certain commands
 		// Get the full command path to check parent commands
@@ -70,16 +101,36 @@ var rootCmd = &cobra.Command{
 		// Don't show path for list, config, and completion commands
 		if fullCmd == "gotodo list" || fullCmd == "gotodo config" ||
 			fullCmd == "gotodo config show" || fullCmd == "gotodo config set-db" ||
+			fullCmd == "gotodo config set-wip" || fullCmd == "gotodo config set-port" || fullCmd == "gotodo board" ||
+			fullCmd == "gotodo agenda" || fullCmd == "gotodo calendar" ||
+			fullCmd == "gotodo stats" || fullCmd == "gotodo report burndown" ||
+			fullCmd == "gotodo report burnup" || fullCmd == "gotodo inbox" ||
 			fullCmd == "gotodo completion" {
 			shouldShowPath = false
 		}
 
-		if shouldShowPath {
+		if useRemote {
+			if shouldShowPath {
+				color.New(color.FgCyan).Printf("Using remote %s: %s\n", remote.Name, remote)
+			}
+		} else if shouldShowPath {
 			currentPath := storage.GetCurrentPath()
 			color.New(color.FgCyan).Printf("Using database path: %s\n", currentPath)
 		}
 		return nil
 	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if offlineRemote != nil {
+			reportRemote(offlineRemote)
+		}
+	},
+}
+
+// commands that work on a remote list when one is in use
+var remoteCommands = map[string]bool{
+	"gotodo add": true, "gotodo list": true, "gotodo done": true, "gotodo delete": true,
+	"gotodo clear": true, "gotodo due": true, "gotodo schedule": true, "gotodo comment": true, "gotodo board": true,
+	"gotodo agenda": true, "gotodo calendar": true, "gotodo shell": true,
 }
 
 func InitSetup() error {
@@ -157,7 +208,9 @@ func appendIfMissing(rc, snippet, marker string) (bool, error) {
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	waitWebhooks()
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -165,4 +218,5 @@ func Execute() {
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&dbPath, "db", "", "path to store tasks")
+	rootCmd.PersistentFlags().StringVar(&remoteFlag, "remote", "", "use this saved remote's list, or none for the local file")
 }
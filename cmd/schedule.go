@@ -0,0 +1,47 @@
+/*
+Copyright © 2025 Ethan Bao 522425561@qq.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// scheduleCmd represents the schedule command
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule <id> <date|none>",
+	Short: "Set or clear the day you plan to work on a task",
+	Long: `Set the scheduled date of a task, the day you plan to start on it. Dates
+take the same forms as 'gotodo due'. Use 'none' to clear it.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+		day := ""
+		if args[1] != "none" {
+			if day, err = parseDateArg(args[1], time.Now()); err != nil {
+				return err
+			}
+		}
+		if err := storage.SetScheduled(id, day); err != nil {
+			return err
+		}
+		if day == "" {
+			fmt.Printf("Task %d is not scheduled.\n", id)
+		} else {
+			fmt.Printf("Task %d is scheduled for %s.\n", id, day)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+}
@@ -0,0 +1,133 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/api"
+	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// default address of the HTTP API, local only
+const defaultHTTPListen = "127.0.0.1:8080"
+
+var httpListen string
+var httpAllowOrigins []string
+
+var serveRootCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve your todo list to other programs",
+}
+
+var serveHTTPCmd = &cobra.Command{
+	Use:   "http",
+	Short: "Serve a REST API over HTTP",
+	Long: `Serve a REST API over HTTP, for browsers, curl and scripts.
+
+  GET    /tasks          list tasks, filter with ?done=, tag=, project=,
+                         priority=, due_before= and q=
+  POST   /tasks          add a task
+  GET    /tasks/{id}     get a task
+  PATCH  /tasks/{id}     change some fields of a task
+  DELETE /tasks/{id}     delete a task
+  GET    /openapi.json   OpenAPI description
+
+Responses carry an ETag; send it back in If-Match with PATCH and DELETE to
+fail with 412 instead of overwriting someone else's change. Once a token
+exists ('gotodo serve token create'), requests need it as a bearer token.
+
+Browsers only let web pages on other origins call the API when they are
+allowed with --allow-origin. Ctrl+C stops the server after the requests in
+progress.`,
+	Example: `  gotodo serve http --listen :8080
+  gotodo serve http --allow-origin https://dashboard.example.com
+  curl -H "Authorization: Bearer gtd_..." localhost:8080/tasks?done=false`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addr, err := network.NormalizeAddr(httpListen, 8080, true)
+		if err != nil {
+			return err
+		}
+		for _, o := range httpAllowOrigins {
+			if err := api.ValidateOrigin(o); err != nil {
+				return err
+			}
+		}
+		tokens, err := httpTokenStore()
+		if err != nil {
+			return err
+		}
+		srv := &http.Server{
+			Addr:              addr,
+			Handler:           api.NewHandler(api.Config{Tokens: tokens, AllowOrigins: httpAllowOrigins}),
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      30 * time.Second,
+		}
+
+		color.New(color.FgBlue, color.Bold).Printf("HTTP API listening on http://%s\n", addr)
+		if err := warnWithoutTokens(tokens); err != nil {
+			return err
+		}
+
+		errc := make(chan error, 1)
+		go func() { errc <- srv.ListenAndServe() }()
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		select {
+		case err := <-errc:
+			return fmt.Errorf("http server error: %v", err)
+		case <-ctx.Done():
+		}
+		stop()
+		color.New(color.FgBlue).Println("\nShutting down, finishing requests in progress...")
+		ctx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		return srv.Shutdown(ctx)
+	},
+}
+
+// warn that anyone may change the tasks while no API tokens are issued,
+// for 'gotodo serve http' and 'gotodo serve caldav'
+func warnWithoutTokens(tokens *network.TokenStore) error {
+	enabled, err := tokens.Enabled()
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		color.New(color.FgYellow).Println("No API tokens issued, anyone who can reach this address can change your tasks.")
+		color.New(color.FgYellow).Println("Create one with 'gotodo serve token create <name>'.")
+	}
+	return nil
+}
+
+// tokens for the HTTP API, kept in ~/.gotodo/http_tokens.json
+func httpTokenStore() (*network.TokenStore, error) {
+	path, err := gotodoFile("http_tokens.json")
+	if err != nil {
+		return nil, err
+	}
+	return network.NewTokenStore(path), nil
+}
+
+func init() {
+	serveHTTPCmd.Flags().StringVar(&httpListen, "listen", defaultHTTPListen, "address to listen on, e.g. :8080 or 0.0.0.0:8080")
+	serveHTTPCmd.Flags().StringSliceVar(&httpAllowOrigins, "allow-origin", nil, "web page origin allowed to call the API, e.g. https://example.com, or * (repeatable)")
+	serveRootCmd.AddCommand(serveHTTPCmd)
+	serveRootCmd.AddCommand(newTokenCmd(httpTokenStore, "HTTP API", `Manage the bearer tokens for 'gotodo serve http' and 'gotodo serve caldav'.
+
+Once any token exists, every request except /openapi.json needs one in
+an "Authorization: Bearer <token>" header. Calendar apps send it as the
+password instead.`))
+	rootCmd.AddCommand(serveRootCmd)
+}
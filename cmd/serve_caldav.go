@@ -0,0 +1,69 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/caldav"
+	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// default address of the CalDAV server, local only
+const defaultCalDAVListen = "127.0.0.1:5232"
+
+var caldavListen string
+var caldavName string
+
+var serveCalDAVCmd = &cobra.Command{
+	Use:   "caldav",
+	Short: "Serve tasks to calendar and reminders apps over CalDAV",
+	Long: `Serve your tasks as a CalDAV calendar of to-dos (VTODO), for calendar and
+reminders apps such as Thunderbird, DAVx5 with tasks.org, or Apple Reminders.
+
+Add an account with the server address, e.g. http://127.0.0.1:5232/. Tasks
+show up with their due date, priority, tags (as categories) and completion.
+Tasks added, changed, completed or deleted in the app change your list.
+
+Once a token exists ('gotodo serve token create'), apps log in with any user
+name and the token as password.`,
+	Example: `  gotodo serve caldav --listen 0.0.0.0:5232`,
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		addr, err := network.NormalizeAddr(caldavListen, 5232, true)
+		if err != nil {
+			return err
+		}
+		tokens, err := httpTokenStore()
+		if err != nil {
+			return err
+		}
+		srv := &http.Server{
+			Addr:              addr,
+			Handler:           caldav.NewHandler(caldav.Config{Tokens: tokens, Name: caldavName}),
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      30 * time.Second,
+		}
+
+		color.New(color.FgBlue, color.Bold).Printf("CalDAV server listening on http://%s/\n", addr)
+		if err := warnWithoutTokens(tokens); err != nil {
+			return err
+		}
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			return fmt.Errorf("caldav server error: %v", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	serveCalDAVCmd.Flags().StringVar(&caldavListen, "listen", defaultCalDAVListen, "address to listen on, e.g. :5232 or 0.0.0.0:5232")
+	serveCalDAVCmd.Flags().StringVar(&caldavName, "name", "gotodo", "calendar name shown by apps")
+	serveRootCmd.AddCommand(serveCalDAVCmd)
+}
@@ -0,0 +1,256 @@
+/*
+Copyright © 2025 Ethan Bao 522425561@qq.com
+*/
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/ethanbao27/gotodo/internal/ui"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// set while commands are dispatched from the interactive shell
+var inShell bool
+
+// database path in effect when the shell started, lines without --db use it
+var shellPath string
+
+// shellCmd represents the shell command
+var shellCmd = &cobra.Command{
+	Use:   "shell",
+	Short: "Start an interactive shell for running gotodo commands",
+	Long: `Start an interactive shell that runs gotodo commands in-process.
+
+Commands are typed without the leading "gotodo", e.g. 'add buy milk' or 'done 3'.
+Use the arrow keys for history, Tab to complete commands and task IDs,
+and 'exit' or Ctrl-D to leave.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		editor := ui.NewLineEditor("gotodo> ")
+		editor.Complete = shellComplete
+		historyFile := shellHistoryPath()
+		editor.History = loadShellHistory(historyFile)
+
+		inShell = true
+		defer func() { inShell = false }()
+		shellPath = storage.GetCurrentPath()
+
+		color.New(color.FgBlue, color.Bold).Println("gotodo shell, type 'help' for commands, 'exit' to quit")
+		for {
+			line, err := editor.ReadLine()
+			if errors.Is(err, ui.ErrInterrupted) {
+				continue
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			editor.AddHistory(line)
+			appendShellHistory(historyFile, line)
+
+			args, err := splitShellArgs(line)
+			if err != nil {
+				color.New(color.FgRed).Println(err)
+				continue
+			}
+			switch args[0] {
+			case "exit", "quit":
+				return nil
+			case "shell":
+				color.New(color.FgYellow).Println("Already in the gotodo shell.")
+				continue
+			}
+			runShellLine(args)
+		}
+	},
+}
+
+// run one shell line through the cobra command tree
+func runShellLine(args []string) {
+	resetFlags(rootCmd)
+	// a --db on an earlier line only applied to that line
+	storage.SetPath(shellPath)
+	rootCmd.SetArgs(args)
+	// cobra already reports the error, nothing more to do here
+	_ = rootCmd.Execute()
+	rootCmd.SetArgs(nil)
+}
+
+// restore every flag to its default so values don't leak between lines
+func resetFlags(c *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		// runShellLine puts back the path the shell started with
+		if f.Name == "db" {
+			f.Changed = false
+			return
+		}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			_ = sv.Replace(nil)
+		} else {
+			_ = f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	c.Flags().VisitAll(reset)
+	c.PersistentFlags().VisitAll(reset)
+	for _, sub := range c.Commands() {
+		resetFlags(sub)
+	}
+}
+
+// split a line into arguments, honouring single and double quotes
+func splitShellArgs(line string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inArg := false
+
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", line)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// complete command names for the first word and task IDs for done/delete
+func shellComplete(line string) []string {
+	fields := strings.Fields(line)
+	word := ""
+	if !strings.HasSuffix(line, " ") && len(fields) > 0 {
+		word = fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+	}
+
+	var options []string
+	if len(fields) == 0 {
+		for _, c := range rootCmd.Commands() {
+			if !c.Hidden && c.Name() != "shell" {
+				options = append(options, c.Name())
+			}
+		}
+		options = append(options, "exit")
+	} else {
+		switch fields[0] {
+		case "done", "delete":
+			if len(fields) > 1 {
+				return nil
+			}
+			tasks, err := storage.List()
+			if err != nil {
+				return nil
+			}
+			for _, t := range tasks {
+				if fields[0] == "done" && t.Done {
+					continue
+				}
+				options = append(options, strconv.Itoa(t.ID))
+			}
+		default:
+			if sub, _, err := rootCmd.Find(fields); err == nil && sub != rootCmd {
+				for _, c := range sub.Commands() {
+					if !c.Hidden {
+						options = append(options, c.Name())
+					}
+				}
+			}
+		}
+	}
+
+	var matches []string
+	for _, o := range options {
+		if strings.HasPrefix(o, word) {
+			matches = append(matches, o)
+		}
+	}
+	return matches
+}
+
+func shellHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".gotodo", "shell_history")
+}
+
+func loadShellHistory(path string) []string {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var history []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			history = append(history, line)
+		}
+	}
+	// keep the most recent entries only
+	if len(history) > 500 {
+		history = history[len(history)-500:]
+	}
+	return history
+}
+
+func appendShellHistory(path, line string) {
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+func init() {
+	rootCmd.AddCommand(shellCmd)
+}
@@ -0,0 +1,121 @@
+/*
+Copyright © 2025 Ethan Bao 522425561@qq.com
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/stats"
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/ethanbao27/gotodo/internal/ui"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var statsDays int
+var statsWeeks int
+
+// statsCmd represents the stats command
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show productivity statistics",
+	Long: `Show tasks created and completed per day and week, average time to
+complete, completion streaks and per-tag/project breakdowns.
+
+Statistics are computed from task timestamps and the change log kept next
+to the tasks file, so deleted tasks still count.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if statsDays < 1 || statsWeeks < 1 {
+			return fmt.Errorf("--days and --weeks must be at least 1")
+		}
+		tasks, err := storage.List()
+		if err != nil {
+			return err
+		}
+		events, err := storage.History()
+		if err != nil {
+			return err
+		}
+		report := stats.Compute(stats.Collect(tasks, events), statsDays, statsWeeks, time.Now())
+		if report.Total == 0 {
+			color.New(color.FgYellow).Println("No tasks.")
+			return nil
+		}
+
+		fmt.Println()
+		color.New(color.FgBlue, color.Bold).Printf("  STATS  ")
+		color.New(color.FgWhite, color.Faint).Printf("  %d tasks tracked, %d completed\n", report.Total, report.Done)
+		fmt.Println()
+
+		avg := "n/a"
+		if report.AvgToDone > 0 {
+			avg = formatDuration(report.AvgToDone)
+		}
+		printStat("Avg time to complete", avg)
+		printStat("Current streak", fmt.Sprintf("%d days", report.CurrentStreak))
+		printStat("Longest streak", fmt.Sprintf("%d days", report.LongestStreak))
+		fmt.Println()
+
+		color.New(color.FgCyan, color.Bold).Printf("  Last %d days\n", statsDays)
+		printSeries("created", report.DailyCreated)
+		printSeries("completed", report.DailyCompleted)
+		fmt.Println()
+		color.New(color.FgCyan, color.Bold).Printf("  Last %d weeks\n", statsWeeks)
+		printSeries("created", report.WeeklyCreated)
+		printSeries("completed", report.WeeklyCompleted)
+
+		printBreakdown("By tag", report.ByTag)
+		printBreakdown("By project", report.ByProject)
+		fmt.Println()
+		return nil
+	},
+}
+
+func printStat(label, value string) {
+	color.New(color.FgWhite).Printf("  %-22s", label)
+	color.New(color.FgGreen).Println(value)
+}
+
+func printSeries(label string, values []int) {
+	sum := 0
+	for _, v := range values {
+		sum += v
+	}
+	color.New(color.FgWhite).Printf("  %-10s ", label)
+	color.New(color.FgGreen).Print(ui.Sparkline(values))
+	color.New(color.FgWhite, color.Faint).Printf("  %d total\n", sum)
+}
+
+func printBreakdown(title string, rows []stats.Breakdown) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Println()
+	color.New(color.FgCyan, color.Bold).Printf("  %s\n", title)
+	for _, b := range rows {
+		progress := float64(b.Done) / float64(b.Total) * 100
+		color.New(color.FgWhite).Printf("  %-16s", ui.Truncate(b.Name, 16))
+		color.New(color.FgWhite, color.Faint).Printf(" %3d/%-3d %5.1f%%\n", b.Done, b.Total, progress)
+	}
+}
+
+// compact duration such as "3d 4h" or "25m"
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+}
+
+func init() {
+	statsCmd.Flags().IntVar(&statsDays, "days", 14, "number of days in the daily chart")
+	statsCmd.Flags().IntVar(&statsWeeks, "weeks", 8, "number of weeks in the weekly chart")
+	rootCmd.AddCommand(statsCmd)
+}
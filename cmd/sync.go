@@ -0,0 +1,90 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync <name|address>",
+	Short: "Two-way sync your list with a friend's",
+	Long: `Two-way sync your list with a friend's.
+
+Only changes since the last sync with that friend are exchanged. Tasks are
+matched by a stable UUID; when both sides changed the same field, the newer
+change wins and the conflict is reported. Deletions win over edits.
+Your friend has to allow it with 'gotodo friend share set <you> --allow sync'.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withFriend(cmd, args[0], func(c *network.Client, target friendTarget) error {
+			state, err := storage.GetSyncState(target.Label)
+			if err != nil {
+				return fmt.Errorf("failed to load sync state: %v", err)
+			}
+			now := storage.Stamp()
+			tasks, tombs, err := storage.Changes(state.Local)
+			if err != nil {
+				return fmt.Errorf("failed to load local changes: %v", err)
+			}
+			theirs, err := c.Sync(network.SyncBatch{
+				Now:        now,
+				Since:      state.Remote,
+				Base:       state.Local,
+				Tasks:      tasks,
+				Tombstones: tombs,
+			})
+			if err != nil {
+				return err
+			}
+			report, err := storage.Merge(theirs.Tasks, theirs.Tombstones, state.Local, state.Remote)
+			if err != nil {
+				return fmt.Errorf("failed to merge changes: %v", err)
+			}
+			if err := storage.SetSyncState(target.Label, storage.SyncState{Local: now, Remote: theirs.Now}); err != nil {
+				return fmt.Errorf("failed to save sync state: %v", err)
+			}
+
+			color.New(color.FgGreen).Printf("✓ Synced with %s: sent %d changes, received %d\n",
+				target.Label, len(tasks)+len(tombs), len(theirs.Tasks)+len(theirs.Tombstones))
+			color.New(color.FgWhite, color.Faint).Printf("  %d added, %d updated, %d deleted here\n",
+				report.Added, report.Updated, report.Deleted)
+			printConflicts(report.Conflicts)
+			return nil
+		})
+	},
+}
+
+func printConflicts(conflicts []storage.Conflict) {
+	if len(conflicts) == 0 {
+		return
+	}
+	color.New(color.FgYellow).Printf("%d conflicts resolved:\n", len(conflicts))
+	for _, c := range conflicts {
+		task := fmt.Sprintf("%q", c.Content)
+		if c.ID != 0 {
+			task = fmt.Sprintf("task %d %q", c.ID, c.Content)
+		}
+		if c.Field == "deleted" {
+			color.New(color.FgYellow).Printf("  %s: %s here, %s there, kept the deletion\n", task, c.Local, c.Remote)
+			continue
+		}
+		kept, lost := c.Local, c.Remote
+		if c.Winner == "remote" {
+			kept, lost = c.Remote, c.Local
+		}
+		color.New(color.FgYellow).Printf("  %s: %s, kept %s %q over %q\n", task, c.Field, c.Winner, kept, lost)
+	}
+}
+
+func init() {
+	syncCmd.Flags().StringVar(&friendToken, "token", "", "token issued by your friend")
+	syncCmd.Flags().IntVar(&friendPort, "port", network.DefaultPort, "port to use when the address has none")
+	rootCmd.AddCommand(syncCmd)
+}
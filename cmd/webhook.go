@@ -0,0 +1,207 @@
+/*
+Copyright © 2025 Ethan Bao
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+	"github.com/ethanbao27/gotodo/internal/webhook"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// how long a command waits for its webhooks before exiting
+const webhookWait = 10 * time.Second
+
+var webhookEvents []string
+var webhookFilter string
+var webhookSecret string
+
+// sends the webhooks of this process
+var webhooks *webhook.Dispatcher
+
+var webhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "Call URLs when tasks change",
+	Long: `Call URLs when tasks change, e.g. to post to a chat when a release task
+is done. Every change of the local list, from any command or server, POSTs a
+JSON payload to the webhooks that want it:
+
+  {"id": "evt_...", "event": "task.done", "time": "...", "task": {...}}
+
+with the headers X-Gotodo-Event, X-Gotodo-Delivery (the id) and
+X-Gotodo-Signature, "sha256=" and the hex HMAC-SHA256 of the body keyed with
+the webhook's secret. Receivers that are down or answer 408, 429 or 5xx are
+tried again with growing waits. Deliveries still failing, or unfinished when
+a command exits, are kept in ~/.gotodo/webhooks.queue.json and sent again by
+the next gotodo command run a minute or more later, for up to a day. A
+receiver may so get an event twice, with the same X-Gotodo-Delivery. Changes
+to remote lists and to the lists 'gotodo host serve' keeps for its users do
+not fire webhooks.`,
+}
+
+var webhookAddCmd = &cobra.Command{
+	Use:   "add <name> <url>",
+	Short: "Add a webhook or replace the one with the same name",
+	Long: `Add a webhook or replace the one with the same name.
+
+Events are ` + strings.Join(webhook.Events, ", ") + `, all of them
+when none are given. --filter chooses the tasks with an expression of
+comparisons joined by and, or, not and brackets:
+
+  field=value  field!=value  field~text (contains)  due<date  due>date
+
+on the fields ` + strings.Join(webhook.FilterFields, ", ") + `. Without --secret a random one
+is generated and shown once.`,
+	Example: `  gotodo webhook add chat https://chat.example.com/hooks/abc -e task.done --filter 'tag=release'
+  gotodo webhook add urgent https://example.com/hook --filter 'priority=high and not project~personal'`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := webhookStore()
+		if err != nil {
+			return err
+		}
+		sub := webhook.Subscription{Name: args[0], URL: args[1], Events: webhookEvents, Filter: webhookFilter, Secret: webhookSecret}
+		generated := sub.Secret == ""
+		if generated {
+			sub.Secret = webhook.NewSecret()
+		}
+		if err := store.Put(sub); err != nil {
+			return err
+		}
+		color.New(color.FgGreen).Printf("✓ Webhook %s added\n", sub.Name)
+		if generated {
+			color.New(color.FgYellow).Println("Secret for checking X-Gotodo-Signature (shown once):")
+			fmt.Println(sub.Secret)
+		}
+		return nil
+	},
+}
+
+var webhookListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List webhooks",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := webhookStore()
+		if err != nil {
+			return err
+		}
+		subs, err := store.List()
+		if err != nil {
+			return err
+		}
+		if len(subs) == 0 {
+			color.New(color.FgYellow).Println("No webhooks, add one with 'gotodo webhook add <name> <url>'")
+			return nil
+		}
+		for _, sub := range subs {
+			events := "all events"
+			if len(sub.Events) > 0 {
+				events = strings.Join(sub.Events, ", ")
+			}
+			color.New(color.FgWhite, color.Bold).Printf("  %-12s", sub.Name)
+			color.New(color.FgCyan).Printf(" %s\n", sub.URL)
+			color.New(color.FgBlue).Printf("  %-12s %s", "", events)
+			if sub.Filter != "" {
+				color.New(color.FgBlue).Printf(" where %s", sub.Filter)
+			}
+			fmt.Println()
+		}
+		return nil
+	},
+}
+
+var webhookRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a webhook",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := webhookStore()
+		if err != nil {
+			return err
+		}
+		if err := store.Remove(args[0]); err != nil {
+			return err
+		}
+		color.New(color.FgGreen).Printf("✓ Webhook %s removed\n", args[0])
+		return nil
+	},
+}
+
+var webhookTestCmd = &cobra.Command{
+	Use:   "test <name>",
+	Short: "Send a ping event to a webhook",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := webhookStore()
+		if err != nil {
+			return err
+		}
+		sub, ok, err := store.Get(args[0])
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("no webhook named %q", args[0])
+		}
+		d := webhook.NewDispatcher(store)
+		d.MaxAttempts = 1
+		if err := d.Deliver(sub, webhook.Ping()); err != nil {
+			return fmt.Errorf("webhook %s failed: %v", sub.Name, err)
+		}
+		color.New(color.FgGreen).Printf("✓ %s accepted the ping\n", sub.URL)
+		return nil
+	},
+}
+
+// webhooks, kept in ~/.gotodo/webhooks.json
+func webhookStore() (*webhook.Store, error) {
+	path, err := gotodoFile("webhooks.json")
+	if err != nil {
+		return nil, err
+	}
+	return webhook.NewStore(path), nil
+}
+
+// send webhooks for the changes this process makes
+func startWebhooks() error {
+	if webhooks != nil {
+		return nil
+	}
+	store, err := webhookStore()
+	if err != nil {
+		return err
+	}
+	queuePath, err := gotodoFile("webhooks.queue.json")
+	if err != nil {
+		return err
+	}
+	webhooks = webhook.NewDispatcher(store)
+	webhooks.Queue = webhook.NewQueue(queuePath)
+	storage.SetObserver(webhooks.Notify)
+	// what earlier commands could not deliver
+	if err := webhooks.Drain(); err != nil {
+		color.New(color.FgYellow).Printf("⚠ Failed to send queued webhooks: %v\n", err)
+	}
+	return nil
+}
+
+// give webhooks still being delivered some time before exiting
+func waitWebhooks() {
+	if webhooks != nil && !webhooks.Wait(webhookWait) {
+		color.New(color.FgYellow).Println("⚠ Some webhooks are still being delivered, a later command will send them")
+	}
+}
+
+func init() {
+	webhookAddCmd.Flags().StringSliceVarP(&webhookEvents, "event", "e", nil, "event to send (repeatable or comma separated)")
+	webhookAddCmd.Flags().StringVar(&webhookFilter, "filter", "", "expression choosing the tasks, e.g. 'tag=release and done=true'")
+	webhookAddCmd.Flags().StringVar(&webhookSecret, "secret", "", "key of the signature (default a random one)")
+	webhookCmd.AddCommand(webhookAddCmd, webhookListCmd, webhookRemoveCmd, webhookTestCmd)
+	rootCmd.AddCommand(webhookCmd)
+}
@@ -0,0 +1,455 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+// longest request body accepted
+const maxBodySize = 1 << 20
+
+// settings of the HTTP API
+type Config struct {
+	// clients must send one of these as a bearer token, unless none are issued
+	Tokens *network.TokenStore
+	// the author recorded on comments, the owner of the list when nil
+	Author func(r *http.Request) string
+	// the list a request works on, storage.Default() when nil
+	Store func(r *http.Request) *storage.Store
+	// web pages allowed to call the API from a browser, e.g.
+	// "https://example.com", or "*" for any
+	AllowOrigins []string
+}
+
+type api struct {
+	cfg Config
+}
+
+// the HTTP handler serving the REST API
+func NewHandler(cfg Config) http.Handler {
+	a := &api{cfg: cfg}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /tasks", a.listTasks)
+	mux.HandleFunc("POST /tasks", a.createTask)
+	mux.HandleFunc("GET /tasks/{id}", a.getTask)
+	mux.HandleFunc("PATCH /tasks/{id}", a.patchTask)
+	mux.HandleFunc("DELETE /tasks/{id}", a.deleteTask)
+	mux.HandleFunc("POST /tasks/{id}/comments", a.commentTask)
+	mux.HandleFunc("GET /openapi.json", serveOpenAPI)
+	return a.cors(a.authenticate(mux))
+}
+
+// check an --allow-origin value: "*" or scheme://host[:port]
+func ValidateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("invalid origin %q (want e.g. https://example.com, or *)", origin)
+	}
+	return nil
+}
+
+// headers browsers may send and read on other origins
+const (
+	corsMethods = "GET, POST, PATCH, DELETE, OPTIONS"
+	corsHeaders = "Authorization, Content-Type, If-Match, If-None-Match"
+	corsExposed = "ETag, Location"
+)
+
+// let the allowed web pages call the API, answering preflight requests
+// before they reach authentication, which browsers do not send them with
+func (a *api) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && (slices.Contains(a.cfg.AllowOrigins, "*") || slices.Contains(a.cfg.AllowOrigins, origin))
+		if allowed {
+			w.Header().Add("Vary", "Origin")
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Expose-Headers", corsExposed)
+		}
+		if r.Method != http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if allowed && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", corsMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsHeaders)
+			w.Header().Set("Access-Control-Max-Age", "600")
+		}
+		w.Header().Set("Allow", corsMethods)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// the list r works on
+func (a *api) store(r *http.Request) *storage.Store {
+	if a.cfg.Store == nil {
+		return storage.Default()
+	}
+	return a.cfg.Store(r)
+}
+
+// error body of every failed request
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...any) {
+	writeJSON(w, status, errorBody{Error: fmt.Sprintf(format, args...)})
+}
+
+// check the bearer token, the OpenAPI document is public
+func (a *api) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/openapi.json" || a.cfg.Tokens == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		enabled, err := a.cfg.Tokens.Enabled()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "server cannot check tokens")
+			return
+		}
+		if enabled {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="gotodo"`)
+				writeError(w, http.StatusUnauthorized, "a bearer token is required")
+				return
+			}
+			if _, valid, err := a.cfg.Tokens.Verify(token); err != nil {
+				writeError(w, http.StatusInternalServerError, "server cannot check tokens")
+				return
+			} else if !valid {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="gotodo", error="invalid_token"`)
+				writeError(w, http.StatusUnauthorized, "invalid or revoked token")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// strong ETag of any JSON value
+func etag(v any) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// the ETag the API gives a task, for clients sending If-Match
+func ETag(t storage.Task) string {
+	return etag(t)
+}
+
+// report whether an If-None-Match or If-Match header lists tag
+func matches(header, tag string) bool {
+	for _, h := range strings.Split(header, ",") {
+		h = strings.TrimPrefix(strings.TrimSpace(h), "W/")
+		if h == "*" || h == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// answer 304 when the client already has this version
+func notModified(w http.ResponseWriter, r *http.Request, tag string) bool {
+	w.Header().Set("ETag", tag)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && matches(inm, tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// GET /tasks with optional filters
+func (a *api) listTasks(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	tasks, err := a.store(r).List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load tasks")
+		return
+	}
+	out := []storage.Task{}
+	for _, t := range tasks {
+		if f.match(t) {
+			out = append(out, t)
+		}
+	}
+	if notModified(w, r, etag(out)) {
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// body of POST /tasks
+type newTask struct {
+	Content   string   `json:"content"`
+	Tags      []string `json:"tags"`
+	Priority  string   `json:"priority"`
+	Project   string   `json:"project"`
+	Due       string   `json:"due"`
+	Scheduled string   `json:"scheduled"`
+}
+
+// POST /tasks
+func (a *api) createTask(w http.ResponseWriter, r *http.Request) {
+	var body newTask
+	if !readBody(w, r, &body) {
+		return
+	}
+	if strings.TrimSpace(body.Content) == "" {
+		writeError(w, http.StatusBadRequest, "task content is empty")
+		return
+	}
+	t, err := a.store(r).AddTask(storage.Task{
+		Content:   strings.TrimSpace(body.Content),
+		Tags:      body.Tags,
+		Priority:  body.Priority,
+		Project:   body.Project,
+		Due:       body.Due,
+		Scheduled: body.Scheduled,
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/tasks/%d", t.ID))
+	w.Header().Set("ETag", etag(t))
+	writeJSON(w, http.StatusCreated, t)
+}
+
+// GET /tasks/{id}
+func (a *api) getTask(w http.ResponseWriter, r *http.Request) {
+	t, ok := a.lookup(w, r)
+	if !ok || notModified(w, r, etag(t)) {
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+// PATCH /tasks/{id}, honouring If-Match
+func (a *api) patchTask(w http.ResponseWriter, r *http.Request) {
+	var patch storage.TaskPatch
+	if !readBody(w, r, &patch) {
+		return
+	}
+	t, ok := a.lookup(w, r)
+	if !ok {
+		return
+	}
+	updated, err := a.store(r).UpdateIf(t.ID, patch, ifMatch(r, &t))
+	if errors.Is(err, errChanged) {
+		preconditionFailed(w, t)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	w.Header().Set("ETag", etag(updated))
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// DELETE /tasks/{id}, honouring If-Match
+func (a *api) deleteTask(w http.ResponseWriter, r *http.Request) {
+	t, ok := a.lookup(w, r)
+	if !ok {
+		return
+	}
+	err := a.store(r).DeleteIf(t.ID, ifMatch(r, &t))
+	if errors.Is(err, errChanged) {
+		preconditionFailed(w, t)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to delete task")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// body of POST /tasks/{id}/comments
+type newComment struct {
+	Text string `json:"text"`
+}
+
+// POST /tasks/{id}/comments
+func (a *api) commentTask(w http.ResponseWriter, r *http.Request) {
+	var body newComment
+	if !readBody(w, r, &body) {
+		return
+	}
+	t, ok := a.lookup(w, r)
+	if !ok {
+		return
+	}
+	c := storage.Comment{Text: body.Text}
+	if a.cfg.Author != nil {
+		c.Author = a.cfg.Author(r)
+	}
+	updated, err := a.store(r).AddComment(t.ID, c)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	w.Header().Set("ETag", etag(updated))
+	writeJSON(w, http.StatusCreated, updated)
+}
+
+// the task named by the {id} path segment, answering 400 or 404 otherwise
+func (a *api) lookup(w http.ResponseWriter, r *http.Request) (storage.Task, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid task id %q", r.PathValue("id"))
+		return storage.Task{}, false
+	}
+	tasks, err := a.store(r).List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load tasks")
+		return storage.Task{}, false
+	}
+	for _, t := range tasks {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	writeError(w, http.StatusNotFound, "task %d not found", id)
+	return storage.Task{}, false
+}
+
+// the client sent If-Match with an outdated copy of the task
+var errChanged = errors.New("task has changed")
+
+// a check for UpdateIf and DeleteIf of If-Match against the current
+// version of the task, which is kept in cur
+func ifMatch(r *http.Request, cur *storage.Task) func(storage.Task) error {
+	return func(t storage.Task) error {
+		*cur = t
+		if im := r.Header.Get("If-Match"); im != "" && !matches(im, etag(t)) {
+			return errChanged
+		}
+		return nil
+	}
+}
+
+// answer 412 to a change of an outdated copy of t
+func preconditionFailed(w http.ResponseWriter, t storage.Task) {
+	w.Header().Set("ETag", etag(t))
+	writeError(w, http.StatusPreconditionFailed, "task %d has changed, fetch it again", t.ID)
+}
+
+// decode a JSON request body into v, answering 400 on failure
+func readBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var maxErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxErr):
+			writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		case errors.Is(err, io.EOF):
+			writeError(w, http.StatusBadRequest, "request body is empty")
+		default:
+			writeError(w, http.StatusBadRequest, "invalid JSON body: %v", err)
+		}
+		return false
+	}
+	return true
+}
+
+// query parameters of GET /tasks
+type filter struct {
+	done      *bool
+	tag       string
+	project   string
+	priority  string
+	dueBefore string
+	query     string
+}
+
+func parseFilter(r *http.Request) (filter, error) {
+	q := r.URL.Query()
+	f := filter{
+		tag:       q.Get("tag"),
+		project:   q.Get("project"),
+		priority:  q.Get("priority"),
+		dueBefore: q.Get("due_before"),
+		query:     strings.ToLower(q.Get("q")),
+	}
+	if v := q.Get("done"); v != "" {
+		done, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, fmt.Errorf("invalid done %q (want true or false)", v)
+		}
+		f.done = &done
+	}
+	if err := storage.ValidatePriority(f.priority); err != nil {
+		return f, err
+	}
+	if f.dueBefore != "" {
+		if _, ok := (storage.Task{Due: f.dueBefore}).DueDate(); !ok {
+			return f, fmt.Errorf("invalid due_before %q (want YYYY-MM-DD)", f.dueBefore)
+		}
+	}
+	return f, nil
+}
+
+func (f filter) match(t storage.Task) bool {
+	if f.done != nil && t.Done != *f.done {
+		return false
+	}
+	if f.tag != "" {
+		found := false
+		for _, tag := range t.Tags {
+			if tag == f.tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.project != "" && t.Project != f.project {
+		return false
+	}
+	if f.priority != "" && t.Priority != f.priority {
+		return false
+	}
+	// dates in DateLayout compare as strings
+	if f.dueBefore != "" && (t.Due == "" || t.Due >= f.dueBefore) {
+		return false
+	}
+	if f.query != "" && !strings.Contains(strings.ToLower(t.Content), f.query) {
+		return false
+	}
+	return true
+}
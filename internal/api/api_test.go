@@ -0,0 +1,241 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+func TestAPI(t *testing.T) {
+	storage.SetPath(filepath.Join(t.TempDir(), "tasks.json"))
+	srv := httptest.NewServer(NewHandler(Config{}))
+	defer srv.Close()
+
+	do := func(method, path, body string, header map[string]string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to build request: %v", err)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	decode := func(resp *http.Response, v any) {
+		t.Helper()
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+	}
+
+	// Test creating tasks
+	t.Run("Create", func(t *testing.T) {
+		for _, body := range []string{
+			`{"content": "Write API", "tags": ["dev"], "priority": "high"}`,
+			`{"content": "Buy milk"}`,
+		} {
+			resp := do("POST", "/tasks", body, nil)
+			if resp.StatusCode != http.StatusCreated || resp.Header.Get("ETag") == "" {
+				t.Fatalf("Expected 201 with ETag, got %d", resp.StatusCode)
+			}
+		}
+		if resp := do("POST", "/tasks", `{"content": "x", "priority": "urgent"}`, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an invalid priority, got %d", resp.StatusCode)
+		}
+		if resp := do("POST", "/tasks", `{"content": "x", "bogus": 1}`, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an unknown field, got %d", resp.StatusCode)
+		}
+	})
+
+	// Test filters and conditional GET
+	t.Run("List", func(t *testing.T) {
+		resp := do("GET", "/tasks?tag=dev", "", nil)
+		var tasks []storage.Task
+		decode(resp, &tasks)
+		if len(tasks) != 1 || tasks[0].Content != "Write API" {
+			t.Fatalf("Expected the dev task, got %+v", tasks)
+		}
+		tag := resp.Header.Get("ETag")
+		if resp := do("GET", "/tasks?tag=dev", "", map[string]string{"If-None-Match": tag}); resp.StatusCode != http.StatusNotModified {
+			t.Errorf("Expected 304, got %d", resp.StatusCode)
+		}
+		if resp := do("GET", "/tasks?done=maybe", "", nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for a bad filter, got %d", resp.StatusCode)
+		}
+	})
+
+	// Test If-Match protects against lost updates
+	t.Run("Patch", func(t *testing.T) {
+		resp := do("GET", "/tasks/1", "", nil)
+		stale := resp.Header.Get("ETag")
+		resp = do("PATCH", "/tasks/1", `{"done": true}`, map[string]string{"If-Match": stale})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", resp.StatusCode)
+		}
+		var task storage.Task
+		decode(resp, &task)
+		if !task.Done || task.CompletedAt == "" || task.Content != "Write API" {
+			t.Errorf("Expected task done with content kept, got %+v", task)
+		}
+		resp = do("PATCH", "/tasks/1", `{"content": "Overwrite"}`, map[string]string{"If-Match": stale})
+		if resp.StatusCode != http.StatusPreconditionFailed {
+			t.Errorf("Expected 412 for a stale ETag, got %d", resp.StatusCode)
+		}
+		if resp := do("PATCH", "/tasks/9", `{"done": true}`, nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404, got %d", resp.StatusCode)
+		}
+	})
+
+	// Test commenting
+	t.Run("Comment", func(t *testing.T) {
+		resp := do("POST", "/tasks/1/comments", `{"text": "Shipped"}`, nil)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", resp.StatusCode)
+		}
+		var task storage.Task
+		decode(resp, &task)
+		if len(task.Comments) != 1 || task.Comments[0].Text != "Shipped" || task.Comments[0].Author != "" {
+			t.Errorf("Expected one comment by the owner, got %+v", task.Comments)
+		}
+		if resp := do("POST", "/tasks/1/comments", `{"text": " "}`, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for an empty comment, got %d", resp.StatusCode)
+		}
+	})
+
+	// Test deleting
+	t.Run("Delete", func(t *testing.T) {
+		if resp := do("DELETE", "/tasks/2", "", nil); resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("Expected 204, got %d", resp.StatusCode)
+		}
+		if resp := do("GET", "/tasks/2", "", nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404 after delete, got %d", resp.StatusCode)
+		}
+	})
+
+	// Test the OpenAPI document is valid JSON
+	t.Run("OpenAPI", func(t *testing.T) {
+		var doc map[string]any
+		decode(do("GET", "/openapi.json", "", nil), &doc)
+		if doc["openapi"] != "3.0.3" {
+			t.Errorf("Expected OpenAPI 3.0.3, got %v", doc["openapi"])
+		}
+	})
+}
+
+func TestAPIAuth(t *testing.T) {
+	storage.SetPath(filepath.Join(t.TempDir(), "tasks.json"))
+	tokens := network.NewTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	token, err := tokens.Create("script")
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	srv := httptest.NewServer(NewHandler(Config{Tokens: tokens}))
+	defer srv.Close()
+
+	get := func(path, auth string) int {
+		req, _ := http.NewRequest("GET", srv.URL+path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	for _, tc := range []struct {
+		path, auth string
+		want       int
+	}{
+		{"/tasks", "", http.StatusUnauthorized},
+		{"/tasks", "Bearer gtd_wrong", http.StatusUnauthorized},
+		{"/tasks", "Bearer " + token, http.StatusOK},
+		{"/openapi.json", "", http.StatusOK},
+	} {
+		if got := get(tc.path, tc.auth); got != tc.want {
+			t.Errorf("Expected %d for %s with %q, got %d", tc.want, tc.path, tc.auth, got)
+		}
+	}
+}
+
+func TestAPICORS(t *testing.T) {
+	storage.SetPath(filepath.Join(t.TempDir(), "tasks.json"))
+	tokens := network.NewTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	token, err := tokens.Create("page")
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	srv := httptest.NewServer(NewHandler(Config{Tokens: tokens, AllowOrigins: []string{"https://app.example.com"}}))
+	defer srv.Close()
+
+	do := func(method, origin string, header map[string]string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+"/tasks", nil)
+		req.Header.Set("Origin", origin)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	// Test preflight requests are answered without a token
+	t.Run("Preflight", func(t *testing.T) {
+		resp := do("OPTIONS", "https://app.example.com", map[string]string{"Access-Control-Request-Method": "PATCH"})
+		if resp.StatusCode != http.StatusNoContent || resp.Header.Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+			t.Errorf("Expected 204 allowing the origin, got %d %v", resp.StatusCode, resp.Header)
+		}
+		if !strings.Contains(resp.Header.Get("Access-Control-Allow-Methods"), "PATCH") ||
+			!strings.Contains(resp.Header.Get("Access-Control-Allow-Headers"), "If-Match") {
+			t.Errorf("Expected PATCH and If-Match allowed, got %v", resp.Header)
+		}
+		resp = do("OPTIONS", "https://evil.example.com", map[string]string{"Access-Control-Request-Method": "PATCH"})
+		if resp.Header.Get("Access-Control-Allow-Origin") != "" || resp.Header.Get("Access-Control-Allow-Methods") != "" {
+			t.Errorf("Expected nothing allowed for another origin, got %v", resp.Header)
+		}
+	})
+
+	// Test requests from an allowed page can read the response
+	t.Run("Request", func(t *testing.T) {
+		resp := do("GET", "https://app.example.com", map[string]string{"Authorization": "Bearer " + token})
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+			!strings.Contains(resp.Header.Get("Access-Control-Expose-Headers"), "ETag") {
+			t.Errorf("Expected 200 readable by the page, got %d %v", resp.StatusCode, resp.Header)
+		}
+		if resp := do("GET", "https://evil.example.com", map[string]string{"Authorization": "Bearer " + token}); resp.Header.Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("Expected no CORS headers for another origin, got %v", resp.Header)
+		}
+	})
+
+	// Test origins given on the command line are checked
+	for _, o := range []string{"*", "http://localhost:3000", "https://app.example.com"} {
+		if err := ValidateOrigin(o); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", o, err)
+		}
+	}
+	for _, o := range []string{"app.example.com", "https://app.example.com/", "ftp://x", ""} {
+		if err := ValidateOrigin(o); err == nil {
+			t.Errorf("Expected %q to be refused", o)
+		}
+	}
+}
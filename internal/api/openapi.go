@@ -0,0 +1,183 @@
+package api
+
+import "net/http"
+
+// GET /openapi.json
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(openAPIDocument))
+}
+
+// OpenAPI 3.0 description of the API, keep it in step with NewHandler
+const openAPIDocument = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "gotodo",
+    "description": "REST API of a gotodo task list. Changes to a task can be made conditional with If-Match and the task's ETag.",
+    "version": "1.0.0"
+  },
+  "components": {
+    "securitySchemes": {
+      "bearer": {"type": "http", "scheme": "bearer", "description": "token from 'gotodo serve token create', only required once one exists"}
+    },
+    "parameters": {
+      "id": {"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}},
+      "ifMatch": {"name": "If-Match", "in": "header", "schema": {"type": "string"}, "description": "ETag of the copy being changed"},
+      "ifNoneMatch": {"name": "If-None-Match", "in": "header", "schema": {"type": "string"}}
+    },
+    "schemas": {
+      "Comment": {
+        "type": "object",
+        "properties": {
+          "author": {"type": "string"},
+          "text": {"type": "string"},
+          "created_at": {"type": "string"}
+        }
+      },
+      "Task": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "integer"},
+          "content": {"type": "string"},
+          "done": {"type": "boolean"},
+          "created_at": {"type": "string"},
+          "completed_at": {"type": "string"},
+          "tags": {"type": "array", "items": {"type": "string"}},
+          "priority": {"type": "string", "enum": ["high", "medium", "low"]},
+          "project": {"type": "string"},
+          "due": {"type": "string", "format": "date"},
+          "scheduled": {"type": "string", "format": "date"},
+          "comments": {"type": "array", "items": {"$ref": "#/components/schemas/Comment"}},
+          "uuid": {"type": "string"},
+          "modified": {"type": "object", "additionalProperties": {"type": "string"}},
+          "updated": {"type": "string"}
+        }
+      },
+      "NewTask": {
+        "type": "object",
+        "required": ["content"],
+        "properties": {
+          "content": {"type": "string"},
+          "tags": {"type": "array", "items": {"type": "string"}},
+          "priority": {"type": "string", "enum": ["high", "medium", "low"]},
+          "project": {"type": "string"},
+          "due": {"type": "string", "format": "date"},
+          "scheduled": {"type": "string", "format": "date"}
+        }
+      },
+      "TaskPatch": {
+        "type": "object",
+        "properties": {
+          "content": {"type": "string"},
+          "done": {"type": "boolean"},
+          "tags": {"type": "array", "items": {"type": "string"}},
+          "priority": {"type": "string", "enum": ["", "high", "medium", "low"]},
+          "project": {"type": "string"},
+          "due": {"type": "string", "description": "YYYY-MM-DD, or empty to clear"},
+          "scheduled": {"type": "string", "description": "YYYY-MM-DD, or empty to clear"},
+          "completed_at": {"type": "string", "description": "when a task marked done by the patch was completed, now when left out"}
+        }
+      },
+      "Error": {
+        "type": "object",
+        "properties": {"error": {"type": "string"}}
+      }
+    },
+    "responses": {
+      "Error": {"description": "error", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+      "Task": {
+        "description": "the task",
+        "headers": {"ETag": {"schema": {"type": "string"}}},
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Task"}}}
+      }
+    }
+  },
+  "security": [{"bearer": []}],
+  "paths": {
+    "/tasks": {
+      "get": {
+        "summary": "List tasks",
+        "parameters": [
+          {"name": "done", "in": "query", "schema": {"type": "boolean"}},
+          {"name": "tag", "in": "query", "schema": {"type": "string"}},
+          {"name": "project", "in": "query", "schema": {"type": "string"}},
+          {"name": "priority", "in": "query", "schema": {"type": "string"}},
+          {"name": "due_before", "in": "query", "schema": {"type": "string", "format": "date"}},
+          {"name": "q", "in": "query", "schema": {"type": "string"}, "description": "text the content contains"},
+          {"$ref": "#/components/parameters/ifNoneMatch"}
+        ],
+        "responses": {
+          "200": {
+            "description": "matching tasks",
+            "headers": {"ETag": {"schema": {"type": "string"}}},
+            "content": {"application/json": {"schema": {"type": "array", "items": {"$ref": "#/components/schemas/Task"}}}}
+          },
+          "304": {"description": "not modified"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "summary": "Add a task",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/NewTask"}}}},
+        "responses": {
+          "201": {"$ref": "#/components/responses/Task"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "401": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/tasks/{id}": {
+      "parameters": [{"$ref": "#/components/parameters/id"}],
+      "get": {
+        "summary": "Get a task",
+        "parameters": [{"$ref": "#/components/parameters/ifNoneMatch"}],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Task"},
+          "304": {"description": "not modified"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "patch": {
+        "summary": "Change some fields of a task",
+        "parameters": [{"$ref": "#/components/parameters/ifMatch"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/TaskPatch"}}}},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Task"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "412": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "summary": "Delete a task",
+        "parameters": [{"$ref": "#/components/parameters/ifMatch"}],
+        "responses": {
+          "204": {"description": "deleted"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "412": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/tasks/{id}/comments": {
+      "parameters": [{"$ref": "#/components/parameters/id"}],
+      "post": {
+        "summary": "Comment on a task",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"type": "object", "required": ["text"], "properties": {"text": {"type": "string"}}}}}},
+        "responses": {
+          "201": {"$ref": "#/components/responses/Task"},
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "summary": "This document",
+        "security": [],
+        "responses": {"200": {"description": "OpenAPI document"}}
+      }
+    }
+  }
+}
+`
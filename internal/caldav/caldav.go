@@ -0,0 +1,696 @@
+package caldav
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+// longest request body accepted
+const maxBodySize = 1 << 20
+
+// XML namespaces of WebDAV, CalDAV and the calendar server extensions
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+	nsCS     = "http://calendarserver.org/ns/"
+)
+
+// the principal and calendar home is the root, the only calendar is below it
+const (
+	principalPath = "/"
+	calendarPath  = "/tasks/"
+)
+
+// content type of a task resource
+const calendarType = "text/calendar; charset=utf-8"
+
+// resource names clients may create, their task keeps the name as UUID
+// unless the VTODO has a UID
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]{0,127}$`)
+
+// longest VTODO UID kept as the UUID of a task
+const maxUIDSize = 255
+
+// settings of the CalDAV server
+type Config struct {
+	// clients must send one of these as their password, unless none are issued
+	Tokens *network.TokenStore
+	// name of the calendar shown by apps
+	Name string
+}
+
+type server struct {
+	cfg Config
+}
+
+// the HTTP handler serving the task list as a CalDAV calendar of VTODOs
+func NewHandler(cfg Config) http.Handler {
+	if cfg.Name == "" {
+		cfg.Name = "gotodo"
+	}
+	s := &server{cfg: cfg}
+	return s.authenticate(http.HandlerFunc(s.serve))
+}
+
+// check the token sent as a basic auth password, the user name is ignored.
+// Calendar apps cannot send bearer tokens.
+func (s *server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.Tokens == nil || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		enabled, err := s.cfg.Tokens.Enabled()
+		if err != nil {
+			http.Error(w, "server cannot check tokens", http.StatusInternalServerError)
+			return
+		}
+		if enabled {
+			_, token, ok := r.BasicAuth()
+			if !ok {
+				token, ok = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			}
+			valid := false
+			if ok {
+				if _, valid, err = s.cfg.Tokens.Verify(token); err != nil {
+					http.Error(w, "server cannot check tokens", http.StatusInternalServerError)
+					return
+				}
+			}
+			if !valid {
+				w.Header().Set("WWW-Authenticate", `Basic realm="gotodo"`)
+				http.Error(w, "log in with a token as password", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("DAV", "1, 3, calendar-access")
+	path := r.URL.Path
+	if path == "/.well-known/caldav" {
+		http.Redirect(w, r, principalPath, http.StatusMovedPermanently)
+		return
+	}
+	if path == "/tasks" {
+		path = calendarPath
+	}
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, PROPPATCH, REPORT")
+		w.WriteHeader(http.StatusOK)
+		return
+	case "PROPFIND":
+		s.propfind(w, r, path)
+		return
+	case "PROPPATCH":
+		s.proppatch(w, r, path)
+		return
+	case "REPORT":
+		s.report(w, r, path)
+		return
+	}
+
+	name, ok := resourceName(r.URL.EscapedPath())
+	if !ok {
+		if path == principalPath || path == calendarPath {
+			w.Header().Set("Allow", "OPTIONS, PROPFIND, PROPPATCH, REPORT")
+			http.Error(w, "method not allowed on a collection", http.StatusMethodNotAllowed)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.get(w, r, name)
+	case http.MethodPut:
+		s.put(w, r, name)
+	case http.MethodDelete:
+		s.delete(w, r, name)
+	default:
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// the name of a task resource in the calendar, without .ics. path is
+// still escaped, UIDs may contain slashes.
+func resourceName(path string) (string, bool) {
+	rest, ok := strings.CutPrefix(path, calendarPath)
+	if !ok || strings.Contains(rest, "/") {
+		return "", false
+	}
+	name, ok := strings.CutSuffix(rest, ".ics")
+	if !ok || name == "" {
+		return "", false
+	}
+	name, err := url.PathUnescape(name)
+	return name, err == nil
+}
+
+func href(t storage.Task) string {
+	return calendarPath + url.PathEscape(uid(t)) + ".ics"
+}
+
+// strong ETag of an encoded task
+func etag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// changes whenever any task changes, so apps know to fetch the calendar again
+func ctag(tasks []storage.Task) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d tasks\n", len(tasks))
+	for _, t := range tasks {
+		h.Write(Encode(t))
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// report whether an If-None-Match or If-Match header lists tag
+func matches(header, tag string) bool {
+	for _, h := range strings.Split(header, ",") {
+		h = strings.TrimPrefix(strings.TrimSpace(h), "W/")
+		if h == "*" || h == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// the task with the given resource name, or else with the given VTODO
+// UID when it is not empty
+func find(name, todoUID string) (storage.Task, bool, error) {
+	tasks, err := storage.List()
+	if err != nil {
+		return storage.Task{}, false, err
+	}
+	for _, t := range tasks {
+		if uid(t) == name {
+			return t, true, nil
+		}
+	}
+	for _, t := range tasks {
+		if todoUID != "" && uid(t) == todoUID {
+			return t, true, nil
+		}
+	}
+	return storage.Task{}, false, nil
+}
+
+// report whether a VTODO UID can be kept as the UUID of a task
+func validUID(u string) bool {
+	if u == "" || len(u) > maxUIDSize || !utf8.ValidString(u) {
+		return false
+	}
+	for _, c := range u {
+		if unicode.IsControl(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// GET and HEAD of a task
+func (s *server) get(w http.ResponseWriter, r *http.Request, name string) {
+	t, ok, err := find(name, "")
+	if err != nil {
+		http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	data := Encode(t)
+	tag := etag(data)
+	w.Header().Set("ETag", tag)
+	if inm := r.Header.Get("If-None-Match"); inm != "" && matches(inm, tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", calendarType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(data)
+	}
+}
+
+// PUT of a task, creating it when the name is new. If-Match and
+// If-None-Match: * keep apps from overwriting each other's changes.
+func (s *server) put(w http.ResponseWriter, r *http.Request, name string) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	td, err := ParseTodo(data)
+	if errors.Is(err, ErrNoTodo) {
+		// apps also try to store events in any calendar they find
+		writeError(w, http.StatusForbidden, nsCalDAV, "supported-calendar-component", "only tasks (VTODO) can be stored")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusForbidden, nsCalDAV, "valid-calendar-data", err.Error())
+		return
+	}
+
+	// apps that lost track of a task may store it under a new name
+	t, exists, err := find(name, td.UID)
+	if err != nil {
+		http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+	if !putAllowed(r, t, exists) {
+		http.Error(w, "the task has changed, fetch it again", http.StatusPreconditionFailed)
+		return
+	}
+
+	if exists {
+		_, err := storage.UpdateIf(t.ID, td.Patch(), func(cur storage.Task) error {
+			if !putAllowed(r, cur, true) {
+				return errChanged
+			}
+			return nil
+		})
+		if errors.Is(err, errChanged) {
+			http.Error(w, "the task has changed, fetch it again", http.StatusPreconditionFailed)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// stored as gotodo encodes it, not as sent, so no ETag (RFC 4791 5.3.4)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if !namePattern.MatchString(name) {
+		http.Error(w, "invalid resource name", http.StatusBadRequest)
+		return
+	}
+	// the UID is how apps and other calendars know the task
+	id := name
+	if td.UID != "" {
+		if !validUID(td.UID) {
+			writeError(w, http.StatusForbidden, nsCalDAV, "valid-calendar-data", "invalid UID")
+			return
+		}
+		id = td.UID
+	}
+	created, err := storage.AddTaskWithUUID(storage.Task{
+		Content:  td.Summary,
+		Tags:     td.Categories,
+		Priority: td.Priority,
+		Project:  td.Project,
+		Due:      td.Due,
+	}, id)
+	if errors.Is(err, storage.ErrUUIDTaken) {
+		// another app stored it first
+		http.Error(w, "the task has changed, fetch it again", http.StatusPreconditionFailed)
+		return
+	}
+	if err == nil && td.Done {
+		p := td.Patch()
+		_, err = storage.Update(created.ID, storage.TaskPatch{Done: p.Done, CompletedAt: p.CompletedAt})
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// served under its UID from now on
+	if uid(created) != name {
+		w.Header().Set("Location", href(created))
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// the app sent If-Match or If-None-Match for an outdated copy of the task
+var errChanged = errors.New("task has changed")
+
+// check If-Match and If-None-Match of a PUT against the current version of
+// the task, if any
+func putAllowed(r *http.Request, t storage.Task, exists bool) bool {
+	im, inm := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+	if !exists {
+		return im == ""
+	}
+	tag := etag(Encode(t))
+	if inm != "" && matches(inm, tag) {
+		return false
+	}
+	return im == "" || matches(im, tag)
+}
+
+// DELETE of a task, honouring If-Match
+func (s *server) delete(w http.ResponseWriter, r *http.Request, name string) {
+	t, ok, err := find(name, "")
+	if err != nil {
+		http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	err = storage.DeleteIf(t.ID, func(cur storage.Task) error {
+		if im := r.Header.Get("If-Match"); im != "" && !matches(im, etag(Encode(cur))) {
+			return errChanged
+		}
+		return nil
+	})
+	if errors.Is(err, errChanged) {
+		http.Error(w, "the task has changed, fetch it again", http.StatusPreconditionFailed)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to delete task", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// a WebDAV precondition error
+func writeError(w http.ResponseWriter, status int, space, condition, message string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>`+"\n"+
+		`<error xmlns="DAV:"><%s xmlns="%s"/><responsedescription>%s</responsedescription></error>`,
+		condition, space, escapeXML(message))
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// an element of a request naming a property or component
+type anyElement struct {
+	XMLName xml.Name
+}
+
+// body of PROPFIND, PROPPATCH and the REPORTs
+type request struct {
+	XMLName  xml.Name
+	AllProp  *struct{} `xml:"DAV: allprop"`
+	PropName *struct{} `xml:"DAV: propname"`
+	Prop     struct {
+		Names []anyElement `xml:",any"`
+	} `xml:"DAV: prop"`
+	// PROPPATCH
+	Set    []request `xml:"DAV: set"`
+	Remove []request `xml:"DAV: remove"`
+	// calendar-multiget
+	Hrefs []string `xml:"DAV: href"`
+	// calendar-query, only the component filters are looked at
+	Filter struct {
+		CompFilter compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+type compFilter struct {
+	Name        string       `xml:"name,attr"`
+	CompFilters []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// read an XML request body, an empty one means allprop
+func readRequest(w http.ResponseWriter, r *http.Request) (request, bool) {
+	var req request
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return req, false
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		req.AllProp = &struct{}{}
+		return req, true
+	}
+	if err := xml.Unmarshal(data, &req); err != nil {
+		http.Error(w, "invalid XML body", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// one property in a response, Inner is raw XML
+type prop struct {
+	XMLName xml.Name
+	Inner   string `xml:",innerxml"`
+}
+
+type propList struct {
+	Props []prop
+}
+
+type propstat struct {
+	Prop   propList `xml:"DAV: prop"`
+	Status string   `xml:"DAV: status"`
+}
+
+type response struct {
+	Href      string     `xml:"DAV: href"`
+	Propstats []propstat `xml:"DAV: propstat"`
+	// instead of Propstats, for a resource that does not exist
+	Status string `xml:"DAV: status,omitempty"`
+}
+
+type multistatus struct {
+	XMLName   xml.Name   `xml:"DAV: multistatus"`
+	Responses []response `xml:"DAV: response"`
+}
+
+func hrefXML(path string) string {
+	return `<href xmlns="DAV:">` + escapeXML(path) + `</href>`
+}
+
+func statusLine(code int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code))
+}
+
+// the properties a resource has, keyed by name
+type properties map[xml.Name]string
+
+func (s *server) principalProps() properties {
+	return properties{
+		{Space: nsDAV, Local: "resourcetype"}:           `<collection xmlns="DAV:"/><principal xmlns="DAV:"/>`,
+		{Space: nsDAV, Local: "displayname"}:            escapeXML(s.cfg.Name),
+		{Space: nsDAV, Local: "current-user-principal"}: hrefXML(principalPath),
+		{Space: nsDAV, Local: "principal-URL"}:          hrefXML(principalPath),
+		{Space: nsCalDAV, Local: "calendar-home-set"}:   hrefXML(principalPath),
+	}
+}
+
+func (s *server) calendarProps(tasks []storage.Task) properties {
+	tag := ctag(tasks)
+	return properties{
+		{Space: nsDAV, Local: "resourcetype"}:           `<collection xmlns="DAV:"/><calendar xmlns="` + nsCalDAV + `"/>`,
+		{Space: nsDAV, Local: "displayname"}:            escapeXML(s.cfg.Name),
+		{Space: nsDAV, Local: "current-user-principal"}: hrefXML(principalPath),
+		{Space: nsDAV, Local: "owner"}:                  hrefXML(principalPath),
+		{Space: nsDAV, Local: "getetag"}:                escapeXML(tag),
+		{Space: nsCS, Local: "getctag"}:                 escapeXML(tag),
+		{Space: nsDAV, Local: "current-user-privilege-set"}: `<privilege xmlns="DAV:"><read/></privilege>` +
+			`<privilege xmlns="DAV:"><write/></privilege><privilege xmlns="DAV:"><write-content/></privilege>` +
+			`<privilege xmlns="DAV:"><bind/></privilege><privilege xmlns="DAV:"><unbind/></privilege>`,
+		{Space: nsDAV, Local: "supported-report-set"}: `<supported-report xmlns="DAV:"><report><calendar-query xmlns="` + nsCalDAV + `"/></report></supported-report>` +
+			`<supported-report xmlns="DAV:"><report><calendar-multiget xmlns="` + nsCalDAV + `"/></report></supported-report>`,
+		{Space: nsCalDAV, Local: "supported-calendar-component-set"}: `<comp xmlns="` + nsCalDAV + `" name="VTODO"/>`,
+	}
+}
+
+func taskProps(t storage.Task) properties {
+	data := Encode(t)
+	p := properties{
+		{Space: nsDAV, Local: "resourcetype"}:     "",
+		{Space: nsDAV, Local: "getetag"}:          escapeXML(etag(data)),
+		{Space: nsDAV, Local: "getcontenttype"}:   calendarType + "; component=VTODO",
+		{Space: nsDAV, Local: "getcontentlength"}: strconv.Itoa(len(data)),
+		{Space: nsCalDAV, Local: "calendar-data"}: escapeXML(string(data)),
+	}
+	if u, err := time.Parse(time.RFC3339Nano, t.Updated); err == nil {
+		p[xml.Name{Space: nsDAV, Local: "getlastmodified"}] = u.UTC().Format(http.TimeFormat)
+	}
+	return p
+}
+
+// the response for one resource: the requested properties it has, and a
+// 404 for the ones it has not. allprop leaves out the task data.
+func (req request) respond(path string, props properties) response {
+	var found, missing []prop
+	if req.AllProp != nil || req.PropName != nil {
+		for name, value := range props {
+			if name.Local == "calendar-data" && req.AllProp != nil {
+				continue
+			}
+			if req.PropName != nil {
+				value = ""
+			}
+			found = append(found, prop{XMLName: name, Inner: value})
+		}
+	} else {
+		for _, n := range req.Prop.Names {
+			if value, ok := props[n.XMLName]; ok {
+				found = append(found, prop{XMLName: n.XMLName, Inner: value})
+			} else {
+				missing = append(missing, prop{XMLName: n.XMLName})
+			}
+		}
+	}
+	byName := func(a, b prop) int { return strings.Compare(a.XMLName.Local, b.XMLName.Local) }
+	slices.SortFunc(found, byName)
+	resp := response{Href: path}
+	if len(found) > 0 {
+		resp.Propstats = append(resp.Propstats, propstat{Prop: propList{found}, Status: statusLine(http.StatusOK)})
+	}
+	if len(missing) > 0 {
+		resp.Propstats = append(resp.Propstats, propstat{Prop: propList{missing}, Status: statusLine(http.StatusNotFound)})
+	}
+	return resp
+}
+
+func writeMultistatus(w http.ResponseWriter, responses []response) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	_ = enc.Encode(multistatus{Responses: responses})
+}
+
+// PROPFIND of the principal, the calendar or a task. Depth 1 adds the
+// members of a collection, infinity is treated as 1.
+func (s *server) propfind(w http.ResponseWriter, r *http.Request, path string) {
+	req, ok := readRequest(w, r)
+	if !ok {
+		return
+	}
+	deep := r.Header.Get("Depth") != "0"
+	tasks, err := storage.List()
+	if err != nil {
+		http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+	var responses []response
+	switch path {
+	case principalPath:
+		responses = append(responses, req.respond(principalPath, s.principalProps()))
+		if deep {
+			responses = append(responses, req.respond(calendarPath, s.calendarProps(tasks)))
+		}
+	case calendarPath:
+		responses = append(responses, req.respond(calendarPath, s.calendarProps(tasks)))
+		if deep {
+			for _, t := range tasks {
+				responses = append(responses, req.respond(href(t), taskProps(t)))
+			}
+		}
+	default:
+		name, ok := resourceName(r.URL.EscapedPath())
+		t, found, _ := find(name, "")
+		if !ok || !found {
+			http.NotFound(w, r)
+			return
+		}
+		responses = append(responses, req.respond(href(t), taskProps(t)))
+	}
+	writeMultistatus(w, responses)
+}
+
+// PROPPATCH, e.g. apps setting a colour: nothing can be changed
+func (s *server) proppatch(w http.ResponseWriter, r *http.Request, path string) {
+	req, ok := readRequest(w, r)
+	if !ok {
+		return
+	}
+	var refused []prop
+	for _, group := range append(req.Set, req.Remove...) {
+		for _, n := range group.Prop.Names {
+			refused = append(refused, prop{XMLName: n.XMLName})
+		}
+	}
+	resp := response{Href: path}
+	if len(refused) > 0 {
+		resp.Propstats = []propstat{{Prop: propList{refused}, Status: statusLine(http.StatusForbidden)}}
+	}
+	writeMultistatus(w, []response{resp})
+}
+
+// REPORT calendar-query and calendar-multiget on the calendar
+func (s *server) report(w http.ResponseWriter, r *http.Request, path string) {
+	if path != calendarPath {
+		http.Error(w, "reports are only supported on "+calendarPath, http.StatusForbidden)
+		return
+	}
+	req, ok := readRequest(w, r)
+	if !ok {
+		return
+	}
+	tasks, err := storage.List()
+	if err != nil {
+		http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+	responses := []response{}
+	switch req.XMLName {
+	case xml.Name{Space: nsCalDAV, Local: "calendar-query"}:
+		if !wantsTodos(req.Filter.CompFilter) {
+			break
+		}
+		for _, t := range tasks {
+			responses = append(responses, req.respond(href(t), taskProps(t)))
+		}
+	case xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}:
+		for _, h := range req.Hrefs {
+			p := h
+			if u, err := url.Parse(h); err == nil {
+				p = u.EscapedPath()
+			}
+			name, ok := resourceName(p)
+			t, found, _ := find(name, "")
+			if !ok || !found {
+				responses = append(responses, response{Href: h, Status: statusLine(http.StatusNotFound)})
+				continue
+			}
+			responses = append(responses, req.respond(href(t), taskProps(t)))
+		}
+	default:
+		writeError(w, http.StatusForbidden, nsDAV, "supported-report", "only calendar-query and calendar-multiget are supported")
+		return
+	}
+	writeMultistatus(w, responses)
+}
+
+// report whether a calendar-query filter lets VTODOs through; queries for
+// other components, e.g. events, get nothing
+func wantsTodos(f compFilter) bool {
+	if f.Name == "" || len(f.CompFilters) == 0 {
+		return true
+	}
+	for _, c := range f.CompFilters {
+		if strings.EqualFold(c.Name, "VTODO") {
+			return true
+		}
+	}
+	return false
+}
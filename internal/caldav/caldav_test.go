@@ -0,0 +1,289 @@
+package caldav
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+// a task as Thunderbird sends it, with a folded line and an alarm
+const thunderbirdTodo = "BEGIN:VCALENDAR\r\n" +
+	"PRODID:-//Mozilla.org/NONSGML Mozilla Calendar V1.1//EN\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VTODO\r\n" +
+	"UID:6a1c1b1e-3d2f-4c3b-9d7e-0f1e2d3c4b5a\r\n" +
+	"SUMMARY:Renew the passport\\, both of them\r\n" +
+	"CATEGORIES:admin,travel\r\n" +
+	"PRIORITY:3\r\n" +
+	"DUE;TZID=Europe/Berlin:20261105T090000\r\n" +
+	"X-GOTODO-PROJECT:ho\r\n" +
+	" me\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"SUMMARY:Not the task\r\n" +
+	"END:VALARM\r\n" +
+	"END:VTODO\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestICalendar(t *testing.T) {
+	// Test parsing what calendar apps send
+	t.Run("Parse", func(t *testing.T) {
+		td, err := ParseTodo([]byte(thunderbirdTodo))
+		if err != nil {
+			t.Fatalf("Failed to parse: %v", err)
+		}
+		if td.Summary != "Renew the passport, both of them" {
+			t.Errorf("Expected unescaped summary, got %q", td.Summary)
+		}
+		if td.Priority != "high" || td.Due != "2026-11-05" || td.Project != "home" || td.Done {
+			t.Errorf("Expected high priority home task due 2026-11-05, got %+v", td)
+		}
+		if len(td.Categories) != 2 || td.Categories[1] != "travel" {
+			t.Errorf("Expected two categories, got %v", td.Categories)
+		}
+		event := strings.ReplaceAll(thunderbirdTodo, "VTODO", "VEVENT")
+		if _, err := ParseTodo([]byte(event)); err != ErrNoTodo {
+			t.Errorf("Expected ErrNoTodo for an event, got %v", err)
+		}
+	})
+
+	// Test only the properties a VTODO has are patched
+	t.Run("Patch", func(t *testing.T) {
+		td, err := ParseTodo([]byte(thunderbirdTodo))
+		if err != nil {
+			t.Fatalf("Failed to parse: %v", err)
+		}
+		p := td.Patch()
+		if p.Done != nil || p.CompletedAt != nil {
+			t.Errorf("Expected the status left alone without STATUS, got %+v", p)
+		}
+		if p.Content == nil || p.Tags == nil || p.Priority == nil || p.Project == nil || p.Due == nil {
+			t.Errorf("Expected the properties sent patched, got %+v", p)
+		}
+		bare := "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nSUMMARY:Bare\r\nCOMPLETED:20261101T080000Z\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+		td, err = ParseTodo([]byte(bare))
+		if err != nil {
+			t.Fatalf("Failed to parse: %v", err)
+		}
+		p = td.Patch()
+		if p.Tags != nil || p.Priority != nil || p.Project != nil || p.Due != nil {
+			t.Errorf("Expected missing properties left alone, got %+v", p)
+		}
+		if p.Done == nil || !*p.Done || p.CompletedAt == nil {
+			t.Fatalf("Expected a completion time to mark the task done, got %+v", p)
+		}
+		if c, err := storage.ParseTime(*p.CompletedAt); err != nil || !c.Equal(time.Date(2026, 11, 1, 8, 0, 0, 0, time.UTC)) {
+			t.Errorf("Expected the completion time kept, got %q", *p.CompletedAt)
+		}
+	})
+
+	// Test a task survives encoding and parsing
+	t.Run("RoundTrip", func(t *testing.T) {
+		task := storage.Task{
+			ID: 7, Content: strings.Repeat("Long; task, with\nlines ", 6), Done: true,
+			Tags: []string{"a,b"}, Priority: "low", Due: "2026-01-31", UUID: "abc",
+			CompletedAt: "2026-01-30 10:00:00 +0000 UTC",
+		}
+		data := Encode(task)
+		for _, l := range strings.Split(string(data), "\r\n") {
+			if len(l) > 75 {
+				t.Errorf("Expected lines of at most 75 octets, got %d: %q", len(l), l)
+			}
+		}
+		if !strings.Contains(string(data), "COMPLETED:20260130T100000Z") {
+			t.Errorf("Expected the completion time, got:\n%s", data)
+		}
+		td, err := ParseTodo(data)
+		if err != nil {
+			t.Fatalf("Failed to parse: %v", err)
+		}
+		if td.UID != "abc" || td.Summary != strings.TrimSpace(task.Content) || !td.Done || td.Priority != "low" ||
+			td.Due != "2026-01-31" || len(td.Categories) != 1 || td.Categories[0] != "a,b" {
+			t.Errorf("Round trip changed the task: %+v", td)
+		}
+	})
+}
+
+func TestServer(t *testing.T) {
+	storage.SetPath(filepath.Join(t.TempDir(), "tasks.json"))
+	srv := httptest.NewServer(NewHandler(Config{}))
+	defer srv.Close()
+
+	do := func(method, path, body string, header map[string]string) (*http.Response, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to build request: %v", err)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+	storage.AddTask(storage.Task{Content: "Existing task", Due: "2026-12-01", Priority: "medium"})
+	tasks, _ := storage.List()
+	existing := "/tasks/" + tasks[0].UUID + ".ics"
+
+	// Test apps find the calendar of tasks
+	t.Run("Discovery", func(t *testing.T) {
+		resp, _ := do("PROPFIND", "/.well-known/caldav", "", nil)
+		if resp.Request.URL.Path != "/" {
+			t.Errorf("Expected a redirect to /, ended at %s", resp.Request.URL.Path)
+		}
+		resp, body := do("PROPFIND", "/", `<?xml version="1.0"?><propfind xmlns="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+			<prop><current-user-principal/><C:calendar-home-set/><quota-used-bytes/></prop></propfind>`, map[string]string{"Depth": "0"})
+		if resp.StatusCode != http.StatusMultiStatus {
+			t.Fatalf("Expected 207, got %d", resp.StatusCode)
+		}
+		if !strings.Contains(body, "calendar-home-set") || !strings.Contains(body, "404 Not Found") {
+			t.Errorf("Expected the home set and a 404 for the unknown property, got %s", body)
+		}
+		_, body = do("PROPFIND", "/tasks/", "", map[string]string{"Depth": "1"})
+		if !strings.Contains(body, `name="VTODO"`) || !strings.Contains(body, existing) {
+			t.Errorf("Expected a VTODO calendar listing the task, got %s", body)
+		}
+	})
+
+	// Test getting a task as iCalendar, with an ETag
+	t.Run("Get", func(t *testing.T) {
+		resp, body := do("GET", existing, "", nil)
+		if resp.StatusCode != http.StatusOK || !strings.Contains(body, "DUE;VALUE=DATE:20261201") || !strings.Contains(body, "PRIORITY:5") {
+			t.Fatalf("Expected the task with due date and priority, got %d:\n%s", resp.StatusCode, body)
+		}
+		resp, _ = do("GET", existing, "", map[string]string{"If-None-Match": resp.Header.Get("ETag")})
+		if resp.StatusCode != http.StatusNotModified {
+			t.Errorf("Expected 304, got %d", resp.StatusCode)
+		}
+	})
+
+	// Test apps adding and changing tasks
+	t.Run("Put", func(t *testing.T) {
+		path := "/tasks/6a1c1b1e-3d2f-4c3b-9d7e-0f1e2d3c4b5a.ics"
+		resp, _ := do("PUT", path, thunderbirdTodo, map[string]string{"If-None-Match": "*"})
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected 201, got %d", resp.StatusCode)
+		}
+		resp, _ = do("PUT", path, thunderbirdTodo, map[string]string{"If-None-Match": "*"})
+		if resp.StatusCode != http.StatusPreconditionFailed {
+			t.Errorf("Expected 412 creating it twice, got %d", resp.StatusCode)
+		}
+		resp, _ = do("GET", path, "", nil)
+		tag := resp.Header.Get("ETag")
+		done := strings.Replace(thunderbirdTodo, "PRIORITY:3", "STATUS:COMPLETED\r\nCOMPLETED:20261101T080000Z", 1)
+		if resp, _ := do("PUT", path, done, map[string]string{"If-Match": `"stale"`}); resp.StatusCode != http.StatusPreconditionFailed {
+			t.Errorf("Expected 412 for a stale ETag, got %d", resp.StatusCode)
+		}
+		if resp, _ := do("PUT", path, done, map[string]string{"If-Match": tag}); resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("Expected 204, got %d", resp.StatusCode)
+		}
+		tasks, _ := storage.List()
+		if len(tasks) != 2 || !tasks[1].Done || tasks[1].Priority != "high" || tasks[1].Project != "home" {
+			t.Errorf("Expected the task done keeping its priority, got %+v", tasks)
+		}
+		if c, ok := tasks[1].Completed(); !ok || !c.Equal(time.Date(2026, 11, 1, 8, 0, 0, 0, time.UTC)) {
+			t.Errorf("Expected the completion time sent, got %q", tasks[1].CompletedAt)
+		}
+		event := strings.ReplaceAll(thunderbirdTodo, "VTODO", "VEVENT")
+		if resp, _ := do("PUT", "/tasks/event.ics", event, nil); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected 403 for an event, got %d", resp.StatusCode)
+		}
+	})
+
+	// Test reports return the calendar data
+	t.Run("Report", func(t *testing.T) {
+		resp, body := do("REPORT", "/tasks/", `<C:calendar-multiget xmlns="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+			<prop><getetag/><C:calendar-data/></prop><href>`+existing+`</href><href>/tasks/gone.ics</href></C:calendar-multiget>`,
+			map[string]string{"Depth": "1"})
+		if resp.StatusCode != http.StatusMultiStatus || strings.Count(body, "BEGIN:VTODO") != 1 || !strings.Contains(body, "404 Not Found") {
+			t.Errorf("Expected one task and one 404, got %s", body)
+		}
+		query := `<C:calendar-query xmlns="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><prop><getetag/></prop>
+			<C:filter><C:comp-filter name="VCALENDAR"><C:comp-filter name="%s"/></C:comp-filter></C:filter></C:calendar-query>`
+		if _, body := do("REPORT", "/tasks/", strings.Replace(query, "%s", "VTODO", 1), nil); strings.Count(body, "<getetag") != 2 {
+			t.Errorf("Expected both tasks for a VTODO query, got %s", body)
+		}
+		if _, body := do("REPORT", "/tasks/", strings.Replace(query, "%s", "VEVENT", 1), nil); strings.Contains(body, "getetag") {
+			t.Errorf("Expected no tasks for a VEVENT query, got %s", body)
+		}
+	})
+
+	// Test apps deleting tasks
+	t.Run("Delete", func(t *testing.T) {
+		if resp, _ := do("DELETE", existing, "", map[string]string{"If-Match": `"stale"`}); resp.StatusCode != http.StatusPreconditionFailed {
+			t.Errorf("Expected 412 for a stale ETag, got %d", resp.StatusCode)
+		}
+		if resp, _ := do("DELETE", existing, "", nil); resp.StatusCode != http.StatusNoContent {
+			t.Errorf("Expected 204, got %d", resp.StatusCode)
+		}
+		if resp, _ := do("GET", existing, "", nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected 404 after delete, got %d", resp.StatusCode)
+		}
+	})
+
+	// Test tasks keep the UID apps gave them and are found by it
+	t.Run("UID", func(t *testing.T) {
+		todo := strings.Replace(thunderbirdTodo, "6a1c1b1e-3d2f-4c3b-9d7e-0f1e2d3c4b5a", "work/42@example.com", 1)
+		resp, _ := do("PUT", "/tasks/new-name.ics", todo, map[string]string{"If-None-Match": "*"})
+		if resp.StatusCode != http.StatusCreated || resp.Header.Get("Location") != "/tasks/work%2F42@example.com.ics" {
+			t.Fatalf("Expected 201 with the UID as location, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+		}
+		resp, body := do("GET", resp.Header.Get("Location"), "", nil)
+		if resp.StatusCode != http.StatusOK || !strings.Contains(body, "UID:work/42@example.com") {
+			t.Errorf("Expected the task under its UID, got %d:\n%s", resp.StatusCode, body)
+		}
+		renamed := strings.Replace(todo, "Renew the passport", "Renew the passports", 1)
+		if resp, _ := do("PUT", "/tasks/other-name.ics", renamed, nil); resp.StatusCode != http.StatusNoContent {
+			t.Errorf("Expected 204 storing the same UID under another name, got %d", resp.StatusCode)
+		}
+		tasks, _ := storage.List()
+		found := 0
+		for _, task := range tasks {
+			if task.UUID == "work/42@example.com" {
+				found++
+				if !strings.HasPrefix(task.Content, "Renew the passports") {
+					t.Errorf("Expected the task updated, got %q", task.Content)
+				}
+			}
+		}
+		if found != 1 {
+			t.Errorf("Expected one task with the UID, got %d in %+v", found, tasks)
+		}
+	})
+}
+
+func TestAuthentication(t *testing.T) {
+	storage.SetPath(filepath.Join(t.TempDir(), "tasks.json"))
+	tokens := network.NewTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	token, err := tokens.Create("phone")
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	srv := httptest.NewServer(NewHandler(Config{Tokens: tokens}))
+	defer srv.Close()
+
+	for password, want := range map[string]int{token: http.StatusMultiStatus, "wrong": http.StatusUnauthorized} {
+		req, _ := http.NewRequest("PROPFIND", srv.URL+"/tasks/", nil)
+		req.SetBasicAuth("anyone", password)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("Expected %d, got %d", want, resp.StatusCode)
+		}
+	}
+}
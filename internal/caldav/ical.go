@@ -0,0 +1,398 @@
+package caldav
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+// iCalendar (RFC 5545) layouts of dates and UTC times
+const (
+	icalDate = "20060102"
+	icalTime = "20060102T150405Z"
+)
+
+// encode a task as an iCalendar object holding one VTODO
+func Encode(t storage.Task) []byte {
+	var b bytes.Buffer
+	line := func(name, value string) {
+		writeFolded(&b, name+":"+value)
+	}
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//gotodo//gotodo//EN")
+	line("BEGIN", "VTODO")
+	line("UID", uid(t))
+
+	// never the current time, the ETag must not change between requests
+	stamp := time.Unix(0, 0)
+	if u, err := time.Parse(time.RFC3339Nano, t.Updated); err == nil {
+		stamp = u
+	} else if c, ok := t.Created(); ok {
+		stamp = c
+	}
+	line("DTSTAMP", stamp.UTC().Format(icalTime))
+	line("LAST-MODIFIED", stamp.UTC().Format(icalTime))
+	if c, ok := t.Created(); ok {
+		line("CREATED", c.UTC().Format(icalTime))
+	}
+
+	line("SUMMARY", escapeText(t.Content))
+	if len(t.Comments) > 0 {
+		var notes []string
+		for _, c := range t.Comments {
+			if c.Author != "" {
+				notes = append(notes, c.Author+": "+c.Text)
+			} else {
+				notes = append(notes, c.Text)
+			}
+		}
+		line("DESCRIPTION", escapeText(strings.Join(notes, "\n")))
+	}
+	if len(t.Tags) > 0 {
+		tags := make([]string, len(t.Tags))
+		for i, tag := range t.Tags {
+			tags[i] = escapeText(tag)
+		}
+		line("CATEGORIES", strings.Join(tags, ","))
+	}
+	if p := priorityOf(t.Priority); p != 0 {
+		line("PRIORITY", strconv.Itoa(p))
+	}
+	if d, ok := t.DueDate(); ok {
+		line("DUE;VALUE=DATE", d.Format(icalDate))
+	}
+	if t.Done {
+		line("STATUS", "COMPLETED")
+		line("PERCENT-COMPLETE", "100")
+		if c, ok := t.Completed(); ok {
+			line("COMPLETED", c.UTC().Format(icalTime))
+		}
+	} else {
+		line("STATUS", "NEEDS-ACTION")
+	}
+	if t.Project != "" {
+		line("X-GOTODO-PROJECT", escapeText(t.Project))
+	}
+	line("X-GOTODO-ID", strconv.Itoa(t.ID))
+	line("END", "VTODO")
+	line("END", "VCALENDAR")
+	return b.Bytes()
+}
+
+// the VTODO UID of a task; tasks from before sync have no UUID and are
+// named after their ID
+func uid(t storage.Task) string {
+	if t.UUID != "" {
+		return t.UUID
+	}
+	return fmt.Sprintf("gotodo-%d", t.ID)
+}
+
+// iCalendar priority of a gotodo one: 1 is highest, 9 lowest, 0 undefined
+func priorityOf(p string) int {
+	switch p {
+	case "high":
+		return 1
+	case "medium":
+		return 5
+	case "low":
+		return 9
+	}
+	return 0
+}
+
+// gotodo priority of an iCalendar one, as calendar apps split the range
+func priorityFrom(p int) string {
+	switch {
+	case p >= 1 && p <= 4:
+		return "high"
+	case p == 5:
+		return "medium"
+	case p >= 6 && p <= 9:
+		return "low"
+	}
+	return ""
+}
+
+// write a content line, folded after 75 octets without splitting a
+// UTF-8 sequence
+func writeFolded(b *bytes.Buffer, s string) {
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		// the leading space counts towards the next line
+		limit = 74
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", "")
+
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func unescapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// split a list value on commas that are not escaped
+func splitList(s string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// returned by ParseTodo for calendar objects without a task, e.g. events
+var ErrNoTodo = errors.New("no VTODO in calendar object")
+
+// a task as sent by a calendar app
+type Todo struct {
+	UID      string
+	Summary  string
+	Done     bool
+	Priority string
+	// YYYY-MM-DD in local time, empty for none
+	Due        string
+	Categories []string
+	Project    string
+	// the COMPLETED time, zero when not sent
+	Completed time.Time
+
+	// names of the properties the VTODO had
+	seen map[string]bool
+}
+
+// the change of a task that makes it match the todo. Properties the VTODO
+// did not have are left alone, apps drop the ones they do not know.
+func (td Todo) Patch() storage.TaskPatch {
+	var p storage.TaskPatch
+	if td.seen["SUMMARY"] {
+		p.Content = &td.Summary
+	}
+	if td.seen["STATUS"] || td.seen["COMPLETED"] {
+		p.Done = &td.Done
+		if td.Done && !td.Completed.IsZero() {
+			completed := td.Completed.Local().String()
+			p.CompletedAt = &completed
+		}
+	}
+	if td.seen["CATEGORIES"] {
+		tags := td.Categories
+		p.Tags = &tags
+	}
+	if td.seen["PRIORITY"] {
+		p.Priority = &td.Priority
+	}
+	if td.seen["X-GOTODO-PROJECT"] {
+		p.Project = &td.Project
+	}
+	if td.seen["DUE"] {
+		p.Due = &td.Due
+	}
+	return p
+}
+
+// one property of a content line
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// parse an iCalendar object and return its first VTODO
+func ParseTodo(data []byte) (Todo, error) {
+	td := Todo{seen: map[string]bool{}}
+	status := ""
+	var depth []string
+	found, inTodo := false, false
+	for _, raw := range unfold(string(data)) {
+		if raw == "" {
+			continue
+		}
+		p, err := parseLine(raw)
+		if err != nil {
+			return Todo{}, err
+		}
+		switch p.name {
+		case "BEGIN":
+			depth = append(depth, strings.ToUpper(p.value))
+			// properties of alarms inside the VTODO are not the task's
+			inTodo = !found && len(depth) == 2 && depth[0] == "VCALENDAR" && depth[1] == "VTODO"
+			continue
+		case "END":
+			if len(depth) == 0 {
+				return Todo{}, fmt.Errorf("unexpected END:%s", p.value)
+			}
+			if inTodo {
+				found = true
+			}
+			depth = depth[:len(depth)-1]
+			inTodo = !found && len(depth) == 2 && depth[1] == "VTODO"
+			continue
+		}
+		if !inTodo {
+			continue
+		}
+		td.seen[p.name] = true
+		switch p.name {
+		case "UID":
+			td.UID = p.value
+		case "SUMMARY":
+			td.Summary = strings.TrimSpace(unescapeText(p.value))
+		case "STATUS":
+			status = strings.ToUpper(strings.TrimSpace(p.value))
+		case "COMPLETED":
+			c, err := parseTime(p)
+			if err != nil {
+				return Todo{}, err
+			}
+			td.Completed = c
+		case "PRIORITY":
+			n, err := strconv.Atoi(strings.TrimSpace(p.value))
+			if err != nil {
+				return Todo{}, fmt.Errorf("invalid PRIORITY %q", p.value)
+			}
+			td.Priority = priorityFrom(n)
+		case "DUE":
+			due, err := parseDate(p)
+			if err != nil {
+				return Todo{}, err
+			}
+			td.Due = due
+		case "CATEGORIES":
+			for _, c := range splitList(p.value) {
+				if c = strings.TrimSpace(unescapeText(c)); c != "" {
+					td.Categories = append(td.Categories, c)
+				}
+			}
+		case "X-GOTODO-PROJECT":
+			td.Project = unescapeText(p.value)
+		}
+	}
+	if !found {
+		return Todo{}, ErrNoTodo
+	}
+	if td.Summary == "" {
+		return Todo{}, fmt.Errorf("task has no SUMMARY")
+	}
+	// a completion time without a status still means done
+	td.Done = status == "COMPLETED" || status == "" && td.seen["COMPLETED"]
+	return td, nil
+}
+
+// join folded lines back together
+func unfold(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	var lines []string
+	for _, l := range strings.Split(s, "\n") {
+		if len(lines) > 0 && (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// split NAME;PARAM=VALUE:value, parameter values may be quoted
+func parseLine(l string) (property, error) {
+	p := property{params: map[string]string{}}
+	quoted := false
+	colon := -1
+	for i := 0; i < len(l) && colon < 0; i++ {
+		switch l[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				colon = i
+			}
+		}
+	}
+	if colon < 0 {
+		return property{}, fmt.Errorf("invalid content line %q", l)
+	}
+	p.value = l[colon+1:]
+	parts := strings.Split(l[:colon], ";")
+	p.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		k, v, _ := strings.Cut(param, "=")
+		p.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+	return p, nil
+}
+
+// the local date of a DATE or DATE-TIME value
+func parseDate(p property) (string, error) {
+	v := strings.TrimSpace(p.value)
+	if len(v) == len(icalDate) {
+		d, err := time.Parse(icalDate, v)
+		if err != nil {
+			return "", fmt.Errorf("invalid date %q", v)
+		}
+		return d.Format(storage.DateLayout), nil
+	}
+	t, err := parseTime(p)
+	if err != nil {
+		return "", err
+	}
+	return t.In(time.Local).Format(storage.DateLayout), nil
+}
+
+// the time of a DATE-TIME value, in UTC, in TZID or else local time
+func parseTime(p property) (time.Time, error) {
+	v := strings.TrimSpace(p.value)
+	loc := time.Local
+	if tz := p.params["TZID"]; tz != "" {
+		if l, err := time.LoadLocation(tz); err == nil {
+			loc = l
+		}
+	}
+	if strings.HasSuffix(v, "Z") {
+		loc = time.UTC
+		v = strings.TrimSuffix(v, "Z")
+	}
+	t, err := time.ParseInLocation("20060102T150405", v, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date-time %q", p.value)
+	}
+	return t, nil
+}
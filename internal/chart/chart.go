@@ -0,0 +1,180 @@
+package chart
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// a named line on a chart, one value per label
+type Series struct {
+	Name   string
+	Values []int
+	Marker rune   // used by ASCII
+	Color  string // used by SVG
+}
+
+func maxValue(series []Series) int {
+	max := 1
+	for _, s := range series {
+		for _, v := range s.Values {
+			if v > max {
+				max = v
+			}
+		}
+	}
+	return max
+}
+
+// pick at most n evenly spread indexes out of count
+func sample(count, n int) []int {
+	if count <= n {
+		idx := make([]int, count)
+		for i := range idx {
+			idx[i] = i
+		}
+		return idx
+	}
+	idx := make([]int, n)
+	for i := range idx {
+		idx[i] = i * (count - 1) / (n - 1)
+	}
+	return idx
+}
+
+// write a plain-text line chart of the given height, at most width
+// characters wide including the axis
+func ASCII(w io.Writer, labels []string, series []Series, height, width int) {
+	if len(labels) == 0 || height < 1 {
+		return
+	}
+	max := maxValue(series)
+	axisWidth := len(strconv.Itoa(max))
+	plotWidth := width - axisWidth - 3
+	if plotWidth < 2 {
+		plotWidth = 2
+	}
+	points := sample(len(labels), plotWidth)
+	step := plotWidth / len(points)
+	if step > 3 {
+		step = 3
+	}
+	if step < 1 {
+		step = 1
+	}
+
+	grid := make([][]rune, height+1)
+	for r := range grid {
+		grid[r] = []rune(strings.Repeat(" ", len(points)*step))
+	}
+	for _, s := range series {
+		prev := -1
+		for x, i := range points {
+			if i >= len(s.Values) {
+				break
+			}
+			y := (s.Values[i]*height + max/2) / max
+			// join vertical jumps so the line stays readable
+			if prev >= 0 {
+				lo, hi := prev, y
+				if lo > hi {
+					lo, hi = hi, lo
+				}
+				for r := lo + 1; r < hi; r++ {
+					if grid[r][x*step] == ' ' {
+						grid[r][x*step] = '│'
+					}
+				}
+			}
+			grid[y][x*step] = s.Marker
+			prev = y
+		}
+	}
+
+	for r := height; r >= 0; r-- {
+		label := ""
+		switch r {
+		case height:
+			label = strconv.Itoa(max)
+		case 0:
+			label = "0"
+		case height / 2:
+			if height > 2 {
+				label = strconv.Itoa((max + 1) / 2)
+			}
+		}
+		fmt.Fprintf(w, " %*s ┤%s\n", axisWidth, label, strings.TrimRight(string(grid[r]), " "))
+	}
+	fmt.Fprintf(w, " %*s └%s\n", axisWidth, "", strings.Repeat("─", len(points)*step))
+
+	first, last := labels[0], labels[len(labels)-1]
+	gap := len(points)*step - len(first) - len(last)
+	if gap < 1 {
+		gap = 1
+	}
+	fmt.Fprintf(w, " %*s  %s%s%s\n", axisWidth, "", first, strings.Repeat(" ", gap), last)
+
+	var legend []string
+	for _, s := range series {
+		legend = append(legend, fmt.Sprintf("%c %s", s.Marker, s.Name))
+	}
+	fmt.Fprintf(w, " %*s  %s\n", axisWidth, "", strings.Join(legend, "   "))
+}
+
+const (
+	svgWidth  = 720
+	svgHeight = 360
+	svgMargin = 48
+)
+
+// write the chart as a standalone SVG document
+func SVG(w io.Writer, title string, labels []string, series []Series) error {
+	max := maxValue(series)
+	plotW := float64(svgWidth - 2*svgMargin)
+	plotH := float64(svgHeight - 2*svgMargin)
+	x := func(i int) float64 {
+		if len(labels) < 2 {
+			return svgMargin
+		}
+		return svgMargin + plotW*float64(i)/float64(len(labels)-1)
+	}
+	y := func(v int) float64 {
+		return svgHeight - svgMargin - plotH*float64(v)/float64(max)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		svgWidth, svgHeight, svgWidth, svgHeight)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+	fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="16" font-weight="bold">%s</text>`+"\n", svgMargin, svgMargin/2+4, html.EscapeString(title))
+
+	// axes and y ticks
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#888"/>`+"\n", svgMargin, svgMargin, svgMargin, svgHeight-svgMargin)
+	fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#888"/>`+"\n", svgMargin, svgHeight-svgMargin, svgWidth-svgMargin, svgHeight-svgMargin)
+	for _, v := range []int{0, (max + 1) / 2, max} {
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#eee"/>`+"\n", svgMargin, y(v), svgWidth-svgMargin, y(v))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end">%d</text>`+"\n", svgMargin-6, y(v)+4, v)
+	}
+	if len(labels) > 0 {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="start">%s</text>`+"\n", x(0), svgHeight-svgMargin+18, html.EscapeString(labels[0]))
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="end">%s</text>`+"\n", x(len(labels)-1), svgHeight-svgMargin+18, html.EscapeString(labels[len(labels)-1]))
+	}
+
+	for n, s := range series {
+		var pts []string
+		for i, v := range s.Values {
+			pts = append(pts, fmt.Sprintf("%.1f,%.1f", x(i), y(v)))
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`+"\n", s.Color, strings.Join(pts, " "))
+		lx := svgWidth - svgMargin - 120
+		ly := svgMargin/2 + 4 + 16*n
+		fmt.Fprintf(&b, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-width="2"/>`+"\n", lx, ly-4, lx+16, ly-4, s.Color)
+		fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`+"\n", lx+22, ly, html.EscapeString(s.Name))
+	}
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
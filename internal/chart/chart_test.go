@@ -0,0 +1,55 @@
+package chart
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestASCII(t *testing.T) {
+	var buf bytes.Buffer
+	labels := []string{"Oct 01", "Oct 02", "Oct 03"}
+	series := []Series{{Name: "open", Values: []int{4, 2, 0}, Marker: '*'}}
+
+	ASCII(&buf, labels, series, 4, 40)
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+
+	// 5 rows, the axis, the labels and the legend
+	if len(lines) != 8 {
+		t.Fatalf("Expected 8 lines, got %d:\n%s", len(lines), buf.String())
+	}
+	if !strings.HasPrefix(lines[0], " 4 ┤*") {
+		t.Errorf("Expected the first value at the top left, got %q", lines[0])
+	}
+	if !strings.Contains(lines[4], "*") || !strings.HasPrefix(lines[4], " 0 ┤") {
+		t.Errorf("Expected the last value on the zero row, got %q", lines[4])
+	}
+	if !strings.Contains(lines[6], "Oct 01") || !strings.Contains(lines[6], "Oct 03") {
+		t.Errorf("Expected first and last labels, got %q", lines[6])
+	}
+	if !strings.Contains(lines[7], "* open") {
+		t.Errorf("Expected legend, got %q", lines[7])
+	}
+}
+
+func TestSVG(t *testing.T) {
+	var buf bytes.Buffer
+	series := []Series{
+		{Name: "scope", Values: []int{1, 2}, Color: "#5b8def"},
+		{Name: "done <all>", Values: []int{0, 1}, Color: "#3fa34d"},
+	}
+	if err := SVG(&buf, "Burnup", []string{"Oct 01", "Oct 02"}, series); err != nil {
+		t.Fatalf("Failed to write svg: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "<svg ") || !strings.HasSuffix(out, "</svg>\n") {
+		t.Error("Expected a complete svg document")
+	}
+	if n := strings.Count(out, "<polyline"); n != 2 {
+		t.Errorf("Expected 2 polylines, got %d", n)
+	}
+	if !strings.Contains(out, "done &lt;all&gt;") {
+		t.Error("Expected series names to be escaped")
+	}
+}
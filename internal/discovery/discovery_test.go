@@ -0,0 +1,171 @@
+package discovery
+
+import (
+	"net"
+	"testing"
+)
+
+func testAdvertiser() *Advertiser {
+	svc := Service{
+		Name:        "Ethan's list",
+		Host:        "laptop.local.",
+		Port:        8088,
+		IPs:         []net.IP{net.IPv4(192, 168, 1, 20)},
+		Version:     1,
+		Fingerprint: "SHA256:abc",
+	}
+	return &Advertiser{svc: svc, instance: svc.Name + "." + ServiceType}
+}
+
+func TestMessage(t *testing.T) {
+	t.Run("pack and unpack", func(t *testing.T) {
+		m := message{
+			ID:        7,
+			Flags:     flagResponse,
+			Questions: []question{{Name: ServiceType, Type: typePTR, Class: classIN}},
+			Answers: []record{
+				{Name: ServiceType, Type: typePTR, Class: classIN, TTL: 10, Target: "x." + ServiceType},
+				{Name: "x." + ServiceType, Type: typeSRV, Class: classIN, TTL: 10, Target: "h.local.", Port: 9000},
+			},
+			Extra: []record{
+				{Name: "x." + ServiceType, Type: typeTXT, Class: classIN, TTL: 10, Text: []string{"proto=1", "fp=a"}},
+				{Name: "h.local.", Type: typeA, Class: classIN, TTL: 10, IP: net.IPv4(10, 0, 0, 1)},
+			},
+		}
+		b, err := m.pack()
+		if err != nil {
+			t.Fatalf("Failed to pack: %v", err)
+		}
+		got, err := unpack(b)
+		if err != nil {
+			t.Fatalf("Failed to unpack: %v", err)
+		}
+		if got.ID != 7 || !got.isResponse() || len(got.Questions) != 1 || len(got.Answers) != 2 || len(got.Extra) != 2 {
+			t.Fatalf("Expected the message back, got %+v", got)
+		}
+		if got.Answers[0].Target != "x."+ServiceType {
+			t.Errorf("Expected PTR target %q, got %q", "x."+ServiceType, got.Answers[0].Target)
+		}
+		if got.Answers[1].Port != 9000 || got.Answers[1].Target != "h.local." {
+			t.Errorf("Expected SRV h.local.:9000, got %+v", got.Answers[1])
+		}
+		if len(got.Extra[0].Text) != 2 || got.Extra[0].Text[1] != "fp=a" {
+			t.Errorf("Expected TXT strings back, got %v", got.Extra[0].Text)
+		}
+		if !got.Extra[1].IP.Equal(net.IPv4(10, 0, 0, 1)) {
+			t.Errorf("Expected A 10.0.0.1, got %v", got.Extra[1].IP)
+		}
+	})
+
+	t.Run("compressed names", func(t *testing.T) {
+		b, _ := message{Questions: []question{{Name: "a.local.", Type: typeA, Class: classIN}}}.pack()
+		// a second question "b" followed by a pointer to "local" at offset 14
+		b[5] = 2
+		b = append(b, 1, 'b', 0xc0, 14, 0, typeA, 0, classIN)
+		m, err := unpack(b)
+		if err != nil {
+			t.Fatalf("Failed to unpack: %v", err)
+		}
+		if len(m.Questions) != 2 || m.Questions[1].Name != "b.local." {
+			t.Errorf("Expected b.local., got %+v", m.Questions)
+		}
+	})
+
+	t.Run("garbage", func(t *testing.T) {
+		b, _ := message{Questions: []question{{Name: ServiceType, Type: typePTR, Class: classIN}}}.pack()
+		for i := 0; i < len(b); i++ {
+			if _, err := unpack(b[:i]); err == nil {
+				t.Errorf("Expected an error for %d bytes", i)
+			}
+		}
+		loop := append(make([]byte, 12), 0xc0, 12)
+		loop[5] = 1
+		if _, err := unpack(loop); err == nil {
+			t.Error("Expected an error for a compression loop")
+		}
+	})
+}
+
+func TestAdvertiser(t *testing.T) {
+	a := testAdvertiser()
+
+	t.Run("browse answer", func(t *testing.T) {
+		q := message{Questions: []question{{Name: "_GOTODO._tcp.local.", Type: typePTR, Class: classIN}}}
+		resp, unicast, ok := a.respond(q, false)
+		if !ok || unicast {
+			t.Fatalf("Expected a multicast answer, got ok=%v unicast=%v", ok, unicast)
+		}
+		b := newBrowser()
+		b.add(resp, net.IPv4(192, 168, 1, 99))
+		list := b.services()
+		if len(list) != 1 {
+			t.Fatalf("Expected 1 service, got %d", len(list))
+		}
+		s := list[0]
+		if s.Name != "Ethan's list" || s.Addr() != "192.168.1.20:8088" || s.Version != 1 || s.Fingerprint != "SHA256:abc" {
+			t.Errorf("Expected the advertised service, got %+v", s)
+		}
+
+		// a goodbye withdraws it
+		b.add(message{Flags: flagResponse, Answers: a.records(0)}, nil)
+		if list := b.services(); len(list) != 0 {
+			t.Errorf("Expected no services after goodbye, got %v", list)
+		}
+	})
+
+	t.Run("one-shot query", func(t *testing.T) {
+		q := message{ID: 42, Questions: []question{{Name: ServiceType, Type: typePTR, Class: classIN}}}
+		resp, unicast, ok := a.respond(q, true)
+		if !ok || !unicast {
+			t.Fatalf("Expected a unicast answer, got ok=%v unicast=%v", ok, unicast)
+		}
+		if resp.ID != 42 || len(resp.Questions) != 1 {
+			t.Errorf("Expected the ID and question repeated, got %+v", resp)
+		}
+		for _, r := range append(resp.Answers, resp.Extra...) {
+			if r.TTL > legacyTTL {
+				t.Errorf("Expected TTL at most %d, got %d", legacyTTL, r.TTL)
+			}
+		}
+	})
+
+	t.Run("other questions", func(t *testing.T) {
+		q := message{Questions: []question{{Name: "_http._tcp.local.", Type: typePTR, Class: classIN}}}
+		if _, _, ok := a.respond(q, false); ok {
+			t.Error("Expected no answer for another service")
+		}
+		q = message{Questions: []question{{Name: "laptop.local.", Type: typeA, Class: classIN | classFlag}}}
+		resp, unicast, ok := a.respond(q, false)
+		if !ok || !unicast || len(resp.Answers) != 1 {
+			t.Errorf("Expected one unicast A record, got ok=%v unicast=%v %+v", ok, unicast, resp.Answers)
+		}
+	})
+
+	t.Run("missing addresses", func(t *testing.T) {
+		// without A records the sender's address is used
+		a := testAdvertiser()
+		resp, _, _ := a.respond(message{Questions: []question{{Name: ServiceType, Type: typePTR, Class: classIN}}}, false)
+		resp.Extra = resp.Extra[:2]
+		b := newBrowser()
+		b.add(resp, net.IPv4(192, 168, 1, 99))
+		if list := b.services(); len(list) != 1 || list[0].Addr() != "192.168.1.99:8088" {
+			t.Errorf("Expected 192.168.1.99:8088, got %v", list)
+		}
+	})
+}
+
+func TestLabels(t *testing.T) {
+	if got := instanceLabel(" My list. v2 "); got != "My list  v2" {
+		t.Errorf("Expected %q, got %q", "My list  v2", got)
+	}
+	long := ""
+	for i := 0; i < 40; i++ {
+		long += "é"
+	}
+	if got := instanceLabel(long); len(got) > 63 || got != long[:62] {
+		t.Errorf("Expected a 62 byte label, got %d bytes", len(got))
+	}
+	if got := hostLabel("my_laptop"); got != "my-laptop" {
+		t.Errorf("Expected my-laptop, got %q", got)
+	}
+}
@@ -0,0 +1,291 @@
+package discovery
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// The small part of the DNS message format (RFC 1035) needed for
+// multicast DNS service discovery: questions and A, PTR, TXT and SRV
+// records. Names are written without compression but read with it.
+
+// record types
+const (
+	typeA   = 1
+	typePTR = 12
+	typeTXT = 16
+	typeSRV = 33
+	typeANY = 255
+)
+
+const (
+	classIN = 1
+	// top bit of the class: "unicast response" in questions, "cache flush"
+	// in records
+	classFlag = 0x8000
+)
+
+// flags of a response from the authority for the records
+const flagResponse = 0x8400
+
+var errTruncated = errors.New("truncated DNS message")
+
+type question struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+type record struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+
+	// set depending on Type
+	IP     net.IP   // A
+	Target string   // PTR and SRV
+	Port   uint16   // SRV
+	Text   []string // TXT
+}
+
+type message struct {
+	ID        uint16
+	Flags     uint16
+	Questions []question
+	Answers   []record
+	Extra     []record
+}
+
+func (m message) isResponse() bool {
+	return m.Flags&0x8000 != 0
+}
+
+// canonical form of a domain name: lower case with a trailing dot
+func canonical(name string) string {
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	return name
+}
+
+func (m message) pack() ([]byte, error) {
+	b := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(b[0:], m.ID)
+	binary.BigEndian.PutUint16(b[2:], m.Flags)
+	binary.BigEndian.PutUint16(b[4:], uint16(len(m.Questions)))
+	binary.BigEndian.PutUint16(b[6:], uint16(len(m.Answers)))
+	binary.BigEndian.PutUint16(b[10:], uint16(len(m.Extra)))
+
+	var err error
+	for _, q := range m.Questions {
+		if b, err = appendName(b, q.Name); err != nil {
+			return nil, err
+		}
+		b = binary.BigEndian.AppendUint16(b, q.Type)
+		b = binary.BigEndian.AppendUint16(b, q.Class)
+	}
+	for _, r := range append(append([]record{}, m.Answers...), m.Extra...) {
+		if b, err = appendRecord(b, r); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func appendName(b []byte, name string) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return nil, fmt.Errorf("invalid DNS label %q in %q", label, name)
+			}
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+	}
+	return append(b, 0), nil
+}
+
+func appendRecord(b []byte, r record) ([]byte, error) {
+	var err error
+	if b, err = appendName(b, r.Name); err != nil {
+		return nil, err
+	}
+	b = binary.BigEndian.AppendUint16(b, r.Type)
+	b = binary.BigEndian.AppendUint16(b, r.Class)
+	b = binary.BigEndian.AppendUint32(b, r.TTL)
+
+	var data []byte
+	switch r.Type {
+	case typeA:
+		ip4 := r.IP.To4()
+		if ip4 == nil {
+			return nil, fmt.Errorf("A record needs an IPv4 address, got %v", r.IP)
+		}
+		data = ip4
+	case typePTR:
+		if data, err = appendName(nil, r.Target); err != nil {
+			return nil, err
+		}
+	case typeSRV:
+		data = make([]byte, 6)
+		binary.BigEndian.PutUint16(data[4:], r.Port) // priority and weight stay 0
+		if data, err = appendName(data, r.Target); err != nil {
+			return nil, err
+		}
+	case typeTXT:
+		for _, s := range r.Text {
+			if len(s) > 255 {
+				return nil, fmt.Errorf("TXT string too long: %q", s)
+			}
+			data = append(data, byte(len(s)))
+			data = append(data, s...)
+		}
+		if len(data) == 0 {
+			data = []byte{0}
+		}
+	default:
+		return nil, fmt.Errorf("cannot write DNS record type %d", r.Type)
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...), nil
+}
+
+func unpack(b []byte) (message, error) {
+	var m message
+	if len(b) < 12 {
+		return m, errTruncated
+	}
+	m.ID = binary.BigEndian.Uint16(b[0:])
+	m.Flags = binary.BigEndian.Uint16(b[2:])
+	qd := int(binary.BigEndian.Uint16(b[4:]))
+	an := int(binary.BigEndian.Uint16(b[6:]))
+	ns := int(binary.BigEndian.Uint16(b[8:]))
+	ar := int(binary.BigEndian.Uint16(b[10:]))
+
+	off := 12
+	for i := 0; i < qd; i++ {
+		name, n, err := readName(b, off)
+		if err != nil {
+			return m, err
+		}
+		off = n
+		if off+4 > len(b) {
+			return m, errTruncated
+		}
+		m.Questions = append(m.Questions, question{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(b[off:]),
+			Class: binary.BigEndian.Uint16(b[off+2:]),
+		})
+		off += 4
+	}
+	for i := 0; i < an+ns+ar; i++ {
+		r, n, err := readRecord(b, off)
+		if err != nil {
+			return m, err
+		}
+		off = n
+		// authority records are of no use here
+		switch {
+		case i < an:
+			m.Answers = append(m.Answers, r)
+		case i >= an+ns:
+			m.Extra = append(m.Extra, r)
+		}
+	}
+	return m, nil
+}
+
+// read a possibly compressed name at off, returning the offset after it
+func readName(b []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for jumps := 0; ; {
+		if off >= len(b) {
+			return "", 0, errTruncated
+		}
+		l := int(b[off])
+		switch {
+		case l == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, ".") + ".", end, nil
+		case l&0xc0 == 0xc0:
+			if off+1 >= len(b) {
+				return "", 0, errTruncated
+			}
+			if jumps++; jumps > 16 {
+				return "", 0, fmt.Errorf("DNS name compression loop")
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(b[off:]) & 0x3fff)
+		default:
+			if off+1+l > len(b) {
+				return "", 0, errTruncated
+			}
+			labels = append(labels, string(b[off+1:off+1+l]))
+			off += 1 + l
+		}
+	}
+}
+
+func readRecord(b []byte, off int) (record, int, error) {
+	var r record
+	name, off, err := readName(b, off)
+	if err != nil {
+		return r, 0, err
+	}
+	if off+10 > len(b) {
+		return r, 0, errTruncated
+	}
+	r.Name = name
+	r.Type = binary.BigEndian.Uint16(b[off:])
+	r.Class = binary.BigEndian.Uint16(b[off+2:])
+	r.TTL = binary.BigEndian.Uint32(b[off+4:])
+	size := int(binary.BigEndian.Uint16(b[off+8:]))
+	off += 10
+	if off+size > len(b) {
+		return r, 0, errTruncated
+	}
+	data := b[off : off+size]
+
+	switch r.Type {
+	case typeA:
+		if size == 4 {
+			r.IP = net.IP(append([]byte{}, data...))
+		}
+	case typePTR:
+		if r.Target, _, err = readName(b, off); err != nil {
+			return r, 0, err
+		}
+	case typeSRV:
+		if size < 7 {
+			return r, 0, errTruncated
+		}
+		r.Port = binary.BigEndian.Uint16(data[4:])
+		if r.Target, _, err = readName(b, off+6); err != nil {
+			return r, 0, err
+		}
+	case typeTXT:
+		for i := 0; i < len(data); {
+			l := int(data[i])
+			if i+1+l > len(data) {
+				return r, 0, errTruncated
+			}
+			if l > 0 {
+				r.Text = append(r.Text, string(data[i+1:i+1+l]))
+			}
+			i += 1 + l
+		}
+	}
+	return r, off + size, nil
+}
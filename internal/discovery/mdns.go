@@ -0,0 +1,433 @@
+// Package discovery finds gotodo friend servers on the local network with
+// multicast DNS service discovery (RFC 6762 and RFC 6763).
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// DNS-SD service type of friend servers
+const ServiceType = "_gotodo._tcp.local."
+
+// lists all service types on the network, answered for tools like avahi-browse
+const servicesName = "_services._dns-sd._udp.local."
+
+const mdnsPort = 5353
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: mdnsPort}
+
+// record TTLs recommended by RFC 6762
+const (
+	hostTTL    = 120
+	serviceTTL = 4500
+	// at most this for answers to one-shot (legacy) queries
+	legacyTTL = 10
+)
+
+// a friend server as advertised on the network
+type Service struct {
+	// display name, e.g. "Ethan's todo list"
+	Name string
+	// host name in .local, e.g. "ethan-laptop.local."
+	Host string
+	Port int
+	// addresses of the host, all local IPv4 addresses when advertising
+	// with none
+	IPs []net.IP
+	// friend protocol version
+	Version int
+	// certificate fingerprint, to compare with the one pinned on connect
+	Fingerprint string
+}
+
+// host:port to connect to, preferring an IP address
+func (s Service) Addr() string {
+	host := strings.TrimSuffix(s.Host, ".")
+	if len(s.IPs) > 0 {
+		host = s.IPs[0].String()
+	}
+	return net.JoinHostPort(host, strconv.Itoa(s.Port))
+}
+
+// answers queries for one service until closed
+type Advertiser struct {
+	conn     *net.UDPConn
+	svc      Service
+	instance string
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// start answering mDNS queries for svc, and announce it once
+func Advertise(svc Service) (*Advertiser, error) {
+	if svc.Host == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("host name: %v", err)
+		}
+		svc.Host = host
+	}
+	svc.Host = hostLabel(strings.TrimSuffix(strings.TrimSuffix(svc.Host, "."), ".local")) + ".local."
+	if svc.Name == "" {
+		svc.Name = strings.TrimSuffix(svc.Host, ".local.")
+	}
+	svc.Name = instanceLabel(svc.Name)
+	if svc.Name == "" {
+		return nil, fmt.Errorf("no name to advertise")
+	}
+
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return nil, fmt.Errorf("mDNS listen error: %v", err)
+	}
+	a := &Advertiser{
+		conn:     conn,
+		svc:      svc,
+		instance: svc.Name + "." + ServiceType,
+		done:     make(chan struct{}),
+	}
+	a.announce(serviceTTL)
+	a.wg.Add(1)
+	go a.serve()
+	return a, nil
+}
+
+// stop answering and tell the network the service is gone
+func (a *Advertiser) Close() error {
+	select {
+	case <-a.done:
+		return nil
+	default:
+	}
+	close(a.done)
+	a.announce(0)
+	err := a.conn.Close()
+	a.wg.Wait()
+	return err
+}
+
+// send all records unasked, a TTL of 0 withdraws them
+func (a *Advertiser) announce(ttl uint32) {
+	m := message{Flags: flagResponse, Answers: a.records(ttl)}
+	if b, err := m.pack(); err == nil {
+		a.conn.WriteToUDP(b, mdnsGroup)
+	}
+}
+
+func (a *Advertiser) serve() {
+	defer a.wg.Done()
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := a.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-a.done:
+				return
+			default:
+			}
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		q, err := unpack(buf[:n])
+		if err != nil || q.isResponse() {
+			continue
+		}
+		resp, unicast, ok := a.respond(q, src.Port != mdnsPort)
+		if !ok {
+			continue
+		}
+		b, err := resp.pack()
+		if err != nil {
+			continue
+		}
+		to := mdnsGroup
+		if unicast {
+			to = src
+		}
+		a.conn.WriteToUDP(b, to)
+	}
+}
+
+// the answer to a query, if it asks about this service. Queries from other
+// ports than 5353 are one-shot queries (RFC 6762 section 6.7) and are
+// answered directly with the question repeated.
+func (a *Advertiser) respond(q message, legacy bool) (resp message, unicast bool, ok bool) {
+	ttl := func(t uint32) uint32 {
+		if legacy && t > legacyTTL {
+			return legacyTTL
+		}
+		return t
+	}
+	all := a.records(serviceTTL)
+	for i := range all {
+		all[i].TTL = ttl(all[i].TTL)
+	}
+	ptr, srv, txt, addrs := all[0], all[1], all[2], all[3:]
+
+	unicast = legacy
+	for _, qu := range q.Questions {
+		name := canonical(qu.Name)
+		anyType := qu.Type == typeANY
+		switch {
+		case name == servicesName && (qu.Type == typePTR || anyType):
+			resp.Answers = append(resp.Answers, record{Name: servicesName, Type: typePTR, Class: classIN, TTL: ttl(serviceTTL), Target: ServiceType})
+		case name == ServiceType && (qu.Type == typePTR || anyType):
+			resp.Answers = append(resp.Answers, ptr)
+			resp.Extra = append(resp.Extra, srv, txt)
+			resp.Extra = append(resp.Extra, addrs...)
+		case name == canonical(a.instance) && (qu.Type == typeSRV || qu.Type == typeTXT || anyType):
+			if qu.Type != typeTXT {
+				resp.Answers = append(resp.Answers, srv)
+				resp.Extra = append(resp.Extra, addrs...)
+			}
+			if qu.Type != typeSRV {
+				resp.Answers = append(resp.Answers, txt)
+			}
+		case name == canonical(a.svc.Host) && (qu.Type == typeA || anyType):
+			resp.Answers = append(resp.Answers, addrs...)
+		default:
+			continue
+		}
+		if qu.Class&classFlag != 0 {
+			unicast = true
+		}
+	}
+	if len(resp.Answers) == 0 {
+		return message{}, false, false
+	}
+	resp.Flags = flagResponse
+	if legacy {
+		resp.ID = q.ID
+		resp.Questions = q.Questions
+	}
+	return resp, unicast, true
+}
+
+// PTR, SRV and TXT records of the service followed by its A records
+func (a *Advertiser) records(ttl uint32) []record {
+	short := uint32(hostTTL)
+	if ttl < short {
+		short = ttl
+	}
+	rs := []record{
+		{Name: ServiceType, Type: typePTR, Class: classIN, TTL: ttl, Target: a.instance},
+		{Name: a.instance, Type: typeSRV, Class: classIN | classFlag, TTL: short, Target: a.svc.Host, Port: uint16(a.svc.Port)},
+		{Name: a.instance, Type: typeTXT, Class: classIN | classFlag, TTL: ttl, Text: a.text()},
+	}
+	ips := a.svc.IPs
+	if len(ips) == 0 {
+		ips = localIPv4()
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			rs = append(rs, record{Name: a.svc.Host, Type: typeA, Class: classIN | classFlag, TTL: short, IP: ip})
+		}
+	}
+	return rs
+}
+
+func (a *Advertiser) text() []string {
+	txt := []string{"txtvers=1", "proto=" + strconv.Itoa(a.svc.Version)}
+	if a.svc.Fingerprint != "" {
+		txt = append(txt, "fp="+a.svc.Fingerprint)
+	}
+	return txt
+}
+
+// the IPv4 addresses of all interfaces that are up, except loopback
+func localIPv4() []net.IP {
+	var ips []net.IP
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				ips = append(ips, ipnet.IP.To4())
+			}
+		}
+	}
+	return ips
+}
+
+// an instance name is a single DNS label of any text, dots would split it
+func instanceLabel(name string) string {
+	name = strings.TrimSpace(strings.ReplaceAll(name, ".", " "))
+	for len(name) > 63 {
+		// cut at a rune boundary
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// a host label keeps to letters, digits and dashes
+func hostLabel(host string) string {
+	host = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			return r
+		}
+		return '-'
+	}, host)
+	if len(host) > 63 {
+		host = host[:63]
+	}
+	if host == "" {
+		host = "gotodo"
+	}
+	return host
+}
+
+// ask the network for friend servers and collect the answers for timeout
+func Browse(timeout time.Duration) ([]Service, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("mDNS error: %v", err)
+	}
+	defer conn.Close()
+
+	query, err := message{Questions: []question{{Name: ServiceType, Type: typePTR, Class: classIN}}}.pack()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteToUDP(query, mdnsGroup); err != nil {
+		return nil, fmt.Errorf("mDNS query error: %v", err)
+	}
+
+	b := newBrowser()
+	deadline := time.Now().Add(timeout)
+	// ask once more halfway in case the first query was lost
+	resent := false
+	buf := make([]byte, 9000)
+	for {
+		next := deadline
+		if !resent {
+			next = time.Now().Add(timeout / 2)
+		}
+		conn.SetReadDeadline(next)
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				if time.Now().Before(deadline) {
+					resent = true
+					conn.WriteToUDP(query, mdnsGroup)
+					continue
+				}
+				break
+			}
+			return nil, fmt.Errorf("mDNS error: %v", err)
+		}
+		if m, err := unpack(buf[:n]); err == nil && m.isResponse() {
+			b.add(m, src.IP)
+		}
+	}
+	return b.services(), nil
+}
+
+// gathers records from responses into services
+type browser struct {
+	instances map[string]bool
+	srv       map[string]record
+	txt       map[string][]string
+	addrs     map[string][]net.IP
+	// sender of the SRV record, used when no A record came
+	from map[string]net.IP
+}
+
+func newBrowser() *browser {
+	return &browser{
+		instances: map[string]bool{},
+		srv:       map[string]record{},
+		txt:       map[string][]string{},
+		addrs:     map[string][]net.IP{},
+		from:      map[string]net.IP{},
+	}
+}
+
+func (b *browser) add(m message, src net.IP) {
+	for _, r := range append(m.Answers, m.Extra...) {
+		name := canonical(r.Name)
+		switch r.Type {
+		case typePTR:
+			if name == ServiceType {
+				if r.TTL == 0 {
+					delete(b.instances, canonical(r.Target))
+				} else {
+					b.instances[canonical(r.Target)] = true
+				}
+			}
+		case typeSRV:
+			b.srv[name] = r
+			b.from[name] = src
+		case typeTXT:
+			b.txt[name] = r.Text
+		case typeA:
+			if !containsIP(b.addrs[name], r.IP) {
+				b.addrs[name] = append(b.addrs[name], r.IP)
+			}
+		}
+	}
+}
+
+func (b *browser) services() []Service {
+	var list []Service
+	for instance := range b.instances {
+		srv, ok := b.srv[instance]
+		if !ok {
+			continue
+		}
+		s := Service{
+			Name: strings.TrimSuffix(instance, "."+ServiceType),
+			Host: srv.Target,
+			Port: int(srv.Port),
+			IPs:  b.addrs[canonical(srv.Target)],
+		}
+		// keep the case of the name as advertised
+		if i := len(srv.Name) - len(ServiceType) - 1; i > 0 {
+			s.Name = srv.Name[:i]
+		}
+		if len(s.IPs) == 0 && b.from[instance] != nil {
+			s.IPs = []net.IP{b.from[instance]}
+		}
+		for _, kv := range b.txt[instance] {
+			k, v, _ := strings.Cut(kv, "=")
+			switch k {
+			case "proto":
+				s.Version, _ = strconv.Atoi(v)
+			case "fp":
+				s.Fingerprint = v
+			}
+		}
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+	})
+	return list
+}
+
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, x := range ips {
+		if x.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
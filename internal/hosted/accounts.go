@@ -0,0 +1,400 @@
+package hosted
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/network"
+)
+
+// shortest password accepted
+const MinPasswordLength = 8
+
+// PBKDF2-SHA256 rounds for new passwords, stored with each hash so it can
+// be raised later without breaking old ones
+var passwordIterations = 600_000
+
+// an account on a hosted server
+type User struct {
+	Name string `json:"name"`
+	// pbkdf2-sha256$<iterations>$<salt>$<key>
+	Password  string `json:"password"`
+	CreatedAt string `json:"created_at"`
+}
+
+// a project whose list is shared by its members
+type Project struct {
+	Name    string   `json:"name"`
+	Members []string `json:"members"`
+}
+
+type accountsFile struct {
+	Users    []User    `json:"users"`
+	Projects []Project `json:"projects"`
+}
+
+// users, shared projects and login tokens of a hosted server, kept in a
+// directory together with every user's and project's task list. The files
+// are read on every call so changes made with 'gotodo host' while the
+// server runs take effect at once.
+type Accounts struct {
+	dir string
+	// serialises changes made by one process
+	mu     sync.Mutex
+	tokens *network.TokenStore
+}
+
+func NewAccounts(dir string) *Accounts {
+	return &Accounts{dir: dir, tokens: network.NewTokenStore(filepath.Join(dir, "tokens.json"))}
+}
+
+func (a *Accounts) Dir() string {
+	return a.dir
+}
+
+// the task list of a user
+func (a *Accounts) UserPath(name string) string {
+	return filepath.Join(a.dir, "users", name+".json")
+}
+
+// the task list of a shared project
+func (a *Accounts) ProjectPath(name string) string {
+	return filepath.Join(a.dir, "projects", name+".json")
+}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{0,63}$`)
+
+// check that name can be used for a user or project, it ends up in file
+// names and URLs
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid name %q (letters, digits, '-' and '_', starting with a letter)", name)
+	}
+	return nil
+}
+
+func (a *Accounts) load() (accountsFile, error) {
+	data, err := os.ReadFile(filepath.Join(a.dir, "accounts.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return accountsFile{}, nil
+		}
+		return accountsFile{}, err
+	}
+	var f accountsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return accountsFile{}, fmt.Errorf("failed to parse accounts file: %v", err)
+	}
+	return f, nil
+}
+
+func (a *Accounts) save(f accountsFile) error {
+	if err := os.MkdirAll(a.dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(f, "", " ")
+	if err != nil {
+		return err
+	}
+	// password hashes, keep them private
+	return os.WriteFile(filepath.Join(a.dir, "accounts.json"), data, 0600)
+}
+
+func findUser(f accountsFile, name string) int {
+	return slices.IndexFunc(f.Users, func(u User) bool { return u.Name == name })
+}
+
+func findProject(f accountsFile, name string) int {
+	return slices.IndexFunc(f.Projects, func(p Project) bool { return p.Name == name })
+}
+
+// create a user with an empty task list
+func (a *Accounts) AddUser(name, password string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := a.load()
+	if err != nil {
+		return err
+	}
+	if findUser(f, name) >= 0 {
+		return fmt.Errorf("user %q already exists", name)
+	}
+	f.Users = append(f.Users, User{Name: name, Password: hash, CreatedAt: time.Now().Local().String()})
+	return a.save(f)
+}
+
+// change the password of a user and log out all of their devices
+func (a *Accounts) SetPassword(name, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := a.load()
+	if err != nil {
+		return err
+	}
+	i := findUser(f, name)
+	if i < 0 {
+		return fmt.Errorf("user %q not found", name)
+	}
+	f.Users[i].Password = hash
+	if err := a.save(f); err != nil {
+		return err
+	}
+	return a.revokeAll(name)
+}
+
+// delete a user, their tokens and project memberships. Their task list is
+// kept on disk.
+func (a *Accounts) RemoveUser(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := a.load()
+	if err != nil {
+		return err
+	}
+	i := findUser(f, name)
+	if i < 0 {
+		return fmt.Errorf("user %q not found", name)
+	}
+	f.Users = slices.Delete(f.Users, i, i+1)
+	for j := range f.Projects {
+		f.Projects[j].Members = slices.DeleteFunc(f.Projects[j].Members, func(m string) bool { return m == name })
+	}
+	if err := a.save(f); err != nil {
+		return err
+	}
+	return a.revokeAll(name)
+}
+
+// all users sorted by name
+func (a *Accounts) Users() ([]User, error) {
+	f, err := a.load()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(f.Users, func(i, j int) bool { return f.Users[i].Name < f.Users[j].Name })
+	return f.Users, nil
+}
+
+// create a project or replace its members, who must all be users
+func (a *Accounts) SetProject(name string, members []string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := a.load()
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		if findUser(f, m) < 0 {
+			return fmt.Errorf("user %q not found", m)
+		}
+	}
+	members = slices.Compact(slices.Sorted(slices.Values(members)))
+	if i := findProject(f, name); i >= 0 {
+		f.Projects[i].Members = members
+	} else {
+		f.Projects = append(f.Projects, Project{Name: name, Members: members})
+	}
+	return a.save(f)
+}
+
+// delete a project, its task list is kept on disk
+func (a *Accounts) RemoveProject(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := a.load()
+	if err != nil {
+		return err
+	}
+	i := findProject(f, name)
+	if i < 0 {
+		return fmt.Errorf("project %q not found", name)
+	}
+	f.Projects = slices.Delete(f.Projects, i, i+1)
+	return a.save(f)
+}
+
+// all projects sorted by name
+func (a *Accounts) Projects() ([]Project, error) {
+	f, err := a.load()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(f.Projects, func(i, j int) bool { return f.Projects[i].Name < f.Projects[j].Name })
+	return f.Projects, nil
+}
+
+// the projects user is a member of
+func (a *Accounts) ProjectsOf(user string) ([]string, error) {
+	projects, err := a.Projects()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, p := range projects {
+		if slices.Contains(p.Members, user) {
+			names = append(names, p.Name)
+		}
+	}
+	return names, nil
+}
+
+// report whether project exists and whether user is one of its members
+func (a *Accounts) Member(project, user string) (exists, member bool, err error) {
+	f, err := a.load()
+	if err != nil {
+		return false, false, err
+	}
+	i := findProject(f, project)
+	if i < 0 {
+		return false, false, nil
+	}
+	return true, slices.Contains(f.Projects[i].Members, user), nil
+}
+
+// report whether password is the password of user
+func (a *Accounts) CheckPassword(user, password string) (bool, error) {
+	f, err := a.load()
+	if err != nil {
+		return false, err
+	}
+	i := findUser(f, user)
+	if i < 0 {
+		// spend the same time as for a real user so timing doesn't tell
+		// which names exist
+		pbkdf2.Key(sha256.New, password, make([]byte, 16), passwordIterations, 32)
+		return false, nil
+	}
+	return checkPassword(f.Users[i].Password, password), nil
+}
+
+var unsafeDeviceChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// check the password of user and issue a token for device, replacing the
+// token the device had before
+func (a *Accounts) Login(user, password, device string) (string, error) {
+	ok, err := a.CheckPassword(user, password)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrBadLogin
+	}
+	device = unsafeDeviceChars.ReplaceAllString(device, "_")
+	if device == "" {
+		device = "cli"
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.tokens.Create(user + "/" + device)
+}
+
+// the user a token was issued to, ok is false for unknown or revoked
+// tokens and tokens of deleted users
+func (a *Accounts) Authenticate(token string) (string, bool, error) {
+	name, ok, err := a.tokens.Verify(token)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	user, _, _ := strings.Cut(name, "/")
+	f, err := a.load()
+	if err != nil {
+		return "", false, err
+	}
+	return user, findUser(f, user) >= 0, nil
+}
+
+// revoke a token issued by Login
+func (a *Accounts) Logout(token string) error {
+	name, ok, err := a.tokens.Verify(token)
+	if err != nil || !ok {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.tokens.Revoke(name)
+}
+
+// revoke every token of user, a.mu must be held
+func (a *Accounts) revokeAll(user string) error {
+	tokens, err := a.tokens.List()
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if strings.HasPrefix(t.Name, user+"/") {
+			if err := a.tokens.Revoke(t.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// returned by Login for an unknown user or a wrong password
+var ErrBadLogin = errors.New("wrong user name or password")
+
+func hashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("password is too short (at least %d characters)", MinPasswordLength)
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, 32)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func checkPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err1 := base64.RawStdEncoding.DecodeString(parts[2])
+	want, err2 := base64.RawStdEncoding.DecodeString(parts[3])
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, want) == 1
+}
@@ -0,0 +1,215 @@
+package hosted
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+// time allowed for one request to a hosted server, and for connecting to
+// it; an unreachable server should not hold up working offline for long
+const clientTimeout = 15 * time.Second
+const connectTimeout = 5 * time.Second
+
+// a task list on a hosted server, used as a storage.Remote
+type Client struct {
+	base  string
+	token string
+	// the shared project to work on, the user's own list when empty
+	project string
+	http    *http.Client
+}
+
+// a client for the list of the user token belongs to, or for a shared
+// project's list
+func NewClient(serverURL, token, project string) *Client {
+	return &Client{
+		base:    strings.TrimSuffix(serverURL, "/"),
+		token:   token,
+		project: project,
+		http: &http.Client{
+			Timeout: clientTimeout,
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				DialContext:         (&net.Dialer{Timeout: connectTimeout}).DialContext,
+				TLSHandshakeTimeout: connectTimeout,
+			},
+		},
+	}
+}
+
+// check that s is an http or https URL with a host
+func ValidateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid server URL %q (want http://host:port or https://host)", s)
+	}
+	return nil
+}
+
+// log in to a hosted server and return a token for device
+func Login(serverURL, user, password, device string) (string, error) {
+	c := NewClient(serverURL, "", "")
+	var resp loginResponse
+	err := c.do("POST", "/login", loginRequest{User: user, Password: password, Device: device}, &resp)
+	return resp.Token, err
+}
+
+// revoke the client's token
+func (c *Client) Logout() error {
+	return c.do("POST", "/logout", nil, nil)
+}
+
+// the user the client is logged in as and their projects
+func (c *Client) Me() (Me, error) {
+	var me Me
+	err := c.do("GET", "/me", nil, &me)
+	return me, err
+}
+
+// path of the client's list
+func (c *Client) tasksPath() string {
+	if c.project != "" {
+		return "/projects/" + url.PathEscape(c.project) + "/tasks"
+	}
+	return "/tasks"
+}
+
+func (c *Client) List() ([]storage.Task, error) {
+	var tasks []storage.Task
+	err := c.do("GET", c.tasksPath(), nil, &tasks)
+	return tasks, err
+}
+
+func (c *Client) AddTask(t storage.Task) (storage.Task, error) {
+	body := map[string]any{"content": t.Content, "tags": t.Tags, "priority": t.Priority, "project": t.Project, "due": t.Due, "scheduled": t.Scheduled}
+	var created storage.Task
+	err := c.do("POST", c.tasksPath(), body, &created)
+	return created, err
+}
+
+func (c *Client) Update(id int, p storage.TaskPatch) (storage.Task, error) {
+	return c.UpdateIf(id, p, "")
+}
+
+// update task id only while it has the ETag etag, any version when etag is
+// empty. Otherwise the server answers 412.
+func (c *Client) UpdateIf(id int, p storage.TaskPatch, etag string) (storage.Task, error) {
+	var updated storage.Task
+	err := c.send("PATCH", fmt.Sprintf("%s/%d", c.tasksPath(), id), etag, p, &updated)
+	return updated, err
+}
+
+func (c *Client) Delete(id int) error {
+	return c.DeleteIf(id, "")
+}
+
+// delete task id only while it has the ETag etag, see UpdateIf
+func (c *Client) DeleteIf(id int, etag string) error {
+	return c.send("DELETE", fmt.Sprintf("%s/%d", c.tasksPath(), id), etag, nil, nil)
+}
+
+func (c *Client) AddComment(id int, cm storage.Comment) (storage.Task, error) {
+	var updated storage.Task
+	err := c.do("POST", fmt.Sprintf("%s/%d/comments", c.tasksPath(), id), map[string]string{"text": cm.Text}, &updated)
+	return updated, err
+}
+
+// wrapped by errors of requests that got no answer
+var ErrUnreachable = errors.New("cannot reach")
+
+// report whether err means the server could not be reached or could not
+// answer for now (too many requests, an error of its own), so the request
+// can be tried again later
+func Unreachable(err error) bool {
+	var herr *Error
+	if errors.As(err, &herr) {
+		return herr.Status == http.StatusTooManyRequests || herr.Status >= 500
+	}
+	return errors.Is(err, ErrUnreachable)
+}
+
+// report whether the server refused this one request because of what it
+// asked for, so sending it again would be refused again
+func Refused(err error) bool {
+	var herr *Error
+	if !errors.As(err, &herr) {
+		return false
+	}
+	switch herr.Status {
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed:
+		return true
+	}
+	return false
+}
+
+// an error answered by a hosted server
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// send a request with an optional JSON body and decode the JSON answer into out
+func (c *Client) do(method, path string, in, out any) error {
+	return c.send(method, path, "", in, out)
+}
+
+// do with an If-Match header, left out when etag is empty
+func (c *Client) send(method, path, etag string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w %s: %v", ErrUnreachable, c.base, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&e) != nil || e.Error == "" {
+			e.Error = resp.Status
+		}
+		return &Error{Status: resp.StatusCode, Message: e.Error}
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response from %s: %v", c.base, err)
+	}
+	return nil
+}
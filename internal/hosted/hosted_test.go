@@ -0,0 +1,564 @@
+package hosted
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+func init() {
+	// keep password hashing fast in tests
+	passwordIterations = 1000
+}
+
+func TestAccounts(t *testing.T) {
+	accounts := NewAccounts(t.TempDir())
+
+	// Test adding users and checking passwords
+	t.Run("Users", func(t *testing.T) {
+		if err := accounts.AddUser("alice", "correct horse"); err != nil {
+			t.Fatalf("Failed to add user: %v", err)
+		}
+		if err := accounts.AddUser("alice", "another one"); err == nil {
+			t.Error("Expected error for a duplicate user")
+		}
+		if err := accounts.AddUser("bob", "short"); err == nil {
+			t.Error("Expected error for a short password")
+		}
+		if err := accounts.AddUser("../evil", "long enough"); err == nil {
+			t.Error("Expected error for an invalid name")
+		}
+		if ok, _ := accounts.CheckPassword("alice", "correct horse"); !ok {
+			t.Error("Expected the right password to be accepted")
+		}
+		if ok, _ := accounts.CheckPassword("alice", "wrong horse"); ok {
+			t.Error("Expected a wrong password to be refused")
+		}
+		if ok, _ := accounts.CheckPassword("nobody", "correct horse"); ok {
+			t.Error("Expected an unknown user to be refused")
+		}
+	})
+
+	// Test tokens from logins and what revokes them
+	t.Run("Tokens", func(t *testing.T) {
+		if _, err := accounts.Login("alice", "wrong horse", "laptop"); !errors.Is(err, ErrBadLogin) {
+			t.Errorf("Expected ErrBadLogin, got %v", err)
+		}
+		laptop, err := accounts.Login("alice", "correct horse", "laptop")
+		if err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+		phone, _ := accounts.Login("alice", "correct horse", "phone")
+		if user, ok, _ := accounts.Authenticate(laptop); !ok || user != "alice" {
+			t.Errorf("Expected the laptop token to belong to alice, got %q %v", user, ok)
+		}
+		if err := accounts.Logout(laptop); err != nil {
+			t.Fatalf("Logout failed: %v", err)
+		}
+		if _, ok, _ := accounts.Authenticate(laptop); ok {
+			t.Error("Expected the laptop token to be revoked")
+		}
+		if _, ok, _ := accounts.Authenticate(phone); !ok {
+			t.Error("Expected the phone token to stay valid")
+		}
+		if err := accounts.SetPassword("alice", "battery staple"); err != nil {
+			t.Fatalf("Failed to change password: %v", err)
+		}
+		if _, ok, _ := accounts.Authenticate(phone); ok {
+			t.Error("Expected a password change to revoke every token")
+		}
+	})
+
+	// Test project membership
+	t.Run("Projects", func(t *testing.T) {
+		accounts.AddUser("bob", "bob's password")
+		if err := accounts.SetProject("website", []string{"bob", "alice", "bob"}); err != nil {
+			t.Fatalf("Failed to set project: %v", err)
+		}
+		if err := accounts.SetProject("website", []string{"carol"}); err == nil {
+			t.Error("Expected error for an unknown member")
+		}
+		if _, member, _ := accounts.Member("website", "bob"); !member {
+			t.Error("Expected bob to be a member")
+		}
+		if err := accounts.RemoveUser("bob"); err != nil {
+			t.Fatalf("Failed to remove user: %v", err)
+		}
+		projects, _ := accounts.Projects()
+		if len(projects) != 1 || len(projects[0].Members) != 1 || projects[0].Members[0] != "alice" {
+			t.Errorf("Expected only alice left in website, got %+v", projects)
+		}
+	})
+}
+
+func TestServer(t *testing.T) {
+	accounts := NewAccounts(t.TempDir())
+	accounts.AddUser("alice", "alice's password")
+	accounts.AddUser("bob", "bob's password")
+	accounts.AddUser("carol", "carol's password")
+	accounts.SetProject("website", []string{"alice", "bob"})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := httptest.NewServer(NewHandler(Config{Accounts: accounts, Logger: logger}))
+	defer srv.Close()
+	// the server switches the storage path, put it back for other tests
+	defer storage.SetPath(storage.GetCurrentPath())
+
+	login := func(user, password string) string {
+		t.Helper()
+		token, err := Login(srv.URL, user, password, "test")
+		if err != nil {
+			t.Fatalf("Login of %s failed: %v", user, err)
+		}
+		return token
+	}
+	alice := login("alice", "alice's password")
+	bob := login("bob", "bob's password")
+	carol := login("carol", "carol's password")
+
+	// Test wrong passwords are refused
+	t.Run("Login", func(t *testing.T) {
+		_, err := Login(srv.URL, "alice", "bob's password", "test")
+		var herr *Error
+		if !errors.As(err, &herr) || herr.Status != http.StatusUnauthorized {
+			t.Errorf("Expected 401, got %v", err)
+		}
+		if _, err := NewClient(srv.URL, "gtd_bogus", "").List(); err == nil {
+			t.Error("Expected an unknown token to be refused")
+		}
+	})
+
+	// Test every user has a list of their own
+	t.Run("OwnLists", func(t *testing.T) {
+		a, b := NewClient(srv.URL, alice, ""), NewClient(srv.URL, bob, "")
+		task, err := a.AddTask(storage.Task{Content: "Alice's task", Priority: "high"})
+		if err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if task.ID != 1 || task.Priority != "high" {
+			t.Errorf("Expected task 1 with high priority, got %+v", task)
+		}
+		if _, err := b.AddTask(storage.Task{Content: "Bob's task"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		tasks, err := a.List()
+		if err != nil || len(tasks) != 1 || tasks[0].Content != "Alice's task" {
+			t.Fatalf("Expected only alice's task, got %+v (%v)", tasks, err)
+		}
+		done := true
+		if updated, err := a.Update(1, storage.TaskPatch{Done: &done}); err != nil || !updated.Done {
+			t.Errorf("Expected task done, got %+v (%v)", updated, err)
+		}
+		if err := a.Delete(2); err == nil || err.Error() != "task 2 not found" {
+			t.Errorf("Expected task 2 not found, got %v", err)
+		}
+		if err := b.Delete(1); err != nil {
+			t.Errorf("Delete failed: %v", err)
+		}
+		if tasks, _ := a.List(); len(tasks) != 1 {
+			t.Errorf("Expected bob's delete to leave alice's list alone, got %+v", tasks)
+		}
+	})
+
+	// Test project lists are shared by their members only
+	t.Run("Projects", func(t *testing.T) {
+		a, b := NewClient(srv.URL, alice, "website"), NewClient(srv.URL, bob, "website")
+		if _, err := a.AddTask(storage.Task{Content: "Launch"}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		task, err := b.AddComment(1, storage.Comment{Text: "On it"})
+		if err != nil {
+			t.Fatalf("Comment failed: %v", err)
+		}
+		if len(task.Comments) != 1 || task.Comments[0].Author != "bob" {
+			t.Errorf("Expected a comment by bob, got %+v", task.Comments)
+		}
+		var herr *Error
+		if _, err := NewClient(srv.URL, carol, "website").List(); !errors.As(err, &herr) || herr.Status != http.StatusForbidden {
+			t.Errorf("Expected 403 for a non-member, got %v", err)
+		}
+		if _, err := NewClient(srv.URL, alice, "nope").List(); !errors.As(err, &herr) || herr.Status != http.StatusNotFound {
+			t.Errorf("Expected 404 for an unknown project, got %v", err)
+		}
+		me, err := NewClient(srv.URL, bob, "").Me()
+		if err != nil || me.User != "bob" || len(me.Projects) != 1 || me.Projects[0] != "website" {
+			t.Errorf("Expected bob in website, got %+v (%v)", me, err)
+		}
+	})
+
+	// Test basic authentication logs in, but is no way around the token
+	t.Run("BasicAuth", func(t *testing.T) {
+		status := func(method, path, password string) int {
+			req, _ := http.NewRequest(method, srv.URL+path, nil)
+			req.SetBasicAuth("carol", password)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		for password, want := range map[string]int{"carol's password": http.StatusOK, "wrong": http.StatusUnauthorized} {
+			if got := status("POST", "/login?device=curl", password); got != want {
+				t.Errorf("Expected %d for password %q, got %d", want, password, got)
+			}
+		}
+		if got := status("GET", "/tasks", "carol's password"); got != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for basic authentication on /tasks, got %d", got)
+		}
+	})
+
+	// Test a client slow to send its body does not hold up other lists
+	t.Run("SlowBody", func(t *testing.T) {
+		body, slow := io.Pipe()
+		req, _ := http.NewRequest("POST", srv.URL+"/tasks", body)
+		req.Header.Set("Authorization", "Bearer "+bob)
+		go func() {
+			if resp, err := http.DefaultClient.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}()
+		slow.Write([]byte(`{"content": `))
+		defer slow.Close()
+		c := NewClient(srv.URL, alice, "")
+		c.http.Timeout = 2 * time.Second
+		if _, err := c.List(); err != nil {
+			t.Errorf("Expected the list while another body is on its way, got %v", err)
+		}
+	})
+
+	// Test addresses failing to log in too often are turned away
+	t.Run("RateLimit", func(t *testing.T) {
+		status := func(password string) int {
+			req, _ := http.NewRequest("POST", srv.URL+"/login", nil)
+			req.SetBasicAuth("carol", password)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		for i := 0; i < loginBurst; i++ {
+			status("wrong")
+		}
+		if got := status("carol's password"); got != http.StatusTooManyRequests {
+			t.Errorf("Expected 429 after failed logins, got %d", got)
+		}
+		var herr *Error
+		if _, err := Login(srv.URL, "carol", "carol's password", "test"); !errors.As(err, &herr) || herr.Status != http.StatusTooManyRequests {
+			t.Errorf("Expected 429 from /login, got %v", err)
+		}
+		if _, err := NewClient(srv.URL, carol, "").List(); err != nil {
+			t.Errorf("Expected tokens to keep working, got %v", err)
+		}
+	})
+
+	// Test logging out revokes the token
+	t.Run("Logout", func(t *testing.T) {
+		c := NewClient(srv.URL, carol, "")
+		if err := c.Logout(); err != nil {
+			t.Fatalf("Logout failed: %v", err)
+		}
+		if _, err := c.List(); err == nil {
+			t.Error("Expected the token to be revoked")
+		}
+	})
+}
+
+// a remote that can be switched off like a server going away
+type flakyRemote struct {
+	*Client
+	down bool
+	// calls that get through before the server goes down, no limit when 0
+	left int
+	// answer of a server that is up but takes no request
+	fail error
+}
+
+func (f *flakyRemote) check() error {
+	if f.fail != nil {
+		return f.fail
+	}
+	if f.down {
+		return fmt.Errorf("%w test server: connection refused", ErrUnreachable)
+	}
+	if f.left > 0 {
+		f.left--
+		f.down = f.left == 0
+	}
+	return nil
+}
+
+func (f *flakyRemote) List() ([]storage.Task, error) {
+	if err := f.check(); err != nil {
+		return nil, err
+	}
+	return f.Client.List()
+}
+
+func (f *flakyRemote) AddTask(t storage.Task) (storage.Task, error) {
+	if err := f.check(); err != nil {
+		return storage.Task{}, err
+	}
+	return f.Client.AddTask(t)
+}
+
+func (f *flakyRemote) Update(id int, p storage.TaskPatch) (storage.Task, error) {
+	if err := f.check(); err != nil {
+		return storage.Task{}, err
+	}
+	return f.Client.Update(id, p)
+}
+
+func (f *flakyRemote) Delete(id int) error {
+	if err := f.check(); err != nil {
+		return err
+	}
+	return f.Client.Delete(id)
+}
+
+func (f *flakyRemote) UpdateIf(id int, p storage.TaskPatch, etag string) (storage.Task, error) {
+	if err := f.check(); err != nil {
+		return storage.Task{}, err
+	}
+	return f.Client.UpdateIf(id, p, etag)
+}
+
+func (f *flakyRemote) DeleteIf(id int, etag string) error {
+	if err := f.check(); err != nil {
+		return err
+	}
+	return f.Client.DeleteIf(id, etag)
+}
+
+func (f *flakyRemote) AddComment(id int, cm storage.Comment) (storage.Task, error) {
+	if err := f.check(); err != nil {
+		return storage.Task{}, err
+	}
+	return f.Client.AddComment(id, cm)
+}
+
+func TestOfflineRemote(t *testing.T) {
+	accounts := NewAccounts(t.TempDir())
+	accounts.AddUser("alice", "alice's password")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := httptest.NewServer(NewHandler(Config{Accounts: accounts, Logger: logger}))
+	defer srv.Close()
+	defer storage.SetPath(storage.GetCurrentPath())
+	token, err := Login(srv.URL, "alice", "alice's password", "test")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	server := NewClient(srv.URL, token, "")
+	remote := &flakyRemote{Client: server}
+	o := NewOfflineRemote(remote, filepath.Join(t.TempDir(), "copy.json"))
+
+	// Test there is nothing to work on before the first fetch
+	t.Run("NoCopy", func(t *testing.T) {
+		remote.down = true
+		defer func() { remote.down = false }()
+		if _, err := o.List(); err == nil {
+			t.Error("Expected error without an offline copy")
+		}
+	})
+
+	// Test changes made offline are kept in the copy and queued
+	t.Run("Offline", func(t *testing.T) {
+		server.AddTask(storage.Task{Content: "Online task"})
+		server.AddTask(storage.Task{Content: "Doomed task"})
+		if tasks, err := o.List(); err != nil || len(tasks) != 2 {
+			t.Fatalf("Expected 2 tasks, got %+v (%v)", tasks, err)
+		}
+		remote.down = true
+		added, err := o.AddTask(storage.Task{Content: "Offline task"})
+		if err != nil || added.ID != -1 {
+			t.Fatalf("Expected offline task -1, got %+v (%v)", added, err)
+		}
+		done := true
+		if _, err := o.Update(-1, storage.TaskPatch{Done: &done}); err != nil {
+			t.Fatalf("Offline update failed: %v", err)
+		}
+		if err := o.Delete(2); err != nil {
+			t.Fatalf("Offline delete failed: %v", err)
+		}
+		if offline, _ := o.Offline(); !offline {
+			t.Error("Expected the remote to report working offline")
+		}
+		tasks, _ := o.List()
+		if len(tasks) != 2 || !tasks[1].Done {
+			t.Errorf("Expected the copy to show the changes, got %+v", tasks)
+		}
+		if queue, _ := o.Queue(); len(queue) != 3 {
+			t.Errorf("Expected 3 queued changes, got %+v", queue)
+		}
+	})
+
+	// Test the queue is replayed in order once the server is back, with
+	// the real ID of a task added offline
+	t.Run("Replay", func(t *testing.T) {
+		// someone else took ID 3 in the meantime
+		server.AddTask(storage.Task{Content: "Added elsewhere"})
+		remote.down = false
+		reachable, err := o.Flush()
+		if err != nil || !reachable {
+			t.Fatalf("Expected flush to reach the server, got %v (%v)", reachable, err)
+		}
+		tasks, _ := server.List()
+		if len(tasks) != 3 || tasks[2].ID != 4 || tasks[2].Content != "Offline task" || !tasks[2].Done {
+			t.Errorf("Expected the offline task done as task 4, got %+v", tasks)
+		}
+		if queue, _ := o.Queue(); len(queue) != 0 {
+			t.Errorf("Expected an empty queue, got %+v", queue)
+		}
+		if copied, _ := o.List(); len(copied) != 3 || copied[2].ID != 4 {
+			t.Errorf("Expected the copy to match the server, got %+v", copied)
+		}
+	})
+
+	// Test a change the server refuses is dropped and reported
+	t.Run("Rejected", func(t *testing.T) {
+		remote.down = true
+		if err := o.Delete(3); err != nil {
+			t.Fatalf("Offline delete failed: %v", err)
+		}
+		server.Delete(3)
+		remote.down = false
+		if _, err := o.List(); err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		rejected := o.Rejected()
+		if len(rejected) != 1 || rejected[0].Change.Kind != ChangeDelete {
+			t.Errorf("Expected the delete to be rejected, got %+v", rejected)
+		}
+		if queue, _ := o.Queue(); len(queue) != 0 {
+			t.Errorf("Expected an empty queue, got %+v", queue)
+		}
+	})
+
+	// Test a replay cut short keeps tasks added offline apart from the
+	// IDs the server gave out
+	t.Run("PartialReplay", func(t *testing.T) {
+		remote.down = true
+		first, _ := o.AddTask(storage.Task{Content: "First offline"})
+		second, _ := o.AddTask(storage.Task{Content: "Second offline"})
+		done := true
+		if _, err := o.Update(first.ID, storage.TaskPatch{Done: &done}); err != nil {
+			t.Fatalf("Offline update failed: %v", err)
+		}
+		if first.ID >= 0 || second.ID >= 0 || first.ID == second.ID {
+			t.Errorf("Expected two different IDs below zero, got %d and %d", first.ID, second.ID)
+		}
+		// the first add gets the ID the copy would have given the second
+		server.AddTask(storage.Task{Content: "Added meanwhile"})
+		remote.down, remote.left = false, 1
+		if reachable, _ := o.Flush(); reachable {
+			t.Fatal("Expected the server to go down during the flush")
+		}
+		queue, _ := o.Queue()
+		if len(queue) != 2 || queue[0].ID != second.ID || queue[1].ID != 6 {
+			t.Errorf("Expected the second add and the update of task 6 queued, got %+v", queue)
+		}
+		remote.down = false
+		if _, err := o.Flush(); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		tasks, _ := server.List()
+		if len(tasks) != 5 {
+			t.Fatalf("Expected 5 tasks, got %+v", tasks)
+		}
+		for _, task := range tasks[2:] {
+			if task.Done != (task.Content == "First offline") {
+				t.Errorf("Expected only the first offline task to be marked done, got %+v", task)
+			}
+		}
+	})
+
+	// Test changes to a task someone else changed since are refused
+	t.Run("Stale", func(t *testing.T) {
+		remote.down = true
+		content := "Changed offline"
+		if _, err := o.Update(1, storage.TaskPatch{Content: &content}); err != nil {
+			t.Fatalf("Offline update failed: %v", err)
+		}
+		if err := o.Delete(7); err != nil {
+			t.Fatalf("Offline delete failed: %v", err)
+		}
+		elsewhere := "Changed elsewhere"
+		server.Update(1, storage.TaskPatch{Content: &elsewhere})
+		server.Delete(7)
+		// the ID of the deleted task goes to a new one
+		server.AddTask(storage.Task{Content: "Reused ID"})
+		remote.down = false
+		if _, err := o.Flush(); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		rejected := o.Rejected()
+		var herr *Error
+		if len(rejected) != 2 || !errors.As(rejected[0].Err, &herr) || herr.Status != http.StatusPreconditionFailed {
+			t.Errorf("Expected both changes to be refused with 412, got %+v", rejected)
+		}
+		tasks, _ := server.List()
+		if len(tasks) != 5 || tasks[0].Content != elsewhere || tasks[4].ID != 7 || tasks[4].Content != "Reused ID" {
+			t.Errorf("Expected the server's tasks untouched, got %+v", tasks)
+		}
+	})
+
+	// Test a revoked login or a server error keeps the queue instead of
+	// dropping one change after another
+	t.Run("Held", func(t *testing.T) {
+		remote.down = true
+		if _, err := o.AddTask(storage.Task{Content: "Held task"}); err != nil {
+			t.Fatalf("Offline add failed: %v", err)
+		}
+		remote.down = false
+		for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusInternalServerError} {
+			remote.fail = &Error{Status: status, Message: http.StatusText(status)}
+			if _, err := o.Flush(); status < 429 && err == nil {
+				t.Errorf("Expected flush to fail with %d", status)
+			}
+			if queue, _ := o.Queue(); len(queue) != 1 {
+				t.Errorf("Expected the change kept after %d, got %+v", status, queue)
+			}
+			if rejected := o.Rejected(); len(rejected) != 0 {
+				t.Errorf("Expected nothing rejected after %d, got %+v", status, rejected)
+			}
+		}
+		remote.fail = &Error{Status: http.StatusUnauthorized, Message: "unauthorized"}
+		if _, err := o.AddTask(storage.Task{Content: "Not queued"}); err == nil {
+			t.Error("Expected an error while the login is refused")
+		}
+		remote.fail = nil
+		if _, err := o.Flush(); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+		tasks, _ := server.List()
+		if len(tasks) != 6 || tasks[5].Content != "Held task" {
+			t.Errorf("Expected the held task sent once, got %+v", tasks)
+		}
+	})
+}
+
+func TestRemoteBook(t *testing.T) {
+	book := NewRemoteBook(filepath.Join(t.TempDir(), "remotes.json"))
+	if err := book.Put(Remote{Name: "work", URL: "https://todo.example.com", User: "alice", Token: "gtd_x"}); err != nil {
+		t.Fatalf("Failed to save remote: %v", err)
+	}
+	r, ok, err := book.Get("work")
+	if err != nil || !ok || r.User != "alice" {
+		t.Errorf("Expected the work remote, got %+v %v %v", r, ok, err)
+	}
+	if err := book.Remove("home"); err == nil {
+		t.Error("Expected error removing an unknown remote")
+	}
+	if err := ValidateURL("todo.example.com"); err == nil {
+		t.Error("Expected error for a URL without a scheme")
+	}
+}
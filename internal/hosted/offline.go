@@ -0,0 +1,437 @@
+package hosted
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/api"
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+// kinds of QueuedChange
+const (
+	ChangeAdd     = "add"
+	ChangeUpdate  = "update"
+	ChangeDelete  = "delete"
+	ChangeComment = "comment"
+)
+
+// a change made while the server was unreachable, sent once it is back
+type QueuedChange struct {
+	Kind string `json:"kind"`
+	// the task changed; for an added task the negative ID it has in the
+	// copy until the server assigns the real one
+	ID int `json:"id"`
+	// ETag of the server's version of the task the change was made to.
+	// Updates and deletes are only sent while the task still has it.
+	ETag     string             `json:"etag,omitempty"`
+	Task     *storage.Task      `json:"task,omitempty"`
+	Patch    *storage.TaskPatch `json:"patch,omitempty"`
+	Comment  string             `json:"comment,omitempty"`
+	QueuedAt time.Time          `json:"queued_at"`
+}
+
+// how the change is shown to the user
+func (ch QueuedChange) String() string {
+	switch ch.Kind {
+	case ChangeAdd:
+		return fmt.Sprintf("add %q", ch.Task.Content)
+	case ChangeComment:
+		return fmt.Sprintf("comment on task %d", ch.ID)
+	case ChangeUpdate:
+		var fields []string
+		p := ch.Patch
+		for name, set := range map[string]bool{"content": p.Content != nil, "done": p.Done != nil, "tags": p.Tags != nil,
+			"priority": p.Priority != nil, "project": p.Project != nil, "due": p.Due != nil, "scheduled": p.Scheduled != nil} {
+			if set {
+				fields = append(fields, name)
+			}
+		}
+		slices.Sort(fields)
+		return fmt.Sprintf("change %s of task %d", strings.Join(fields, ", "), ch.ID)
+	}
+	return fmt.Sprintf("%s task %d", ch.Kind, ch.ID)
+}
+
+// a queued change the server refused when it was replayed
+type RejectedChange struct {
+	Change QueuedChange
+	Err    error
+}
+
+// the copy of a remote list kept on disk
+type offlineCopy struct {
+	FetchedAt time.Time      `json:"fetched_at"`
+	Tasks     []storage.Task `json:"tasks"`
+	Queue     []QueuedChange `json:"queue,omitempty"`
+}
+
+// a storage.Remote that keeps a copy of the list in a file. While the
+// server cannot be reached, the copy is read and changed instead and the
+// changes are queued; they are sent, in order, by the next call that
+// reaches the server.
+type OfflineRemote struct {
+	remote storage.Remote
+	path   string
+
+	// set by the last call that could not reach the server
+	offline bool
+	// changes refused by the server on replay, since the last Rejected
+	rejected []RejectedChange
+}
+
+func NewOfflineRemote(remote storage.Remote, path string) *OfflineRemote {
+	return &OfflineRemote{remote: remote, path: path}
+}
+
+func (o *OfflineRemote) load() (offlineCopy, error) {
+	data, err := os.ReadFile(o.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return offlineCopy{Tasks: []storage.Task{}}, nil
+		}
+		return offlineCopy{}, err
+	}
+	var c offlineCopy
+	if err := json.Unmarshal(data, &c); err != nil {
+		return offlineCopy{}, fmt.Errorf("failed to parse offline copy: %v", err)
+	}
+	return c, nil
+}
+
+func (o *OfflineRemote) save(c offlineCopy) error {
+	if err := os.MkdirAll(filepath.Dir(o.path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", " ")
+	if err != nil {
+		return err
+	}
+	// write and rename so a crash never leaves half a file behind
+	tmp := o.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, o.path)
+}
+
+// report whether the last call worked on the copy instead of the server,
+// and when the copy was last fetched
+func (o *OfflineRemote) Offline() (bool, time.Time) {
+	c, _ := o.load()
+	return o.offline, c.FetchedAt
+}
+
+// changes waiting for the server
+func (o *OfflineRemote) Queue() ([]QueuedChange, error) {
+	c, err := o.load()
+	return c.Queue, err
+}
+
+// changes the server refused on replay, they are dropped from the queue
+func (o *OfflineRemote) Rejected() []RejectedChange {
+	r := o.rejected
+	o.rejected = nil
+	return r
+}
+
+// send the queued changes, report whether the server was reachable. When
+// it was but took none of them, for a login it no longer accepts, the
+// queue is kept and the error returned.
+func (o *OfflineRemote) Flush() (bool, error) {
+	c, err := o.load()
+	if err != nil {
+		return false, err
+	}
+	stop := o.replay(&c)
+	if err := o.save(c); err != nil {
+		return false, err
+	}
+	if stop != nil && !Unreachable(stop) {
+		return true, fmt.Errorf("%v, %d queued changes kept", stop, len(c.Queue))
+	}
+	return stop == nil, nil
+}
+
+// a storage.Remote that can make a change depend on the task's ETag, like
+// Client
+type conditionalRemote interface {
+	UpdateIf(id int, p storage.TaskPatch, etag string) (storage.Task, error)
+	DeleteIf(id int, etag string) error
+}
+
+// send the queued changes in order. Changes the server refuses (see Refused),
+// also because the task changed there since, are dropped and remembered.
+// Any other error stops the replay with the change still queued and is
+// returned: the server is unreachable, or takes no change for now, such as
+// after the token was revoked.
+func (o *OfflineRemote) replay(c *offlineCopy) error {
+	if len(c.Queue) == 0 {
+		return nil
+	}
+	cond, _ := o.remote.(conditionalRemote)
+	for len(c.Queue) > 0 {
+		ch := c.Queue[0]
+		// the server's version of the task after the change
+		var result storage.Task
+		var err error
+		switch ch.Kind {
+		case ChangeAdd:
+			result, err = o.remote.AddTask(*ch.Task)
+		case ChangeUpdate:
+			if cond != nil && ch.ETag != "" {
+				result, err = cond.UpdateIf(ch.ID, *ch.Patch, ch.ETag)
+			} else {
+				result, err = o.remote.Update(ch.ID, *ch.Patch)
+			}
+		case ChangeDelete:
+			if cond != nil && ch.ETag != "" {
+				err = cond.DeleteIf(ch.ID, ch.ETag)
+			} else {
+				err = o.remote.Delete(ch.ID)
+			}
+		case ChangeComment:
+			result, err = o.remote.AddComment(ch.ID, storage.Comment{Text: ch.Comment})
+		default:
+			// written by a newer version, this one can never send it
+			o.rejected = append(o.rejected, RejectedChange{Change: ch, Err: fmt.Errorf("unknown change %q", ch.Kind)})
+			c.Queue = c.Queue[1:]
+			continue
+		}
+		if err != nil && !Refused(err) {
+			return err
+		}
+		if err != nil {
+			o.rejected = append(o.rejected, RejectedChange{Change: ch, Err: err})
+		}
+		c.Queue = c.Queue[1:]
+		if err == nil && result.ID != 0 {
+			rebase(c, ch.ID, result)
+		}
+	}
+	// the copy still has the tasks added offline under their old IDs
+	if tasks, err := o.remote.List(); err == nil {
+		c.Tasks, c.FetchedAt = tasks, time.Now()
+	}
+	return nil
+}
+
+// point the copy and the rest of the queue at t, the server's version of
+// the task known as id so far, so a replay cut short carries on from the
+// right IDs and ETags
+func rebase(c *offlineCopy, id int, t storage.Task) {
+	tag := api.ETag(t)
+	for i := range c.Queue {
+		if c.Queue[i].ID == id {
+			c.Queue[i].ID, c.Queue[i].ETag = t.ID, tag
+		}
+	}
+	if id != t.ID {
+		// a cached task with the new ID is gone from the server, or it
+		// would not have given the ID out again
+		c.Tasks = slices.DeleteFunc(c.Tasks, func(ct storage.Task) bool { return ct.ID == t.ID })
+		for i := range c.Tasks {
+			if c.Tasks[i].ID == id {
+				c.Tasks[i].ID = t.ID
+			}
+		}
+	}
+}
+
+// the ETag of the server's version of task t that changes made offline
+// build on: the one of the first queued change to it, else t's own
+func baseETag(c *offlineCopy, t storage.Task) string {
+	for _, ch := range c.Queue {
+		if ch.ID == t.ID {
+			return ch.ETag
+		}
+	}
+	return api.ETag(t)
+}
+
+// replay the queue and run call on the server. When the server cannot be
+// reached, offline changes the copy instead and the change is queued.
+func (o *OfflineRemote) do(call func(c *offlineCopy) error, offline func(c *offlineCopy) (QueuedChange, error)) error {
+	c, err := o.load()
+	if err != nil {
+		return err
+	}
+	o.offline = false
+	unreachable := o.replay(&c)
+	if unreachable != nil && !Unreachable(unreachable) {
+		// the server answers but takes none of the queue, so nothing new
+		// is sent or queued behind it either
+		if serr := o.save(c); serr != nil {
+			return serr
+		}
+		return fmt.Errorf("%v, %d queued changes kept", unreachable, len(c.Queue))
+	}
+	if unreachable == nil {
+		err = call(&c)
+		if err == nil || !Unreachable(err) {
+			if serr := o.save(c); err == nil {
+				err = serr
+			}
+			return err
+		}
+		unreachable = err
+	}
+	o.offline = true
+	if c.FetchedAt.IsZero() {
+		o.save(c)
+		return fmt.Errorf("%v, and there is no offline copy of the list yet", unreachable)
+	}
+	ch, err := offline(&c)
+	if err != nil {
+		return err
+	}
+	if ch.Kind != "" {
+		ch.QueuedAt = time.Now()
+		c.Queue = append(c.Queue, ch)
+	}
+	return o.save(c)
+}
+
+func (o *OfflineRemote) List() ([]storage.Task, error) {
+	var tasks []storage.Task
+	err := o.do(func(c *offlineCopy) error {
+		var err error
+		if tasks, err = o.remote.List(); err == nil {
+			c.Tasks, c.FetchedAt = tasks, time.Now()
+		}
+		return err
+	}, func(c *offlineCopy) (QueuedChange, error) {
+		tasks = c.Tasks
+		return QueuedChange{}, nil
+	})
+	return tasks, err
+}
+
+func (o *OfflineRemote) AddTask(t storage.Task) (storage.Task, error) {
+	var added storage.Task
+	err := o.do(func(c *offlineCopy) error {
+		var err error
+		if added, err = o.remote.AddTask(t); err == nil {
+			c.Tasks = append(c.Tasks, added)
+		}
+		return err
+	}, func(c *offlineCopy) (QueuedChange, error) {
+		// below every ID in the copy, so it never clashes with one the
+		// server gives out
+		added = t
+		added.ID = -1
+		for _, ct := range c.Tasks {
+			added.ID = min(added.ID, ct.ID-1)
+		}
+		added.CreatedAt = time.Now().Local().String()
+		c.Tasks = append(c.Tasks, added)
+		return QueuedChange{Kind: ChangeAdd, ID: added.ID, Task: &t}, nil
+	})
+	return added, err
+}
+
+func (o *OfflineRemote) Update(id int, p storage.TaskPatch) (storage.Task, error) {
+	var updated storage.Task
+	err := o.do(func(c *offlineCopy) error {
+		var err error
+		if updated, err = o.remote.Update(id, p); err == nil {
+			replaceTask(c, updated)
+		}
+		return err
+	}, func(c *offlineCopy) (QueuedChange, error) {
+		i := slices.IndexFunc(c.Tasks, func(t storage.Task) bool { return t.ID == id })
+		if i < 0 {
+			return QueuedChange{}, fmt.Errorf("task %d not found", id)
+		}
+		ch := QueuedChange{Kind: ChangeUpdate, ID: id, ETag: baseETag(c, c.Tasks[i]), Patch: &p}
+		applyPatch(&c.Tasks[i], p)
+		updated = c.Tasks[i]
+		return ch, nil
+	})
+	return updated, err
+}
+
+func (o *OfflineRemote) Delete(id int) error {
+	return o.do(func(c *offlineCopy) error {
+		err := o.remote.Delete(id)
+		if err == nil {
+			c.Tasks = slices.DeleteFunc(c.Tasks, func(t storage.Task) bool { return t.ID == id })
+		}
+		return err
+	}, func(c *offlineCopy) (QueuedChange, error) {
+		i := slices.IndexFunc(c.Tasks, func(t storage.Task) bool { return t.ID == id })
+		if i < 0 {
+			return QueuedChange{}, fmt.Errorf("task %d not found", id)
+		}
+		ch := QueuedChange{Kind: ChangeDelete, ID: id, ETag: baseETag(c, c.Tasks[i])}
+		c.Tasks = slices.Delete(c.Tasks, i, i+1)
+		return ch, nil
+	})
+}
+
+func (o *OfflineRemote) AddComment(id int, cm storage.Comment) (storage.Task, error) {
+	var updated storage.Task
+	err := o.do(func(c *offlineCopy) error {
+		var err error
+		if updated, err = o.remote.AddComment(id, cm); err == nil {
+			replaceTask(c, updated)
+		}
+		return err
+	}, func(c *offlineCopy) (QueuedChange, error) {
+		i := slices.IndexFunc(c.Tasks, func(t storage.Task) bool { return t.ID == id })
+		if i < 0 {
+			return QueuedChange{}, fmt.Errorf("task %d not found", id)
+		}
+		ch := QueuedChange{Kind: ChangeComment, ID: id, ETag: baseETag(c, c.Tasks[i]), Comment: cm.Text}
+		cm.CreatedAt = time.Now().Local().String()
+		c.Tasks[i].Comments = append(c.Tasks[i].Comments, cm)
+		updated = c.Tasks[i]
+		return ch, nil
+	})
+	return updated, err
+}
+
+// put the server's copy of a task in place of the cached one
+func replaceTask(c *offlineCopy, t storage.Task) {
+	if i := slices.IndexFunc(c.Tasks, func(ct storage.Task) bool { return ct.ID == t.ID }); i >= 0 {
+		c.Tasks[i] = t
+	}
+}
+
+// what the server would do with a patch, for the offline copy
+func applyPatch(t *storage.Task, p storage.TaskPatch) {
+	if p.Content != nil {
+		t.Content = strings.TrimSpace(*p.Content)
+	}
+	if p.Done != nil && *p.Done != t.Done {
+		t.Done = *p.Done
+		t.CompletedAt = ""
+		if t.Done {
+			t.CompletedAt = time.Now().Local().String()
+			if p.CompletedAt != nil {
+				t.CompletedAt = *p.CompletedAt
+			}
+		}
+	}
+	if p.Tags != nil {
+		t.Tags = *p.Tags
+	}
+	if p.Priority != nil {
+		t.Priority = *p.Priority
+	}
+	if p.Project != nil {
+		t.Project = *p.Project
+	}
+	if p.Due != nil {
+		t.Due = *p.Due
+	}
+	if p.Scheduled != nil {
+		t.Scheduled = *p.Scheduled
+	}
+}
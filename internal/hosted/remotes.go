@@ -0,0 +1,127 @@
+package hosted
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// a hosted server logged in to from this machine
+type Remote struct {
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	User  string `json:"user"`
+	Token string `json:"token"`
+	// the shared project worked on, the user's own list when empty
+	Project string `json:"project,omitempty"`
+}
+
+// the client for the remote's list
+func (r Remote) Client() *Client {
+	return NewClient(r.URL, r.Token, r.Project)
+}
+
+// how the remote is shown to the user
+func (r Remote) String() string {
+	s := r.User + " @ " + r.URL
+	if r.Project != "" {
+		s += ", project " + r.Project
+	}
+	return s
+}
+
+// named remotes kept in a JSON file
+type RemoteBook struct {
+	path string
+}
+
+func NewRemoteBook(path string) *RemoteBook {
+	return &RemoteBook{path: path}
+}
+
+func (b *RemoteBook) load() (map[string]Remote, error) {
+	data, err := os.ReadFile(b.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]Remote{}, nil
+		}
+		return nil, err
+	}
+	var list []Remote
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse remotes file: %v", err)
+	}
+	remotes := make(map[string]Remote, len(list))
+	for _, r := range list {
+		remotes[r.Name] = r
+	}
+	return remotes, nil
+}
+
+func (b *RemoteBook) save(remotes map[string]Remote) error {
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(sortedRemotes(remotes), "", " ")
+	if err != nil {
+		return err
+	}
+	// entries hold tokens
+	return os.WriteFile(b.path, data, 0600)
+}
+
+func sortedRemotes(remotes map[string]Remote) []Remote {
+	list := make([]Remote, 0, len(remotes))
+	for _, r := range remotes {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// all remotes sorted by name
+func (b *RemoteBook) List() ([]Remote, error) {
+	remotes, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+	return sortedRemotes(remotes), nil
+}
+
+// look up a remote by name
+func (b *RemoteBook) Get(name string) (Remote, bool, error) {
+	remotes, err := b.load()
+	if err != nil {
+		return Remote{}, false, err
+	}
+	r, ok := remotes[name]
+	return r, ok, nil
+}
+
+// add or replace a remote
+func (b *RemoteBook) Put(r Remote) error {
+	if err := ValidateName(r.Name); err != nil {
+		return err
+	}
+	remotes, err := b.load()
+	if err != nil {
+		return err
+	}
+	remotes[r.Name] = r
+	return b.save(remotes)
+}
+
+func (b *RemoteBook) Remove(name string) error {
+	remotes, err := b.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := remotes[name]; !ok {
+		return fmt.Errorf("no remote named %q", name)
+	}
+	delete(remotes, name)
+	return b.save(remotes)
+}
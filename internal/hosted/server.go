@@ -0,0 +1,304 @@
+package hosted
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/api"
+	"github.com/ethanbao27/gotodo/internal/network"
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+// longest login request body accepted
+const maxLoginSize = 4 << 10
+
+// failed logins allowed per IP address: a burst of loginBurst, then one per
+// loginInterval
+const (
+	loginBurst    = 10
+	loginInterval = 6 * time.Second
+)
+
+// settings of a hosted server
+type Config struct {
+	Accounts *Accounts
+	// defaults to slog.Default()
+	Logger *slog.Logger
+	// one entry per request, defaults to Logger
+	AccessLog *slog.Logger
+}
+
+type server struct {
+	cfg   Config
+	tasks http.Handler
+	// failed logins per IP address, every check of a password is costly
+	logins *network.RateLimiter
+
+	storesMu sync.Mutex
+	// the lists opened so far by path, one per user or project
+	stores map[string]*storage.Store
+}
+
+// who a request is from and which list it is for, filled in as the
+// request is handled
+type requestInfo struct {
+	user    string
+	project string
+	store   *storage.Store
+}
+
+type infoKey struct{}
+
+// the HTTP handler of a hosted server. Every user has a task list of their
+// own under /tasks, the members of a project share the list under
+// /projects/{name}/tasks; both speak the REST API of 'gotodo serve http'.
+//
+//	POST /login                 {"user", "password", "device"} -> {"token"},
+//	                            or basic authentication and ?device=
+//	POST /logout                revoke the token used
+//	GET  /me                    {"user", "projects"}
+//	     /tasks...              the user's own list
+//	     /projects/{name}/tasks... a shared project's list
+//
+// Requests other than /login need a token from /login as a bearer token.
+// Passwords are only taken by /login, checking one is slow on purpose.
+// Addresses that fail to log in too often get 429 for a while.
+func NewHandler(cfg Config) http.Handler {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	if cfg.AccessLog == nil {
+		cfg.AccessLog = cfg.Logger
+	}
+	s := &server{
+		cfg:    cfg,
+		logins: network.NewRateLimiter(1/loginInterval.Seconds(), loginBurst),
+		stores: map[string]*storage.Store{},
+	}
+	s.tasks = api.NewHandler(api.Config{
+		Author: func(r *http.Request) string {
+			// comments in a shared list say who wrote them
+			if info(r).project != "" {
+				return info(r).user
+			}
+			return ""
+		},
+		Store: func(r *http.Request) *storage.Store {
+			return info(r).store
+		},
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", s.login)
+	mux.HandleFunc("POST /logout", s.authenticate(s.logout))
+	mux.HandleFunc("GET /me", s.authenticate(s.me))
+	mux.HandleFunc("/tasks", s.authenticate(s.userTasks))
+	mux.HandleFunc("/tasks/", s.authenticate(s.userTasks))
+	mux.HandleFunc("/projects/{project}/tasks", s.authenticate(s.projectTasks))
+	mux.HandleFunc("/projects/{project}/tasks/", s.authenticate(s.projectTasks))
+	mux.Handle("GET /openapi.json", s.tasks)
+	return s.log(mux)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+// errors have the same shape as those of the REST API
+func writeError(w http.ResponseWriter, status int, format string, args ...any) {
+	writeJSON(w, status, map[string]string{"error": fmt.Sprintf(format, args...)})
+}
+
+func info(r *http.Request) *requestInfo {
+	if i, ok := r.Context().Value(infoKey{}).(*requestInfo); ok {
+		return i
+	}
+	return &requestInfo{}
+}
+
+// status code written by a handler, for the log
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (s *server) log(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		i := &requestInfo{}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), infoKey{}, i)))
+		s.cfg.AccessLog.Info("request", "remote", r.RemoteAddr, "user", i.user,
+			"method", r.Method, "path", r.URL.Path, "status", sw.status, "duration", time.Since(start))
+	})
+}
+
+// check the bearer token and pass the user on
+func (s *server) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, bearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !bearer {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gotodo"`)
+			writeError(w, http.StatusUnauthorized, "log in with 'gotodo remote login' or POST /login, then send the token as a bearer token")
+			return
+		}
+		user, ok, err := s.cfg.Accounts.Authenticate(token)
+		if err != nil {
+			s.cfg.Logger.Error("cannot check credentials", "error", err)
+			writeError(w, http.StatusInternalServerError, "server cannot check credentials")
+			return
+		}
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gotodo", error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, "invalid or revoked token")
+			return
+		}
+		info(r).user = user
+		next(w, r)
+	}
+}
+
+// answer 429 when the client has run out of failed logins
+func (s *server) loginsLimited(w http.ResponseWriter, r *http.Request) bool {
+	if !s.logins.Limited(r.RemoteAddr, time.Now()) {
+		return false
+	}
+	s.cfg.Logger.Warn("too many failed logins", "remote", r.RemoteAddr)
+	w.Header().Set("Retry-After", fmt.Sprint(int(loginInterval.Seconds())))
+	writeError(w, http.StatusTooManyRequests, "too many failed logins, try again later")
+	return true
+}
+
+// body of POST /login
+type loginRequest struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+	// name of the device the token is for, logging in again from the
+	// same device replaces its token
+	Device string `json:"device"`
+}
+
+type loginResponse struct {
+	User  string `json:"user"`
+	Token string `json:"token"`
+}
+
+// POST /login
+func (s *server) login(w http.ResponseWriter, r *http.Request) {
+	var body loginRequest
+	if name, password, basic := r.BasicAuth(); basic {
+		body = loginRequest{User: name, Password: password, Device: r.URL.Query().Get("device")}
+	} else if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLoginSize)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: %v", err)
+		return
+	}
+	if s.loginsLimited(w, r) {
+		return
+	}
+	token, err := s.cfg.Accounts.Login(body.User, body.Password, body.Device)
+	if errors.Is(err, ErrBadLogin) {
+		s.logins.Allow(r.RemoteAddr, time.Now())
+		s.cfg.Logger.Warn("failed login", "remote", r.RemoteAddr, "user", body.User)
+		writeError(w, http.StatusUnauthorized, "%v", err)
+		return
+	}
+	if err != nil {
+		s.cfg.Logger.Error("login failed", "user", body.User, "error", err)
+		writeError(w, http.StatusInternalServerError, "login failed")
+		return
+	}
+	info(r).user = body.User
+	writeJSON(w, http.StatusOK, loginResponse{User: body.User, Token: token})
+}
+
+// POST /logout
+func (s *server) logout(w http.ResponseWriter, r *http.Request) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		if err := s.cfg.Accounts.Logout(token); err != nil {
+			writeError(w, http.StatusInternalServerError, "logout failed")
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// body of GET /me
+type Me struct {
+	User     string   `json:"user"`
+	Projects []string `json:"projects"`
+}
+
+// GET /me
+func (s *server) me(w http.ResponseWriter, r *http.Request) {
+	projects, err := s.cfg.Accounts.ProjectsOf(info(r).user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load projects")
+		return
+	}
+	writeJSON(w, http.StatusOK, Me{User: info(r).user, Projects: projects})
+}
+
+// /tasks... on the user's own list
+func (s *server) userTasks(w http.ResponseWriter, r *http.Request) {
+	s.serveList(w, r, s.cfg.Accounts.UserPath(info(r).user), s.tasks)
+}
+
+// /projects/{project}/tasks... on a shared list, for members only
+func (s *server) projectTasks(w http.ResponseWriter, r *http.Request) {
+	project := r.PathValue("project")
+	exists, member, err := s.cfg.Accounts.Member(project, info(r).user)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load projects")
+		return
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, "project %q not found", project)
+		return
+	}
+	if !member {
+		writeError(w, http.StatusForbidden, "you are not a member of project %q", project)
+		return
+	}
+	info(r).project = project
+	s.serveList(w, r, s.cfg.Accounts.ProjectPath(project), http.StripPrefix("/projects/"+project, s.tasks))
+}
+
+// the store of the list at path, opened on first use
+func (s *server) store(path string) *storage.Store {
+	s.storesMu.Lock()
+	defer s.storesMu.Unlock()
+	st, ok := s.stores[path]
+	if !ok {
+		st = storage.NewStore(path)
+		s.stores[path] = st
+	}
+	return st
+}
+
+// hand a request to the REST API working on the list at path
+func (s *server) serveList(w http.ResponseWriter, r *http.Request, path string, h http.Handler) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		s.cfg.Logger.Error("cannot create list directory", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to load tasks")
+		return
+	}
+	info(r).store = s.store(path)
+	h.ServeHTTP(w, r)
+}
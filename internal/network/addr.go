@@ -0,0 +1,59 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// port used when an address doesn't name one
+const DefaultPort = 8088
+
+// turn "host", "host:port", "[v6]:port" or a bare IPv6 literal into a
+// host:port string, filling in port when the address has none. An empty
+// host is only accepted when allowEmptyHost is set (listening on all
+// interfaces).
+func NormalizeAddr(addr string, port int, allowEmptyHost bool) (string, error) {
+	addr = strings.TrimSpace(addr)
+	if addr == "" && !allowEmptyHost {
+		return "", fmt.Errorf("address is empty")
+	}
+
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		// no port given, which SplitHostPort reports as an error
+		host = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+		if _, perr := netip.ParseAddr(host); strings.Contains(host, ":") && perr != nil {
+			return "", fmt.Errorf("invalid address %q: %v", addr, err)
+		}
+		portStr = strconv.Itoa(port)
+	}
+
+	if host == "" && !allowEmptyHost {
+		return "", fmt.Errorf("invalid address %q: missing host", addr)
+	}
+	if strings.ContainsAny(host, " /") {
+		return "", fmt.Errorf("invalid address %q: bad host %q", addr, host)
+	}
+	if err := ValidatePort(portStr); err != nil {
+		return "", fmt.Errorf("invalid address %q: %v", addr, err)
+	}
+	return net.JoinHostPort(host, portStr), nil
+}
+
+// check that p is a TCP port number between 1 and 65535
+func ValidatePort(p string) error {
+	n, err := strconv.Atoi(p)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port %q (want 1-65535)", p)
+	}
+	return nil
+}
+
+// report whether addr already names a port
+func HasPort(addr string) bool {
+	_, _, err := net.SplitHostPort(strings.TrimSpace(addr))
+	return err == nil
+}
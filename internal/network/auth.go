@@ -0,0 +1,146 @@
+package network
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// prefix of generated tokens, makes them easy to recognise
+const tokenPrefix = "gtd_"
+
+// a token issued to one friend, only its hash is kept
+type FriendToken struct {
+	Name      string `json:"name"`
+	Hash      string `json:"hash"`
+	CreatedAt string `json:"created_at"`
+}
+
+// pre-shared friend tokens kept in a JSON file. The file is read on every
+// call so tokens revoked while a server is running stop working at once.
+type TokenStore struct {
+	path string
+}
+
+func NewTokenStore(path string) *TokenStore {
+	return &TokenStore{path: path}
+}
+
+func (s *TokenStore) load() ([]FriendToken, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []FriendToken{}, nil
+		}
+		return nil, err
+	}
+	var tokens []FriendToken
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("failed to parse token file: %v", err)
+	}
+	return tokens, nil
+}
+
+func (s *TokenStore) save(tokens []FriendToken) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(tokens, "", " ")
+	if err != nil {
+		return err
+	}
+	// tokens grant access to the task list, keep them private
+	return os.WriteFile(s.path, data, 0600)
+}
+
+// all issued tokens sorted by friend name
+func (s *TokenStore) List() ([]FriendToken, error) {
+	tokens, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Name < tokens[j].Name })
+	return tokens, nil
+}
+
+// issue a new token for name, replacing any previous one, and return it.
+// The token itself is not stored and cannot be shown again.
+func (s *TokenStore) Create(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("friend name is empty")
+	}
+	tokens, err := s.load()
+	if err != nil {
+		return "", err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := tokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	kept := tokens[:0]
+	for _, t := range tokens {
+		if t.Name != name {
+			kept = append(kept, t)
+		}
+	}
+	kept = append(kept, FriendToken{Name: name, Hash: hashToken(token), CreatedAt: time.Now().Local().String()})
+	return token, s.save(kept)
+}
+
+// remove the token of name
+func (s *TokenStore) Revoke(name string) error {
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	kept := tokens[:0]
+	for _, t := range tokens {
+		if t.Name != name {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == len(tokens) {
+		return fmt.Errorf("no token for friend %q", name)
+	}
+	return s.save(kept)
+}
+
+// report whether any token has been issued
+func (s *TokenStore) Enabled() (bool, error) {
+	tokens, err := s.load()
+	return len(tokens) > 0, err
+}
+
+// return the friend name a token was issued to
+func (s *TokenStore) Verify(token string) (string, bool, error) {
+	tokens, err := s.load()
+	if err != nil {
+		return "", false, err
+	}
+	hash := []byte(hashToken(token))
+	name, ok := "", false
+	// compare against every entry so timing doesn't reveal a match
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(t.Hash)) == 1 {
+			name, ok = t.Name, true
+		}
+	}
+	return name, ok, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
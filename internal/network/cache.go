@@ -0,0 +1,137 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+// the last list fetched from a friend
+type CachedList struct {
+	Friend    string         `json:"friend"`
+	Addr      string         `json:"addr"`
+	FetchedAt time.Time      `json:"fetched_at"`
+	Tasks     []storage.Task `json:"tasks"`
+}
+
+// copies of friends' lists kept in a directory, one JSON file per friend
+type FriendCache struct {
+	dir string
+}
+
+func NewFriendCache(dir string) *FriendCache {
+	return &FriendCache{dir: dir}
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// the file of a saved friend's name or an address. Names start with a
+// letter, so the "@" keeps addresses from colliding with them.
+func (c *FriendCache) path(key string) string {
+	if ValidateFriendName(key) != nil {
+		key = "@" + unsafeFileChars.ReplaceAllString(key, "_")
+	}
+	return filepath.Join(c.dir, key+".json")
+}
+
+// the cached list of key, ok is false when there is none
+func (c *FriendCache) Load(key string) (CachedList, bool, error) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return CachedList{}, false, nil
+		}
+		return CachedList{}, false, err
+	}
+	var list CachedList
+	if err := json.Unmarshal(data, &list); err != nil {
+		return CachedList{}, false, fmt.Errorf("failed to parse cached list: %v", err)
+	}
+	return list, true, nil
+}
+
+func (c *FriendCache) Save(key string, list CachedList) error {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(list, "", " ")
+	if err != nil {
+		return err
+	}
+	// write and rename so a crash never leaves half a file behind
+	tmp := c.path(key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path(key))
+}
+
+func (c *FriendCache) Remove(key string) error {
+	if err := os.Remove(c.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// how a friend's list changed between two fetches
+type TaskDiff struct {
+	Added     []storage.Task
+	Completed []storage.Task
+	Reopened  []storage.Task
+	// content, tags, priority, project, due date or comments changed
+	Changed []storage.Task
+	Removed []storage.Task
+}
+
+func (d TaskDiff) Empty() bool {
+	return len(d.Added)+len(d.Completed)+len(d.Reopened)+len(d.Changed)+len(d.Removed) == 0
+}
+
+// compare two copies of a list, tasks are matched by UUID, or by ID for
+// servers that don't send one
+func DiffTasks(before, after []storage.Task) TaskDiff {
+	key := func(t storage.Task) string {
+		if t.UUID != "" {
+			return t.UUID
+		}
+		return fmt.Sprintf("#%d", t.ID)
+	}
+	old := make(map[string]storage.Task, len(before))
+	for _, t := range before {
+		old[key(t)] = t
+	}
+
+	var d TaskDiff
+	for _, t := range after {
+		prev, ok := old[key(t)]
+		if !ok {
+			d.Added = append(d.Added, t)
+			continue
+		}
+		delete(old, key(t))
+		switch {
+		case t.Done && !prev.Done:
+			d.Completed = append(d.Completed, t)
+		case !t.Done && prev.Done:
+			d.Reopened = append(d.Reopened, t)
+		}
+		if t.Content != prev.Content || !slices.Equal(t.Tags, prev.Tags) || t.Priority != prev.Priority ||
+			t.Project != prev.Project || t.Due != prev.Due || t.Scheduled != prev.Scheduled || len(t.Comments) != len(prev.Comments) {
+			d.Changed = append(d.Changed, t)
+		}
+	}
+	// keep the order of the old list
+	for _, t := range before {
+		if _, ok := old[key(t)]; ok {
+			d.Removed = append(d.Removed, t)
+		}
+	}
+	return d
+}
@@ -1,47 +1,242 @@
 package network
 
 import (
-	"encoding/json"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"time"
 
 	"github.com/ethanbao27/gotodo/internal/storage"
 	"github.com/ethanbao27/gotodo/internal/ui"
 	"github.com/fatih/color"
 )
 
-// connect to friend and fetch tasks
-func ConnectAndFetch(addr string) error {
-	port := ":8088"
-	conn, err := net.Dial("tcp", addr+port)
+// a connection to a friend server that has completed the handshake
+type Client struct {
+	conn          net.Conn
+	codec         *codec
+	ServerVersion int
+}
+
+// how long to wait for a friend to accept the connection
+const dialTimeout = 10 * time.Second
+
+// settings for connecting to a friend server
+type DialOptions struct {
+	// token issued by the friend with 'gotodo friend token create'
+	Token string
+	// trusted certificate fingerprints, new friends are pinned on first use
+	Pins Pinner
+	// when set, the connection fails once it has been open this long
+	Timeout time.Duration
+}
+
+// connect to a friend server at addr (host:port) and do the handshake
+func Dial(addr string, opts DialOptions) (*Client, error) {
+	if opts.Pins == nil {
+		return nil, fmt.Errorf("no certificate pin store given")
+	}
+	pinned, known, err := opts.Pins.Pinned(addr)
 	if err != nil {
-		return fmt.Errorf("dial error: %w", err)
+		return nil, fmt.Errorf("failed to read pinned certificates: %v", err)
 	}
-	defer conn.Close()
 
-	// send request
-	fmt.Fprintf(conn, "GET_TODOS\n")
+	var presented string
+	netDialer := &net.Dialer{Timeout: dialTimeout}
+	var deadline time.Time
+	if opts.Timeout > 0 {
+		deadline = time.Now().Add(opts.Timeout)
+		netDialer.Deadline = deadline
+	}
+	dialer := &tls.Dialer{
+		NetDialer: netDialer,
+		Config: &tls.Config{
+			MinVersion: tls.VersionTLS13,
+			// friend certificates are self-signed, they are checked
+			// against the pinned fingerprint instead of a CA
+			InsecureSkipVerify: true,
+			VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				if len(rawCerts) == 0 {
+					return fmt.Errorf("friend presented no certificate")
+				}
+				presented = Fingerprint(rawCerts[0])
+				if known && presented != pinned {
+					return &FingerprintMismatchError{Addr: addr, Expected: pinned, Got: presented}
+				}
+				return nil
+			},
+		},
+	}
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		var mismatch *FingerprintMismatchError
+		if errors.As(err, &mismatch) {
+			return nil, mismatch
+		}
+		return nil, fmt.Errorf("dial error: %w", err)
+	}
+	if !known {
+		if err := opts.Pins.Pin(addr, presented); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to pin certificate: %v", err)
+		}
+		color.New(color.FgYellow).Printf("Trusting %s on first use, certificate fingerprint %s\n", addr, presented)
+	}
 
-	// read response
-	resp, err := io.ReadAll(conn)
+	if opts.Timeout > 0 {
+		// covers the handshake and every request after it
+		conn.SetDeadline(deadline)
+	}
+	c, err := newClient(conn, opts)
 	if err != nil {
-		return fmt.Errorf("read error: %w", err)
+		conn.Close()
+		return nil, err
 	}
+	return c, nil
+}
 
-	var tasks []storage.Task
-	if err := json.Unmarshal(resp, &tasks); err != nil {
-		return fmt.Errorf("unmarshal error: %w", err)
+func newClient(conn net.Conn, opts DialOptions) (*Client, error) {
+	c := &Client{conn: conn, codec: newCodec(conn)}
+	resp, err := c.roundTrip(Request{Type: RequestHello, Version: ProtocolVersion, Token: opts.Token})
+	if err != nil {
+		return nil, fmt.Errorf("handshake failed: %w", err)
 	}
+	c.ServerVersion = resp.Version
+	return c, nil
+}
+
+// send one request and wait for its response, turning error responses
+// into *Error
+func (c *Client) roundTrip(req Request) (Response, error) {
+	if err := c.codec.write(req); err != nil {
+		return Response{}, fmt.Errorf("write error: %w", err)
+	}
+	var resp Response
+	if err := c.codec.read(&resp); err != nil {
+		if err == io.EOF {
+			return Response{}, fmt.Errorf("read error: connection closed by server")
+		}
+		return Response{}, fmt.Errorf("read error: %w", err)
+	}
+	if resp.Error != nil {
+		return resp, resp.Error
+	}
+	if resp.Type != req.Type {
+		return resp, fmt.Errorf("unexpected %q response to %q request", resp.Type, req.Type)
+	}
+	return resp, nil
+}
+
+// fetch the friend's tasks
+func (c *Client) ListTasks() ([]storage.Task, error) {
+	resp, err := c.roundTrip(Request{Type: RequestList})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Tasks, nil
+}
+
+// the outcome of a change sent to a friend
+type ChangeResult struct {
+	// the task as the friend shares it, nil when Queued
+	Task *storage.Task
+	// the change waits for the friend's approval
+	Queued bool
+}
+
+func (c *Client) change(req Request) (ChangeResult, error) {
+	resp, err := c.roundTrip(req)
+	if err != nil {
+		return ChangeResult{}, err
+	}
+	return ChangeResult{Task: resp.Task, Queued: resp.Queued}, nil
+}
+
+// add a task to the friend's list, only content, tags, priority, project
+// and the due and scheduled dates are sent
+func (c *Client) AddTask(t storage.Task) (ChangeResult, error) {
+	nt := storage.Task{Content: t.Content, Tags: t.Tags, Priority: t.Priority, Project: t.Project, Due: t.Due, Scheduled: t.Scheduled}
+	return c.change(Request{Type: RequestAdd, Task: &nt})
+}
+
+// mark one of the friend's tasks done
+func (c *Client) SetDone(id int) (ChangeResult, error) {
+	return c.change(Request{Type: RequestDone, ID: id})
+}
+
+// comment on one of the friend's tasks
+func (c *Client) Comment(id int, text string) (ChangeResult, error) {
+	return c.change(Request{Type: RequestComment, ID: id, Text: text})
+}
+
+// send our changes and return the friend's
+func (c *Client) Sync(batch SyncBatch) (SyncBatch, error) {
+	resp, err := c.roundTrip(Request{Type: RequestSync, Sync: &batch})
+	if err != nil {
+		return SyncBatch{}, err
+	}
+	if resp.Sync == nil {
+		return SyncBatch{}, fmt.Errorf("sync response without changes")
+	}
+	return *resp.Sync, nil
+}
+
+// start watching the friend's list and return its current tasks. The
+// connection can only be used for NextEvent afterwards.
+func (c *Client) Subscribe() ([]storage.Task, error) {
+	resp, err := c.roundTrip(Request{Type: RequestSubscribe})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Tasks, nil
+}
+
+// wait for the next change after Subscribe
+func (c *Client) NextEvent() (storage.Event, error) {
+	for {
+		var resp Response
+		if err := c.codec.read(&resp); err != nil {
+			if err == io.EOF {
+				return storage.Event{}, fmt.Errorf("connection closed by server")
+			}
+			return storage.Event{}, fmt.Errorf("read error: %w", err)
+		}
+		if resp.Error != nil {
+			return storage.Event{}, resp.Error
+		}
+		if resp.Type == ResponseEvent && resp.Event != nil {
+			return *resp.Event, nil
+		}
+	}
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// connect to friend at addr (host:port) and fetch their tasks
+func FetchTasks(addr string, opts DialOptions) ([]storage.Task, error) {
+	client, err := Dial(addr, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return client.ListTasks()
+}
 
+// print a friend's task list, name is shown in the header
+func PrintTasks(name string, tasks []storage.Task) {
 	if len(tasks) == 0 {
 		color.New(color.FgYellow).Println("No tasks received from friend.")
-		return nil
+		return
 	}
 
 	// ==== Header ====
 	fmt.Println()
-	color.New(color.FgBlue, color.Bold).Printf(" Friend's Todo List @ %s\n", addr)
+	color.New(color.FgBlue, color.Bold).Printf(" Friend's Todo List @ %s\n", name)
 	color.New(color.FgWhite, color.Faint).Printf("  %d tasks\n\n", len(tasks))
 
 	// ==== Stats ====
@@ -80,5 +275,4 @@ func ConnectAndFetch(addr string) error {
 
 	fmt.Println()
 	ui.PrintProgressSummary(doneCount, totalCount, progress)
-	return nil
 }
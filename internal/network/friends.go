@@ -0,0 +1,172 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// a named friend in the address book
+type Friend struct {
+	Name        string `json:"name"`
+	Addr        string `json:"addr"`
+	Token       string `json:"token,omitempty"`
+	LastSeen    string `json:"last_seen,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+var friendNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// check that name can't be mistaken for an address
+func ValidateFriendName(name string) error {
+	if !friendNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid friend name %q (letters, digits, '-' and '_', starting with a letter)", name)
+	}
+	return nil
+}
+
+// named friends kept in a JSON file
+type AddressBook struct {
+	path string
+}
+
+func NewAddressBook(path string) *AddressBook {
+	return &AddressBook{path: path}
+}
+
+func (b *AddressBook) load() (map[string]Friend, error) {
+	data, err := os.ReadFile(b.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]Friend{}, nil
+		}
+		return nil, err
+	}
+	var list []Friend
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse address book: %v", err)
+	}
+	friends := make(map[string]Friend, len(list))
+	for _, f := range list {
+		friends[f.Name] = f
+	}
+	return friends, nil
+}
+
+func (b *AddressBook) save(friends map[string]Friend) error {
+	list := make([]Friend, 0, len(friends))
+	for _, f := range friends {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(list, "", " ")
+	if err != nil {
+		return err
+	}
+	// entries may hold tokens
+	return os.WriteFile(b.path, data, 0600)
+}
+
+// all friends sorted by name
+func (b *AddressBook) List() ([]Friend, error) {
+	friends, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Friend, 0, len(friends))
+	for _, f := range friends {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+// look up a friend by name
+func (b *AddressBook) Get(name string) (Friend, bool, error) {
+	friends, err := b.load()
+	if err != nil {
+		return Friend{}, false, err
+	}
+	f, ok := friends[name]
+	return f, ok, nil
+}
+
+// add or replace a friend
+func (b *AddressBook) Put(f Friend) error {
+	if err := ValidateFriendName(f.Name); err != nil {
+		return err
+	}
+	friends, err := b.load()
+	if err != nil {
+		return err
+	}
+	friends[f.Name] = f
+	return b.save(friends)
+}
+
+// change a stored friend in place
+func (b *AddressBook) update(name string, fn func(*Friend)) error {
+	friends, err := b.load()
+	if err != nil {
+		return err
+	}
+	f, ok := friends[name]
+	if !ok {
+		return fmt.Errorf("no friend named %q", name)
+	}
+	fn(&f)
+	friends[name] = f
+	return b.save(friends)
+}
+
+func (b *AddressBook) Remove(name string) error {
+	friends, err := b.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := friends[name]; !ok {
+		return fmt.Errorf("no friend named %q", name)
+	}
+	delete(friends, name)
+	return b.save(friends)
+}
+
+// record a successful connection to name
+func (b *AddressBook) Touch(name string, at time.Time) error {
+	return b.update(name, func(f *Friend) { f.LastSeen = at.Local().String() })
+}
+
+// drop the pinned certificate of name
+func (b *AddressBook) ForgetFingerprint(name string) error {
+	return b.update(name, func(f *Friend) { f.Fingerprint = "" })
+}
+
+// a Pinner keeping the fingerprint in the entry of name
+func (b *AddressBook) Pinner(name string) Pinner {
+	return &friendPinner{book: b, name: name}
+}
+
+type friendPinner struct {
+	book *AddressBook
+	name string
+}
+
+func (p *friendPinner) Pinned(addr string) (string, bool, error) {
+	f, ok, err := p.book.Get(p.name)
+	if err != nil || !ok || f.Fingerprint == "" {
+		return "", false, err
+	}
+	return f.Fingerprint, true, nil
+}
+
+func (p *friendPinner) Pin(addr, fingerprint string) error {
+	return p.book.update(p.name, func(f *Friend) { f.Fingerprint = fingerprint })
+}
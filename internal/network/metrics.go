@@ -0,0 +1,210 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+// upper bounds of the request latency histogram buckets, in seconds
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// request types worth a label of their own, anything else a client sends
+// is counted as "other" so clients cannot grow the metrics without bound
+var knownRequests = map[string]bool{
+	RequestHello: true, RequestList: true, RequestAdd: true, RequestDone: true,
+	RequestComment: true, RequestSync: true, RequestSubscribe: true,
+}
+
+// counters of a friend server, exported in the Prometheus text format
+type metrics struct {
+	mu      sync.Mutex
+	started time.Time
+	// by request type and result
+	requests map[[2]string]uint64
+	// by request type
+	latency map[string]*histogram
+	// connections accepted since the start, and open now
+	connections uint64
+	active      int
+	watchers    int
+}
+
+type histogram struct {
+	// count per bucket, not cumulative, the last one is +Inf
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		started:  time.Now(),
+		requests: map[[2]string]uint64{},
+		latency:  map[string]*histogram{},
+	}
+}
+
+// count a request answered with result after d
+func (m *metrics) observe(reqType, result string, d time.Duration) {
+	switch {
+	case reqType == "":
+		reqType = "invalid"
+	case !knownRequests[reqType]:
+		reqType = "other"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[[2]string{reqType, result}]++
+	h, ok := m.latency[reqType]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets)+1)}
+		m.latency[reqType] = h
+	}
+	secs := d.Seconds()
+	i := sort.SearchFloat64s(latencyBuckets, secs)
+	h.counts[i]++
+	h.sum += secs
+	h.count++
+}
+
+// change the open connection and watcher gauges
+func (m *metrics) connected(delta int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active += delta
+	if delta > 0 {
+		m.connections += uint64(delta)
+	}
+}
+
+func (m *metrics) watching(delta int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.watchers += delta
+}
+
+// write all metrics in the Prometheus text exposition format
+func (m *metrics) write(w io.Writer) error {
+	var b strings.Builder
+	m.writeCounters(&b)
+
+	// read at scrape time, so changes made outside the server count too
+	if tasks, err := storage.List(); err == nil {
+		open, done, overdue := 0, 0, 0
+		today := time.Now().Format(storage.DateLayout)
+		for _, t := range tasks {
+			switch {
+			case t.Done:
+				done++
+			default:
+				open++
+				if t.Due != "" && t.Due < today {
+					overdue++
+				}
+			}
+		}
+		header(&b, "gotodo_tasks", "gauge", "Tasks in the list, by status.")
+		fmt.Fprintf(&b, "gotodo_tasks{status=\"done\"} %d\n", done)
+		fmt.Fprintf(&b, "gotodo_tasks{status=\"open\"} %d\n", open)
+		header(&b, "gotodo_tasks_overdue", "gauge", "Open tasks past their due date.")
+		fmt.Fprintf(&b, "gotodo_tasks_overdue %d\n", overdue)
+	}
+	if items, err := storage.Inbox(); err == nil {
+		header(&b, "gotodo_inbox_items", "gauge", "Changes from friends waiting for review.")
+		fmt.Fprintf(&b, "gotodo_inbox_items %d\n", len(items))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// the counters of the server, the lock is not held while the tasks are
+// read so that requests do not wait for the disk
+func (m *metrics) writeCounters(b *strings.Builder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	header(b, "gotodo_friend_requests_total", "counter", "Requests answered, by request type and result.")
+	keys := make([][2]string, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, k := range keys {
+		fmt.Fprintf(b, "gotodo_friend_requests_total{type=%s,result=%s} %d\n", quote(k[0]), quote(k[1]), m.requests[k])
+	}
+
+	header(b, "gotodo_friend_request_duration_seconds", "histogram", "Time to answer a request, by request type.")
+	types := make([]string, 0, len(m.latency))
+	for t := range m.latency {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		h := m.latency[t]
+		var cumulative uint64
+		for i, le := range latencyBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(b, "gotodo_friend_request_duration_seconds_bucket{type=%s,le=\"%g\"} %d\n", quote(t), le, cumulative)
+		}
+		fmt.Fprintf(b, "gotodo_friend_request_duration_seconds_bucket{type=%s,le=\"+Inf\"} %d\n", quote(t), h.count)
+		fmt.Fprintf(b, "gotodo_friend_request_duration_seconds_sum{type=%s} %g\n", quote(t), h.sum)
+		fmt.Fprintf(b, "gotodo_friend_request_duration_seconds_count{type=%s} %d\n", quote(t), h.count)
+	}
+
+	header(b, "gotodo_friend_connections_total", "counter", "Connections accepted since the server started.")
+	fmt.Fprintf(b, "gotodo_friend_connections_total %d\n", m.connections)
+	header(b, "gotodo_friend_connections_active", "gauge", "Connections open now.")
+	fmt.Fprintf(b, "gotodo_friend_connections_active %d\n", m.active)
+	header(b, "gotodo_friend_watchers_active", "gauge", "Friends watching the list live now.")
+	fmt.Fprintf(b, "gotodo_friend_watchers_active %d\n", m.watchers)
+	header(b, "gotodo_friend_start_time_seconds", "gauge", "Start time of the server in seconds since the epoch.")
+	fmt.Fprintf(b, "gotodo_friend_start_time_seconds %d\n", m.started.Unix())
+}
+
+func header(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// a label value in double quotes with \, " and newlines escaped
+func quote(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `"`, `\"`)
+	v = strings.ReplaceAll(v, "\n", `\n`)
+	return `"` + v + `"`
+}
+
+// an HTTP handler for an admin listener: /healthz answers 200 while the
+// server is up and can read the tasks, /metrics serves Prometheus metrics
+func (srv *Server) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		status, code := map[string]string{"status": "ok"}, http.StatusOK
+		if srv.s.isClosing() {
+			status, code = map[string]string{"status": "shutting_down"}, http.StatusServiceUnavailable
+		} else if _, err := storage.List(); err != nil {
+			status, code = map[string]string{"status": "error", "error": "cannot read tasks"}, http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(status)
+	})
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		srv.s.metrics.write(w)
+	})
+	return mux
+}
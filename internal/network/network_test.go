@@ -0,0 +1,1038 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+func TestNormalizeAddr(t *testing.T) {
+	// Test valid addresses with and without ports
+	t.Run("Valid", func(t *testing.T) {
+		cases := []struct {
+			in     string
+			listen bool
+			want   string
+		}{
+			{"192.168.1.20", false, "192.168.1.20:8088"},
+			{"192.168.1.20:9000", false, "192.168.1.20:9000"},
+			{"example.com", false, "example.com:8088"},
+			{"::1", false, "[::1]:8088"},
+			{"[::1]", false, "[::1]:8088"},
+			{"[fe80::1%eth0]:9000", false, "[fe80::1%eth0]:9000"},
+			{":9000", true, ":9000"},
+			{"", true, ":8088"},
+		}
+		for _, c := range cases {
+			got, err := NormalizeAddr(c.in, DefaultPort, c.listen)
+			if err != nil {
+				t.Errorf("Unexpected error for %q: %v", c.in, err)
+				continue
+			}
+			if got != c.want {
+				t.Errorf("Expected %q to normalize to %q, got %q", c.in, c.want, got)
+			}
+		}
+	})
+
+	// Test invalid addresses are rejected
+	t.Run("Invalid", func(t *testing.T) {
+		for _, in := range []string{"", ":9000", "host:0", "host:99999", "host:abc", "1:2:3:zz", "bad host"} {
+			if got, err := NormalizeAddr(in, DefaultPort, false); err == nil {
+				t.Errorf("Expected error for %q, got %q", in, got)
+			}
+		}
+	})
+}
+
+// start a server connection handler on one end of a pipe and return the
+// other end
+func pipeServer(t *testing.T, cfg ServerConfig) net.Conn {
+	t.Helper()
+	if cfg.Tokens == nil {
+		cfg.Tokens = NewTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	}
+	serverConn, client := net.Pipe()
+	s := newServer(cfg)
+	go s.handleConnection(serverConn)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// point storage at a temporary file holding the given tasks
+func useTempStore(t *testing.T, contents ...string) {
+	t.Helper()
+	storage.SetPath(filepath.Join(t.TempDir(), "tasks.json"))
+	for _, c := range contents {
+		if _, err := storage.Add(c); err != nil {
+			t.Fatalf("Failed to add test task: %v", err)
+		}
+	}
+}
+
+func TestProtocol(t *testing.T) {
+	useTempStore(t, "First task", "Second task")
+
+	// Test several requests over one connection
+	t.Run("MultipleRequests", func(t *testing.T) {
+		client, err := newClient(pipeServer(t, ServerConfig{}), DialOptions{})
+		if err != nil {
+			t.Fatalf("Handshake failed: %v", err)
+		}
+		if client.ServerVersion != ProtocolVersion {
+			t.Errorf("Expected server version %d, got %d", ProtocolVersion, client.ServerVersion)
+		}
+		for i := 0; i < 2; i++ {
+			tasks, err := client.ListTasks()
+			if err != nil {
+				t.Fatalf("List request %d failed: %v", i, err)
+			}
+			if len(tasks) != 2 {
+				t.Errorf("Expected 2 tasks, got %d", len(tasks))
+			}
+		}
+	})
+
+	// Test typed errors are reported to the client
+	t.Run("UnknownRequest", func(t *testing.T) {
+		client, err := newClient(pipeServer(t, ServerConfig{}), DialOptions{})
+		if err != nil {
+			t.Fatalf("Handshake failed: %v", err)
+		}
+		_, err = client.roundTrip(Request{Type: "explode"})
+		var perr *Error
+		if !errors.As(err, &perr) || perr.Code != ErrUnknownRequest {
+			t.Errorf("Expected %s error, got %v", ErrUnknownRequest, err)
+		}
+	})
+
+	// Test the handshake rejects other protocol versions
+	t.Run("UnsupportedVersion", func(t *testing.T) {
+		c := &Client{conn: pipeServer(t, ServerConfig{})}
+		c.codec = newCodec(c.conn)
+		_, err := c.roundTrip(Request{Type: RequestHello, Version: ProtocolVersion + 1})
+		var perr *Error
+		if !errors.As(err, &perr) || perr.Code != ErrUnsupportedVersion {
+			t.Errorf("Expected %s error, got %v", ErrUnsupportedVersion, err)
+		}
+	})
+
+	// Test requests before the handshake are refused
+	t.Run("HandshakeRequired", func(t *testing.T) {
+		c := &Client{conn: pipeServer(t, ServerConfig{})}
+		c.codec = newCodec(c.conn)
+		_, err := c.roundTrip(Request{Type: RequestList})
+		var perr *Error
+		if !errors.As(err, &perr) || perr.Code != ErrHandshakeRequired {
+			t.Errorf("Expected %s error, got %v", ErrHandshakeRequired, err)
+		}
+	})
+}
+
+func TestAuthentication(t *testing.T) {
+	useTempStore(t, "Secret task")
+	tokens := NewTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	token, err := tokens.Create("alice")
+	if err != nil {
+		t.Fatalf("Failed to create token: %v", err)
+	}
+	cfg := ServerConfig{Tokens: tokens}
+
+	// Test a valid token is accepted
+	t.Run("ValidToken", func(t *testing.T) {
+		client, err := newClient(pipeServer(t, cfg), DialOptions{Token: token})
+		if err != nil {
+			t.Fatalf("Handshake failed: %v", err)
+		}
+		if tasks, err := client.ListTasks(); err != nil || len(tasks) != 1 {
+			t.Errorf("Expected 1 task, got %d (%v)", len(tasks), err)
+		}
+	})
+
+	// Test missing and wrong tokens are rejected
+	t.Run("Rejected", func(t *testing.T) {
+		for _, tok := range []string{"", "gtd_wrong"} {
+			_, err := newClient(pipeServer(t, cfg), DialOptions{Token: tok})
+			var perr *Error
+			if !errors.As(err, &perr) || perr.Code != ErrUnauthorized {
+				t.Errorf("Expected %s error for token %q, got %v", ErrUnauthorized, tok, err)
+			}
+		}
+	})
+
+	// Test revoked tokens stop working
+	t.Run("Revoked", func(t *testing.T) {
+		if err := tokens.Revoke("alice"); err != nil {
+			t.Fatalf("Failed to revoke token: %v", err)
+		}
+		if _, _, err := tokens.Verify(token); err != nil {
+			t.Fatalf("Failed to verify token: %v", err)
+		}
+		// a second token keeps authentication switched on
+		if _, err := tokens.Create("bob"); err != nil {
+			t.Fatalf("Failed to create token: %v", err)
+		}
+		if _, err := newClient(pipeServer(t, cfg), DialOptions{Token: token}); err == nil {
+			t.Error("Expected revoked token to be rejected")
+		}
+	})
+}
+
+func TestTLSPinning(t *testing.T) {
+	useTempStore(t, "Pinned task")
+	dir := t.TempDir()
+	cert, err := LoadOrCreateCertificate(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cfg := ServerConfig{Tokens: NewTokenStore(filepath.Join(dir, "tokens.json")), Certificate: cert}
+	ln, err := listen("127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv := serveListener(ln, cfg)
+	defer srv.Close()
+	addr := srv.Addr().String()
+
+	// Test the certificate is reloaded rather than regenerated
+	again, err := LoadOrCreateCertificate(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil || Fingerprint(again.Certificate[0]) != Fingerprint(cert.Certificate[0]) {
+		t.Fatalf("Expected the saved certificate to be reused (%v)", err)
+	}
+
+	known := NewKnownFriends(filepath.Join(dir, "known_friends"))
+
+	// Test the first connection pins the fingerprint
+	t.Run("TrustOnFirstUse", func(t *testing.T) {
+		client, err := Dial(addr, DialOptions{Pins: known})
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer client.Close()
+		if tasks, err := client.ListTasks(); err != nil || len(tasks) != 1 {
+			t.Errorf("Expected 1 task over TLS, got %d (%v)", len(tasks), err)
+		}
+		fp, ok, err := known.Pinned(addr)
+		if err != nil || !ok || fp != Fingerprint(cert.Certificate[0]) {
+			t.Errorf("Expected the server fingerprint to be pinned, got %q", fp)
+		}
+	})
+
+	// Test a changed fingerprint is refused
+	t.Run("Mismatch", func(t *testing.T) {
+		if err := known.Pin(addr, "SHA256:somethingelse"); err != nil {
+			t.Fatalf("Failed to pin: %v", err)
+		}
+		_, err := Dial(addr, DialOptions{Pins: known})
+		var mismatch *FingerprintMismatchError
+		if !errors.As(err, &mismatch) {
+			t.Fatalf("Expected fingerprint mismatch, got %v", err)
+		}
+		if mismatch.Got != Fingerprint(cert.Certificate[0]) {
+			t.Errorf("Expected presented fingerprint %s, got %s", Fingerprint(cert.Certificate[0]), mismatch.Got)
+		}
+	})
+}
+
+func TestAddressBook(t *testing.T) {
+	book := NewAddressBook(filepath.Join(t.TempDir(), "friends.json"))
+
+	// Test names that look like addresses are refused
+	if err := book.Put(Friend{Name: "192.168.1.20", Addr: "192.168.1.20:8088"}); err == nil {
+		t.Error("Expected error for an address used as a name")
+	}
+
+	if err := book.Put(Friend{Name: "alice", Addr: "192.168.1.20:8088", Token: "gtd_x"}); err != nil {
+		t.Fatalf("Failed to add friend: %v", err)
+	}
+
+	// Test entries keep last-seen time and the pinned fingerprint
+	if err := book.Touch("alice", time.Now()); err != nil {
+		t.Fatalf("Failed to touch friend: %v", err)
+	}
+	pins := book.Pinner("alice")
+	if err := pins.Pin("192.168.1.20:8088", "SHA256:abc"); err != nil {
+		t.Fatalf("Failed to pin: %v", err)
+	}
+	f, ok, err := book.Get("alice")
+	if err != nil || !ok {
+		t.Fatalf("Expected to find alice (%v)", err)
+	}
+	if f.LastSeen == "" || f.Fingerprint != "SHA256:abc" || f.Token != "gtd_x" {
+		t.Errorf("Unexpected entry: %+v", f)
+	}
+
+	// Test forgetting the fingerprint and removing the friend
+	if err := book.ForgetFingerprint("alice"); err != nil {
+		t.Fatalf("Failed to forget fingerprint: %v", err)
+	}
+	if _, ok, _ := pins.Pinned("192.168.1.20:8088"); ok {
+		t.Error("Expected no pinned fingerprint after forgetting")
+	}
+	if err := book.Remove("alice"); err != nil {
+		t.Fatalf("Failed to remove friend: %v", err)
+	}
+	if list, _ := book.List(); len(list) != 0 {
+		t.Errorf("Expected empty address book, got %v", list)
+	}
+}
+
+func TestSharing(t *testing.T) {
+	storage.SetPath(filepath.Join(t.TempDir(), "tasks.json"))
+	for _, nt := range []storage.Task{
+		{Content: "Ship release", Tags: []string{"team"}, Project: "web"},
+		{Content: "Dentist", Tags: []string{"private"}},
+		{Content: "Fix login", Tags: []string{"team", "private"}},
+		{Content: "Write docs", Project: "docs"},
+	} {
+		if _, err := storage.AddTask(nt); err != nil {
+			t.Fatalf("Failed to add test task: %v", err)
+		}
+	}
+	for _, id := range []int{1, 4} {
+		if _, err := storage.AddComment(id, storage.Comment{Text: "Secret plan"}); err != nil {
+			t.Fatalf("Failed to add test comment: %v", err)
+		}
+	}
+	dir := t.TempDir()
+	tokens := NewTokenStore(filepath.Join(dir, "tokens.json"))
+	aliceToken, _ := tokens.Create("alice")
+	bobToken, _ := tokens.Create("bob")
+	shares := NewShareStore(filepath.Join(dir, "shares.json"))
+	cfg := ServerConfig{Tokens: tokens, Shares: shares}
+
+	list := func(token string) []storage.Task {
+		t.Helper()
+		client, err := newClient(pipeServer(t, cfg), DialOptions{Token: token})
+		if err != nil {
+			t.Fatalf("Handshake failed: %v", err)
+		}
+		tasks, err := client.ListTasks()
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		return tasks
+	}
+
+	// Test everything is shared without rules
+	if tasks := list(aliceToken); len(tasks) != 4 {
+		t.Errorf("Expected 4 tasks without rules, got %d", len(tasks))
+	}
+
+	if err := shares.Set(DefaultShareRule, ShareRule{ExcludeTags: []string{"private"}, Hide: []string{"dates", "comments"}}); err != nil {
+		t.Fatalf("Failed to set default rule: %v", err)
+	}
+	if err := shares.Set("alice", ShareRule{Tags: []string{"team"}, Projects: []string{"docs"}, ExcludeTags: []string{"private"}, Redact: true}); err != nil {
+		t.Fatalf("Failed to set rule: %v", err)
+	}
+
+	// Test a friend's own rule applies
+	t.Run("FriendRule", func(t *testing.T) {
+		tasks := list(aliceToken)
+		if len(tasks) != 2 || tasks[0].ID != 1 || tasks[1].ID != 4 {
+			t.Fatalf("Expected tasks 1 and 4, got %+v", tasks)
+		}
+		if tasks[0].Content != redactedContent || tasks[0].Project != "web" {
+			t.Errorf("Expected redacted content with project kept, got %+v", tasks[0])
+		}
+		for _, task := range tasks {
+			if len(task.Comments) != 0 {
+				t.Errorf("Expected no comments on redacted task %d, got %+v", task.ID, task.Comments)
+			}
+		}
+	})
+
+	// Test friends without a rule get the default one
+	t.Run("DefaultRule", func(t *testing.T) {
+		tasks := list(bobToken)
+		if len(tasks) != 2 {
+			t.Fatalf("Expected 2 tasks, got %+v", tasks)
+		}
+		for _, task := range tasks {
+			if task.CreatedAt != "" || task.Content == redactedContent || len(task.Comments) != 0 {
+				t.Errorf("Expected dates and comments hidden and content kept, got %+v", task)
+			}
+		}
+	})
+
+	// Test invalid rules are refused
+	if err := shares.Set("bob", ShareRule{Hide: []string{"content"}}); err == nil {
+		t.Error("Expected error for an unknown field")
+	}
+}
+
+func TestRemoteChanges(t *testing.T) {
+	storage.SetPath(filepath.Join(t.TempDir(), "tasks.json"))
+	for _, nt := range []storage.Task{
+		{Content: "Team task", Tags: []string{"team"}},
+		{Content: "Private task", Tags: []string{"private"}},
+	} {
+		if _, err := storage.AddTask(nt); err != nil {
+			t.Fatalf("Failed to add test task: %v", err)
+		}
+	}
+	dir := t.TempDir()
+	tokens := NewTokenStore(filepath.Join(dir, "tokens.json"))
+	aliceToken, _ := tokens.Create("alice")
+	bobToken, _ := tokens.Create("bob")
+	shares := NewShareStore(filepath.Join(dir, "shares.json"))
+	shares.Set("alice", ShareRule{ExcludeTags: []string{"private"}, Allow: []string{RequestAdd, RequestDone, RequestComment}})
+	shares.Set("bob", ShareRule{Allow: []string{RequestAdd}, Review: true})
+	cfg := ServerConfig{Tokens: tokens, Shares: shares}
+
+	dial := func(token string) *Client {
+		t.Helper()
+		client, err := newClient(pipeServer(t, cfg), DialOptions{Token: token})
+		if err != nil {
+			t.Fatalf("Handshake failed: %v", err)
+		}
+		return client
+	}
+	expectCode := func(err error, code string) {
+		t.Helper()
+		var perr *Error
+		if !errors.As(err, &perr) || perr.Code != code {
+			t.Errorf("Expected %s error, got %v", code, err)
+		}
+	}
+
+	// Test allowed changes are applied at once
+	t.Run("Applied", func(t *testing.T) {
+		client := dial(aliceToken)
+		res, err := client.AddTask(storage.Task{Content: "Review PR", Tags: []string{"team"}, Due: "2026-11-06", Scheduled: "2026-11-02"})
+		if err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if res.Queued || res.Task == nil || res.Task.ID != 3 {
+			t.Fatalf("Expected task 3 to be added, got %+v", res)
+		}
+		if _, err := client.SetDone(1); err != nil {
+			t.Errorf("Done failed: %v", err)
+		}
+		if _, err := client.Comment(1, "Shipped"); err != nil {
+			t.Errorf("Comment failed: %v", err)
+		}
+		added, _ := storage.Get(3)
+		if len(added.Tags) != 2 || added.Tags[1] != storage.SenderTag("alice") {
+			t.Errorf("Expected sender tag, got %v", added.Tags)
+		}
+		if added.Due != "2026-11-06" || added.Scheduled != "2026-11-02" {
+			t.Errorf("Expected the due and scheduled dates sent, got %q and %q", added.Due, added.Scheduled)
+		}
+		_, err = client.AddTask(storage.Task{Content: "Someday", Tags: []string{"team"}, Scheduled: "someday"})
+		expectCode(err, ErrBadRequest)
+		task, _ := storage.Get(1)
+		if !task.Done || len(task.Comments) != 1 || task.Comments[0].Author != "alice" {
+			t.Errorf("Expected task 1 done with alice's comment, got %+v", task)
+		}
+	})
+
+	// Test hidden tasks look like missing ones
+	t.Run("HiddenTask", func(t *testing.T) {
+		_, err := dial(aliceToken).SetDone(2)
+		expectCode(err, ErrNotFound)
+	})
+
+	// Test changes outside the permissions are refused
+	t.Run("Forbidden", func(t *testing.T) {
+		_, err := dial(bobToken).SetDone(1)
+		expectCode(err, ErrForbidden)
+	})
+
+	// Test changes from friends under review wait in the inbox
+	t.Run("Review", func(t *testing.T) {
+		res, err := dial(bobToken).AddTask(storage.Task{Content: "Maybe later"})
+		if err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if !res.Queued {
+			t.Error("Expected the add to be queued")
+		}
+		items, err := storage.Inbox()
+		if err != nil || len(items) != 1 || items[0].From != "bob" {
+			t.Errorf("Expected bob's item in the inbox, got %+v (%v)", items, err)
+		}
+		if tasks, _ := storage.List(); len(tasks) != 3 {
+			t.Errorf("Expected 3 tasks before approval, got %d", len(tasks))
+		}
+	})
+}
+
+func TestSyncScope(t *testing.T) {
+	storage.SetPath(filepath.Join(t.TempDir(), "tasks.json"))
+	for _, nt := range []storage.Task{
+		{Content: "Ship release", Tags: []string{"team"}},
+		{Content: "Plan offsite", Tags: []string{"team"}},
+		{Content: "Dentist", Tags: []string{"private"}},
+	} {
+		if _, err := storage.AddTask(nt); err != nil {
+			t.Fatalf("Failed to add test task: %v", err)
+		}
+	}
+	dir := t.TempDir()
+	tokens := NewTokenStore(filepath.Join(dir, "tokens.json"))
+	token, _ := tokens.Create("alice")
+	shares := NewShareStore(filepath.Join(dir, "shares.json"))
+	shares.Set("alice", ShareRule{Tags: []string{"team"}, Allow: []string{RequestSync}})
+	client, err := newClient(pipeServer(t, ServerConfig{Tokens: tokens, Shares: shares}), DialOptions{Token: token})
+	if err != nil {
+		t.Fatalf("Handshake failed: %v", err)
+	}
+	theirs, err := client.Sync(SyncBatch{Now: storage.Stamp()})
+	if err != nil || len(theirs.Tasks) != 2 {
+		t.Fatalf("Expected the 2 team tasks, got %+v (%v)", theirs.Tasks, err)
+	}
+
+	// Test tasks outside the rule are dropped and comments get the friend as author
+	moved, commented := theirs.Tasks[0], theirs.Tasks[1]
+	moved.Tags = []string{"private"}
+	moved.Modified = map[string]string{storage.FieldTags: storage.Stamp()}
+	commented.Comments = []storage.Comment{{Text: "On it", CreatedAt: time.Now().Local().String()}}
+	batch := SyncBatch{Now: storage.Stamp(), Since: theirs.Now, Base: theirs.Now, Tasks: []storage.Task{
+		moved,
+		commented,
+		{UUID: "alice-own", Content: "Alice's own task"},
+		{UUID: "alice-team", Content: "Book venue", Tags: []string{"team"}},
+	}}
+	back, err := client.Sync(batch)
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	tasks, _ := storage.List()
+	if len(tasks) != 4 || tasks[3].Content != "Book venue" {
+		t.Fatalf("Expected only the new team task to be added, got %+v", tasks)
+	}
+	if tasks[0].Tags[0] != "team" {
+		t.Errorf("Expected task 1 to stay in team, got %v", tasks[0].Tags)
+	}
+	if len(tasks[1].Comments) != 1 || tasks[1].Comments[0].Author != "alice" {
+		t.Errorf("Expected alice's comment on task 2, got %+v", tasks[1].Comments)
+	}
+
+	// Test only removals of tasks the friend could have seen are sent
+	private := []string{"private"}
+	if _, err := storage.Update(1, storage.TaskPatch{Tags: &private}); err != nil {
+		t.Fatalf("Failed to move task 1: %v", err)
+	}
+	storage.Delete(2)
+	storage.Delete(3)
+	removed, err := client.Sync(SyncBatch{Now: storage.Stamp(), Since: back.Now, Base: back.Now})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	var uuids []string
+	for _, tb := range removed.Tombstones {
+		uuids = append(uuids, tb.UUID)
+	}
+	if len(uuids) != 2 || !slices.Contains(uuids, tasks[0].UUID) || !slices.Contains(uuids, tasks[1].UUID) {
+		t.Errorf("Expected removals of tasks 1 and 2 only, got %v", uuids)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	useTempStore(t, "Existing task")
+	dir := t.TempDir()
+	tokens := NewTokenStore(filepath.Join(dir, "tokens.json"))
+	token, _ := tokens.Create("alice")
+	shares := NewShareStore(filepath.Join(dir, "shares.json"))
+	shares.Set("alice", ShareRule{ExcludeTags: []string{"private"}})
+
+	client, err := newClient(pipeServer(t, ServerConfig{Tokens: tokens, Shares: shares}), DialOptions{Token: token})
+	if err != nil {
+		t.Fatalf("Handshake failed: %v", err)
+	}
+	tasks, err := client.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("Expected 1 task, got %d", len(tasks))
+	}
+
+	// Test changes are pushed, hidden ones are not
+	if _, err := storage.AddTask(storage.Task{Content: "Secret", Tags: []string{"private"}}); err != nil {
+		t.Fatalf("Failed to add task: %v", err)
+	}
+	if err := storage.SetDone(1, true); err != nil {
+		t.Fatalf("Failed to mark task done: %v", err)
+	}
+	events := make(chan storage.Event)
+	go func() {
+		for {
+			e, err := client.NextEvent()
+			if err != nil {
+				close(events)
+				return
+			}
+			events <- e
+		}
+	}()
+	select {
+	case e := <-events:
+		if e.Action != storage.ActionDone || e.Task.ID != 1 {
+			t.Errorf("Expected done event for task 1, got %s for task %d", e.Action, e.Task.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No event received")
+	}
+
+	// Test a task the friend never saw stays quiet, one they saw and that
+	// became hidden is deleted
+	if err := storage.SetDue(2, "2026-11-05"); err != nil {
+		t.Fatalf("Failed to set due date: %v", err)
+	}
+	private := []string{"private"}
+	if _, err := storage.Update(1, storage.TaskPatch{Tags: &private}); err != nil {
+		t.Fatalf("Failed to hide task 1: %v", err)
+	}
+	select {
+	case e := <-events:
+		if e.Action != storage.ActionDelete || e.Task.ID != 1 {
+			t.Errorf("Expected delete event for task 1, got %s for task %d", e.Action, e.Task.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No event received")
+	}
+}
+
+func TestServerLimits(t *testing.T) {
+	useTempStore(t, "Limited task")
+	dir := t.TempDir()
+	cert, err := LoadOrCreateCertificate(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	tokens := NewTokenStore(filepath.Join(dir, "tokens.json"))
+	start := func(t *testing.T, cfg ServerConfig) (*Server, string) {
+		t.Helper()
+		cfg.Tokens = tokens
+		cfg.Certificate = cert
+		ln, err := listen("127.0.0.1:0", cfg)
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		srv := serveListener(ln, cfg)
+		t.Cleanup(func() { srv.Close() })
+		return srv, srv.Addr().String()
+	}
+	dial := func(addr string) (*Client, error) {
+		return Dial(addr, DialOptions{Pins: NewKnownFriends(filepath.Join(t.TempDir(), "known"))})
+	}
+	code := func(err error) string {
+		var perr *Error
+		if errors.As(err, &perr) {
+			return perr.Code
+		}
+		return ""
+	}
+
+	// Test shutdown closes idle connections and stops accepting new ones
+	t.Run("Shutdown", func(t *testing.T) {
+		srv, addr := start(t, ServerConfig{})
+		client, err := dial(addr)
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer client.Close()
+		if _, err := client.ListTasks(); err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			t.Fatalf("Expected a clean shutdown, got %v", err)
+		}
+		if _, err := client.ListTasks(); err == nil {
+			t.Error("Expected the idle connection to be closed")
+		}
+		if c, err := dial(addr); err == nil {
+			c.Close()
+			t.Error("Expected new connections to be refused")
+		}
+	})
+
+	// Test connections idle for too long are dropped
+	t.Run("IdleTimeout", func(t *testing.T) {
+		_, addr := start(t, ServerConfig{IdleTimeout: 100 * time.Millisecond})
+		client, err := dial(addr)
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer client.Close()
+		time.Sleep(300 * time.Millisecond)
+		if _, err := client.ListTasks(); err == nil {
+			t.Error("Expected the idle connection to be closed")
+		}
+	})
+
+	// Test connections over the cap are turned away
+	t.Run("MaxConns", func(t *testing.T) {
+		_, addr := start(t, ServerConfig{MaxConns: 1})
+		first, err := dial(addr)
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer first.Close()
+		if _, err := dial(addr); code(err) != ErrBusy {
+			t.Errorf("Expected a busy error, got %v", err)
+		}
+		first.Close()
+		// the slot frees up once the server notices the close
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			c, err := dial(addr)
+			if err == nil {
+				c.Close()
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected a free slot after closing, got %v", err)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	})
+
+	// Test a dial timeout gives up on a server that never answers
+	t.Run("DialTimeout", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		defer ln.Close()
+		go func() {
+			// accept and stay silent
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+			}
+		}()
+		start := time.Now()
+		_, err = Dial(ln.Addr().String(), DialOptions{Timeout: 200 * time.Millisecond, Pins: NewKnownFriends(filepath.Join(t.TempDir(), "known"))})
+		if err == nil {
+			t.Fatal("Expected the dial to time out")
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Expected to give up after about 200ms, took %v", elapsed)
+		}
+	})
+
+	// Test requests over the rate limit are refused without closing
+	t.Run("RateLimit", func(t *testing.T) {
+		client, err := newClient(pipeServer(t, ServerConfig{RateLimit: 0.001, RateBurst: 2}), DialOptions{})
+		if err != nil {
+			t.Fatalf("Handshake failed: %v", err)
+		}
+		if _, err := client.ListTasks(); err != nil {
+			t.Fatalf("Expected the first request to pass, got %v", err)
+		}
+		if _, err := client.ListTasks(); code(err) != ErrRateLimited {
+			t.Errorf("Expected a rate_limited error, got %v", err)
+		}
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(1, 2)
+	a := "10.0.0.1:1000"
+	other := "10.0.0.1:2000"
+	now := time.Now()
+	if !l.Allow(a, now) || !l.Allow(other, now) {
+		t.Fatal("Expected the burst to be allowed")
+	}
+	// the port does not matter, the bucket is per IP
+	if l.Allow(a, now) || !l.Limited("10.0.0.1", now) {
+		t.Error("Expected the third request to be limited")
+	}
+	if !l.Allow("10.0.0.2", now) {
+		t.Error("Expected another address to have its own bucket")
+	}
+	if l.Limited(a, now.Add(time.Second)) || !l.Allow(a, now.Add(time.Second)) {
+		t.Error("Expected a token after one second")
+	}
+	if !NewRateLimiter(-1, 0).Allow(a, now) {
+		t.Error("Expected no limit with a negative rate")
+	}
+
+	// Test the bucket map stays bounded when every address is still limited
+	l = NewRateLimiter(0.001, 1)
+	for i := 0; i < maxBuckets+10; i++ {
+		l.Allow(fmt.Sprintf("10.1.%d.%d", i/256, i%256), now.Add(time.Duration(i)*time.Millisecond))
+	}
+	if len(l.buckets) > maxBuckets {
+		t.Errorf("Expected at most %d buckets, got %d", maxBuckets, len(l.buckets))
+	}
+	last := now.Add(time.Duration(maxBuckets+9) * time.Millisecond)
+	if _, ok := l.buckets["10.1.0.0"]; ok {
+		t.Error("Expected the least recently used bucket to be evicted")
+	}
+	if !l.Limited(fmt.Sprintf("10.1.%d.%d", (maxBuckets+9)/256, (maxBuckets+9)%256), last) {
+		t.Error("Expected the newest address to stay limited")
+	}
+}
+
+// a buffer the server can log to while the test reads it
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// the JSON log entries written so far
+func (b *logBuffer) entries() []map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var e map[string]any
+		if json.Unmarshal([]byte(line), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func TestAccessLog(t *testing.T) {
+	useTempStore(t, "First task", "Second task")
+	dir := t.TempDir()
+	tokens := NewTokenStore(filepath.Join(dir, "tokens.json"))
+	token, _ := tokens.Create("alice")
+	var events, access logBuffer
+	cfg := ServerConfig{
+		Tokens:    tokens,
+		Shares:    NewShareStore(filepath.Join(dir, "shares.json")),
+		Logger:    slog.New(slog.NewJSONHandler(&events, nil)),
+		AccessLog: slog.New(slog.NewJSONHandler(&access, nil)),
+	}
+	client, err := newClient(pipeServer(t, cfg), DialOptions{Token: token})
+	if err != nil {
+		t.Fatalf("Handshake failed: %v", err)
+	}
+	if _, err := client.ListTasks(); err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if _, err := client.AddTask(storage.Task{Content: "Not allowed"}); err == nil {
+		t.Fatal("Expected the add to be refused")
+	}
+
+	// the entry is written after the response, give it a moment
+	var entries []map[string]any
+	for i := 0; i < 100; i++ {
+		if entries = access.entries(); len(entries) == 3 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 access log entries, got %d", len(entries))
+	}
+	want := []struct {
+		typ, result string
+		tasks       float64
+	}{
+		{RequestHello, "ok", 0},
+		{RequestList, "ok", 2},
+		{RequestAdd, ErrForbidden, 0},
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e["type"] != w.typ || e["result"] != w.result || e["tasks"] != w.tasks {
+			t.Errorf("Expected %s/%s with %v tasks, got %v", w.typ, w.result, w.tasks, e)
+		}
+		if e["friend"] != "alice" && w.typ != RequestHello {
+			t.Errorf("Expected the friend in the entry, got %v", e["friend"])
+		}
+		if _, ok := e["remote"]; !ok {
+			t.Errorf("Expected the remote address in the entry, got %v", e)
+		}
+		if _, ok := e["duration"]; !ok {
+			t.Errorf("Expected a duration in the entry, got %v", e)
+		}
+	}
+	if len(events.entries()) != 0 {
+		t.Errorf("Expected no server events, got %v", events.entries())
+	}
+}
+
+func TestAdminHandler(t *testing.T) {
+	useTempStore(t, "Open task", "Done task")
+	if err := storage.SetDone(2, true); err != nil {
+		t.Fatalf("Failed to mark task done: %v", err)
+	}
+	dir := t.TempDir()
+	cert, err := LoadOrCreateCertificate(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cfg := ServerConfig{Tokens: NewTokenStore(filepath.Join(dir, "tokens.json")), Certificate: cert}
+	ln, err := listen("127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv := serveListener(ln, cfg)
+	defer srv.Close()
+	admin := srv.AdminHandler()
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	client, err := Dial(srv.Addr().String(), DialOptions{Pins: NewKnownFriends(filepath.Join(dir, "known"))})
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+	if _, err := client.ListTasks(); err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	// Test the metrics count the requests, connection and tasks
+	t.Run("Metrics", func(t *testing.T) {
+		rec := get("/metrics")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		body := rec.Body.String()
+		for _, want := range []string{
+			`gotodo_friend_requests_total{type="hello",result="ok"} 1`,
+			`gotodo_friend_requests_total{type="list",result="ok"} 1`,
+			`gotodo_friend_request_duration_seconds_count{type="list"} 1`,
+			`gotodo_friend_request_duration_seconds_bucket{type="list",le="+Inf"} 1`,
+			"gotodo_friend_connections_active 1",
+			`gotodo_tasks{status="open"} 1`,
+			`gotodo_tasks{status="done"} 1`,
+			"# TYPE gotodo_friend_request_duration_seconds histogram",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("Expected %q in the metrics, got:\n%s", want, body)
+			}
+		}
+	})
+
+	// Test the health check fails once the server is stopping
+	t.Run("Healthz", func(t *testing.T) {
+		if rec := get("/healthz"); rec.Code != http.StatusOK {
+			t.Errorf("Expected 200, got %d", rec.Code)
+		}
+		srv.Close()
+		if rec := get("/healthz"); rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected 503 after shutdown, got %d", rec.Code)
+		}
+	})
+}
+
+func TestMetricLabels(t *testing.T) {
+	m := newMetrics()
+	m.observe("made-up", ErrUnknownRequest, time.Millisecond)
+	m.observe("", ErrBadRequest, time.Millisecond)
+	var b strings.Builder
+	if err := m.write(&b); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+	if !strings.Contains(b.String(), `{type="other",result="unknown_request"} 1`) ||
+		!strings.Contains(b.String(), `{type="invalid",result="bad_request"} 1`) {
+		t.Errorf("Expected unknown types to be grouped, got:\n%s", b.String())
+	}
+	if got := quote("a\"b\\c\nd"); got != `"a\"b\\c\nd"` {
+		t.Errorf("Expected escaped label value, got %s", got)
+	}
+}
+
+func TestFriendCache(t *testing.T) {
+	cache := NewFriendCache(filepath.Join(t.TempDir(), "friends"))
+
+	// Test a missing entry is not an error
+	if _, ok, err := cache.Load("alice"); ok || err != nil {
+		t.Fatalf("Expected no cached list, got ok=%v err=%v", ok, err)
+	}
+
+	// Test names and addresses are kept apart
+	at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	alice := CachedList{Friend: "alice", Addr: "10.0.0.1:8088", FetchedAt: at, Tasks: []storage.Task{{ID: 1, Content: "Alice's task"}}}
+	if err := cache.Save("alice", alice); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	if err := cache.Save("10.0.0.2:8088", CachedList{Addr: "10.0.0.2:8088", FetchedAt: at}); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	got, ok, err := cache.Load("alice")
+	if err != nil || !ok {
+		t.Fatalf("Expected the cached list, got ok=%v err=%v", ok, err)
+	}
+	if !got.FetchedAt.Equal(at) || len(got.Tasks) != 1 || got.Tasks[0].Content != "Alice's task" {
+		t.Errorf("Expected alice's list back, got %+v", got)
+	}
+	if got, ok, _ := cache.Load("10.0.0.2:8088"); !ok || got.Addr != "10.0.0.2:8088" {
+		t.Errorf("Expected the list cached by address, got %+v", got)
+	}
+
+	if err := cache.Remove("alice"); err != nil {
+		t.Fatalf("Failed to remove: %v", err)
+	}
+	if _, ok, _ := cache.Load("alice"); ok {
+		t.Error("Expected the list to be removed")
+	}
+	if err := cache.Remove("alice"); err != nil {
+		t.Errorf("Expected removing twice to be fine, got %v", err)
+	}
+}
+
+func TestDiffTasks(t *testing.T) {
+	before := []storage.Task{
+		{ID: 1, UUID: "a", Content: "Stays"},
+		{ID: 2, UUID: "b", Content: "Gets done"},
+		{ID: 3, UUID: "c", Content: "Goes away"},
+		{ID: 4, UUID: "d", Content: "Reopened", Done: true},
+		{ID: 5, UUID: "e", Content: "Old text"},
+	}
+	after := []storage.Task{
+		{ID: 1, UUID: "a", Content: "Stays"},
+		{ID: 2, UUID: "b", Content: "Gets done", Done: true},
+		{ID: 4, UUID: "d", Content: "Reopened"},
+		{ID: 5, UUID: "e", Content: "New text"},
+		{ID: 6, UUID: "f", Content: "Brand new"},
+	}
+	d := DiffTasks(before, after)
+	check := func(name string, got []storage.Task, want ...int) {
+		t.Helper()
+		var ids []int
+		for _, t := range got {
+			ids = append(ids, t.ID)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(want) {
+			t.Errorf("Expected %s %v, got %v", name, want, ids)
+		}
+	}
+	check("added", d.Added, 6)
+	check("completed", d.Completed, 2)
+	check("reopened", d.Reopened, 4)
+	check("changed", d.Changed, 5)
+	check("removed", d.Removed, 3)
+
+	// Test lists without UUIDs are matched by ID
+	if d := DiffTasks([]storage.Task{{ID: 1, Content: "x"}}, []storage.Task{{ID: 1, Content: "x"}}); !d.Empty() {
+		t.Errorf("Expected no changes, got %+v", d)
+	}
+}
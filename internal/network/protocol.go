@@ -0,0 +1,156 @@
+package network
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+// The friend protocol is newline-delimited JSON. A connection starts with
+// a "hello" exchange that agrees on the protocol version, after which the
+// client may send any number of requests, each answered by one response.
+// Failures are reported as a response carrying an Error. After a subscribe
+// request the server only streams "event" and "ping" messages until the
+// connection is closed.
+
+// version of the protocol spoken by this build
+const ProtocolVersion = 1
+
+// longest message accepted on the wire
+const maxMessageSize = 4 << 20
+
+// request types
+const (
+	RequestHello   = "hello"
+	RequestList    = "list"
+	RequestAdd     = "add"
+	RequestDone    = "done"
+	RequestComment = "comment"
+	RequestSync    = "sync"
+	// turns the connection into a stream of "event" responses
+	RequestSubscribe = "subscribe"
+)
+
+// types of messages streamed after a subscribe
+const (
+	ResponseEvent = "event"
+	ResponsePing  = "ping"
+)
+
+// error codes carried in Error.Code
+const (
+	ErrBadRequest         = "bad_request"
+	ErrUnsupportedVersion = "unsupported_version"
+	ErrHandshakeRequired  = "handshake_required"
+	ErrUnknownRequest     = "unknown_request"
+	ErrUnauthorized       = "unauthorized"
+	ErrForbidden          = "forbidden"
+	ErrNotFound           = "not_found"
+	ErrInternal           = "internal"
+	// too many connections, try again later
+	ErrBusy = "busy"
+	// too many requests from one address
+	ErrRateLimited = "rate_limited"
+)
+
+type Request struct {
+	Type    string `json:"type"`
+	Version int    `json:"version,omitempty"`
+	// pre-shared friend token, sent with hello
+	Token string `json:"token,omitempty"`
+	// the task to add, for add
+	Task *storage.Task `json:"task,omitempty"`
+	// the task to change and the comment text, for done and comment
+	ID   int    `json:"id,omitempty"`
+	Text string `json:"text,omitempty"`
+	// the client's changes, for sync
+	Sync *SyncBatch `json:"sync,omitempty"`
+}
+
+type Response struct {
+	Type    string         `json:"type"`
+	Version int            `json:"version,omitempty"`
+	Tasks   []storage.Task `json:"tasks,omitempty"`
+	// the task after an add, done or comment was applied
+	Task *storage.Task `json:"task,omitempty"`
+	// the change waits for the owner's approval
+	Queued bool `json:"queued,omitempty"`
+	// the server's changes, for sync
+	Sync *SyncBatch `json:"sync,omitempty"`
+	// a change of the task list, for event
+	Event *storage.Event `json:"event,omitempty"`
+	Error *Error         `json:"error,omitempty"`
+}
+
+// changes exchanged by a sync. Times are storage.Stamp values by the
+// clock of the side they belong to.
+type SyncBatch struct {
+	// when the sender took the batch, by its clock
+	Now string `json:"now"`
+	// the previous sync by the receiver's and the sender's clock, empty
+	// the first time. Only set in requests.
+	Since string `json:"since,omitempty"`
+	Base  string `json:"base,omitempty"`
+
+	Tasks      []storage.Task      `json:"tasks,omitempty"`
+	Tombstones []storage.Tombstone `json:"tombstones,omitempty"`
+}
+
+// an error reported by the other side
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("friend server error (%s): %s", e.Code, e.Message)
+}
+
+// returned by codec.read for lines that aren't valid JSON messages
+var errMalformed = errors.New("malformed message")
+
+// reads and writes protocol messages on a connection
+type codec struct {
+	scanner *bufio.Scanner
+	enc     *json.Encoder
+}
+
+func newCodec(rw io.ReadWriter) *codec {
+	scanner := bufio.NewScanner(rw)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	return &codec{scanner: scanner, enc: json.NewEncoder(rw)}
+}
+
+// read the next raw message line, io.EOF when the peer is done
+func (c *codec) readLine() ([]byte, error) {
+	if !c.scanner.Scan() {
+		if err := c.scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	return c.scanner.Bytes(), nil
+}
+
+func (c *codec) read(v any) error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(line, v); err != nil {
+		return fmt.Errorf("%w: %v", errMalformed, err)
+	}
+	return nil
+}
+
+func (c *codec) write(v any) error {
+	return c.enc.Encode(v)
+}
+
+func errorResponse(reqType, code, format string, args ...any) Response {
+	return Response{Type: reqType, Error: &Error{Code: code, Message: fmt.Sprintf(format, args...)}}
+}
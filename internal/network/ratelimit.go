@@ -0,0 +1,93 @@
+package network
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// a token bucket per IP address, safe for concurrent use
+type RateLimiter struct {
+	mu sync.Mutex
+	// tokens added per second, the limit is off when 0
+	rate  float64
+	burst float64
+	// buckets of recently seen addresses
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// most buckets kept, past this full ones are forgotten first and then the
+// least recently used
+const maxBuckets = 4096
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{rate: rate, burst: float64(burst), buckets: map[string]*bucket{}}
+}
+
+// take a token for the address, an IP with or without a port; false when
+// it has none left
+func (l *RateLimiter) Allow(addr string, now time.Time) bool {
+	return l.take(addr, now, 1)
+}
+
+// report whether the address has no token left, without taking one
+func (l *RateLimiter) Limited(addr string, now time.Time) bool {
+	return !l.take(addr, now, 0)
+}
+
+func (l *RateLimiter) take(addr string, now time.Time, n float64) bool {
+	if l.rate <= 0 {
+		return true
+	}
+	key := addr
+	if host, _, err := net.SplitHostPort(key); err == nil {
+		key = host
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxBuckets {
+			l.prune(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+// drop buckets that have filled up again, they hold nothing a new one
+// wouldn't; if that frees nothing, drop the one used longest ago so the map
+// stays bounded
+func (l *RateLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	if len(l.buckets) < maxBuckets {
+		return
+	}
+	oldest := ""
+	for key, b := range l.buckets {
+		if oldest == "" || b.last.Before(l.buckets[oldest].last) {
+			oldest = key
+		}
+	}
+	delete(l.buckets, oldest)
+}
@@ -1,76 +1,763 @@
 package network
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ethanbao27/gotodo/internal/storage"
-	"github.com/fatih/color"
 )
 
-func StartServer(addr string) error {
-	ln, err := net.Listen("tcp", addr)
+// settings of a friend server
+type ServerConfig struct {
+	// friends must present one of these tokens, unless none are issued
+	Tokens *TokenStore
+	// certificate presented to friends, see LoadOrCreateCertificate
+	Certificate tls.Certificate
+	// what each friend may see, everything is shared when nil
+	Shares *ShareStore
+
+	// limits, the defaults below are used for zero values
+	// most connections served at once, more are turned away
+	MaxConns int
+	// how long a connection may sit idle between requests
+	IdleTimeout time.Duration
+	// how long writing one response may take
+	WriteTimeout time.Duration
+	// connections and requests per second allowed from one IP address,
+	// in bursts of up to RateBurst; negative turns the limit off
+	RateLimit float64
+	RateBurst int
+
+	// server events, slog.Default() when nil
+	Logger *slog.Logger
+	// one entry per request, Logger when nil
+	AccessLog *slog.Logger
+}
+
+// defaults of the ServerConfig limits
+const (
+	DefaultMaxConns     = 64
+	DefaultIdleTimeout  = 2 * time.Minute
+	DefaultWriteTimeout = 10 * time.Second
+	DefaultRateLimit    = 5
+	DefaultRateBurst    = 20
+)
+
+// the hello must arrive this soon after connecting
+const handshakeTimeout = 10 * time.Second
+
+func (cfg ServerConfig) withDefaults() ServerConfig {
+	if cfg.MaxConns <= 0 {
+		cfg.MaxConns = DefaultMaxConns
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = DefaultIdleTimeout
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = DefaultWriteTimeout
+	}
+	if cfg.RateLimit == 0 {
+		cfg.RateLimit = DefaultRateLimit
+	}
+	if cfg.RateBurst <= 0 {
+		cfg.RateBurst = DefaultRateBurst
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	if cfg.AccessLog == nil {
+		cfg.AccessLog = cfg.Logger
+	}
+	return cfg
+}
+
+type server struct {
+	cfg     ServerConfig
+	limiter *RateLimiter
+
+	// open connections, guarded by mu
+	mu      sync.Mutex
+	conns   map[net.Conn]struct{}
+	closing bool
+	active  sync.WaitGroup
+
+	metrics *metrics
+}
+
+func newServer(cfg ServerConfig) *server {
+	cfg = cfg.withDefaults()
+	return &server{
+		cfg:     cfg,
+		limiter: NewRateLimiter(cfg.RateLimit, cfg.RateBurst),
+		conns:   map[net.Conn]struct{}{},
+		metrics: newMetrics(),
+	}
+}
+
+// a running friend server
+type Server struct {
+	s  *server
+	ln net.Listener
+	// closed when the accept loop has ended
+	done chan struct{}
+}
+
+// listen on addr and serve friends until Shutdown
+func StartServer(addr string, cfg ServerConfig) (*Server, error) {
+	enabled, err := cfg.Tokens.Enabled()
 	if err != nil {
-		return fmt.Errorf("listen error: %w", err)
+		return nil, err
 	}
+	ln, err := listen(addr, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := serveListener(ln, cfg)
+	log := srv.s.cfg.Logger
+	log.Info("friend server started", "addr", ln.Addr().String(), "fingerprint", Fingerprint(cfg.Certificate.Certificate[0]))
+	if !enabled {
+		log.Warn("no friend tokens issued, anyone who can reach this address can read your tasks",
+			"hint", "gotodo friend token create <name>")
+	}
+	return srv, nil
+}
+
+// serve friends on an open listener
+func serveListener(ln net.Listener, cfg ServerConfig) *Server {
+	srv := &Server{s: newServer(cfg), ln: ln, done: make(chan struct{})}
+	go func() {
+		defer close(srv.done)
+		srv.s.serve(ln)
+	}()
+	return srv
+}
+
+// address the server listens on
+func (srv *Server) Addr() net.Addr {
+	return srv.ln.Addr()
+}
+
+// stop accepting connections, let requests in progress finish and close
+// idle connections. When ctx ends first the remaining connections are
+// closed and ctx's error is returned.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	srv.ln.Close()
+	<-srv.done
+
+	s := srv.s
+	s.mu.Lock()
+	s.closing = true
+	// connections waiting for a request give up at once, the others
+	// after sending their response
+	for conn := range s.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
 
-	color.New(color.FgBlue, color.Bold).Printf("Friend server started on %s\n", addr)
+	finished := make(chan struct{})
+	go func() {
+		s.active.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+// stop at once, closing all connections
+func (srv *Server) Close() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := srv.Shutdown(ctx); err != context.Canceled {
+		return err
+	}
+	return nil
+}
 
+// open the TLS listener
+func listen(addr string, cfg ServerConfig) (net.Listener, error) {
+	if len(cfg.Certificate.Certificate) == 0 {
+		return nil, fmt.Errorf("friend server needs a certificate")
+	}
+	ln, err := tls.Listen("tcp", addr, &tls.Config{
+		Certificates: []tls.Certificate{cfg.Certificate},
+		MinVersion:   tls.VersionTLS13,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listen error: %w", err)
+	}
+	return ln, nil
+}
+
+// accept connections until the listener is closed
+func (s *server) serve(ln net.Listener) error {
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
-			color.New(color.FgRed).Println("accept error:", err)
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			s.cfg.Logger.Error("accept failed", "err", err)
+			// e.g. out of file descriptors, give it a moment
+			time.Sleep(50 * time.Millisecond)
 			continue
 		}
-		go handleConnection(conn)
+		go s.handleConnection(conn)
 	}
 }
 
-func handleConnection(conn net.Conn) {
+// register a new connection, false when the server is shutting down or full
+func (s *server) track(conn net.Conn) (ok bool, busy bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return false, false
+	}
+	if len(s.conns) >= s.cfg.MaxConns {
+		return false, true
+	}
+	s.conns[conn] = struct{}{}
+	s.active.Add(1)
+	s.metrics.connected(1)
+	return true, false
+}
+
+func (s *server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+	s.active.Done()
+	s.metrics.connected(-1)
+}
+
+// whether Shutdown has been called
+func (s *server) isClosing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closing
+}
+
+// set the deadline for the next request, false when shutting down.
+// A zero timeout waits forever.
+func (s *server) awaitRequest(conn net.Conn, timeout time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return false
+	}
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	conn.SetReadDeadline(deadline)
+	return true
+}
+
+func (s *server) handleConnection(conn net.Conn) {
 	defer conn.Close()
+	c := newCodec(conn)
+	// covers the TLS handshake, which can happen on the first read or write
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	sess := &session{conn: conn}
+	start := time.Now()
+
+	ok, busy := s.track(conn)
+	if !ok {
+		if busy {
+			s.cfg.Logger.Warn("connection turned away", append(sess.attrs(), "max_conns", s.cfg.MaxConns)...)
+			s.reply(c, sess, start, errorResponse(RequestHello, ErrBusy, "server is busy, try again later"))
+		}
+		return
+	}
+	defer s.untrack(conn)
+	if !s.limiter.Allow(conn.RemoteAddr().String(), start) {
+		s.reply(c, sess, start, errorResponse(RequestHello, ErrRateLimited, "too many connections, slow down"))
+		return
+	}
 
-	buf := make([]byte, 1024)
-	n, err := conn.Read(buf)
+	var hello Request
+	if !s.awaitRequest(conn, handshakeTimeout) {
+		return
+	}
+	if err := c.read(&hello); err != nil {
+		if errors.Is(err, errMalformed) {
+			s.reply(c, sess, start, errorResponse("", ErrBadRequest, "%v", err))
+		}
+		if err != io.EOF {
+			s.cfg.Logger.Warn("bad handshake", append(sess.attrs(), "err", err)...)
+		}
+		return
+	}
+	if hello.Type != RequestHello {
+		s.reply(c, sess, start, errorResponse(hello.Type, ErrHandshakeRequired, "expected %q as the first request", RequestHello))
+		return
+	}
+	if hello.Version != ProtocolVersion {
+		resp := errorResponse(RequestHello, ErrUnsupportedVersion, "protocol version %d is not supported", hello.Version)
+		resp.Version = ProtocolVersion
+		s.reply(c, sess, start, resp)
+		return
+	}
+	friend, err := s.authenticate(hello.Token)
 	if err != nil {
-		color.New(color.FgRed).Println("read error:", err)
+		s.cfg.Logger.Warn("connection rejected", append(sess.attrs(), "reason", err.Error())...)
+		s.reply(c, sess, start, errorResponse(RequestHello, ErrUnauthorized, "%v", err))
+		return
+	}
+	sess.friend = friend
+	if !s.reply(c, sess, start, Response{Type: RequestHello, Version: ProtocolVersion}) {
 		return
 	}
 
-	req := strings.TrimSpace(string(buf[:n]))
-	if req != "GET_TODOS" {
-		if _, err := conn.Write([]byte("invalid request")); err != nil {
-			fmt.Println("write error:", err)
+	for {
+		if !s.awaitRequest(conn, s.cfg.IdleTimeout) {
+			return
+		}
+		var req Request
+		err := c.read(&req)
+		start := time.Now()
+		if err != nil {
+			if errors.Is(err, errMalformed) {
+				s.reply(c, sess, start, errorResponse("", ErrBadRequest, "%v", err))
+			}
+			return
+		}
+		if !s.limiter.Allow(conn.RemoteAddr().String(), start) {
+			if !s.reply(c, sess, start, errorResponse(req.Type, ErrRateLimited, "too many requests, slow down")) {
+				return
+			}
+			continue
+		}
+		if req.Type == RequestSubscribe {
+			s.subscribe(c, sess, start)
+			return
+		}
+		if !s.reply(c, sess, start, s.handleRequest(sess, req)) {
 			return
 		}
-		color.New(color.FgYellow).Printf("Invalid request from %s\n", conn.RemoteAddr())
-		return
 	}
+}
 
-	tasks, err := storage.List()
+// check a hello token and return the friend it belongs to, "" for
+// anonymous clients of a server without tokens
+func (s *server) authenticate(token string) (string, error) {
+	enabled, err := s.cfg.Tokens.Enabled()
 	if err != nil {
-		if _, err := conn.Write([]byte("failed to load tasks")); err != nil {
-			fmt.Println("write error:", err)
-			return
+		s.cfg.Logger.Error("token store failed", "err", err)
+		return "", fmt.Errorf("server cannot check tokens")
+	}
+	if !enabled {
+		return "", nil
+	}
+	if token == "" {
+		return "", fmt.Errorf("a token is required")
+	}
+	name, ok, err := s.cfg.Tokens.Verify(token)
+	if err != nil {
+		s.cfg.Logger.Error("token store failed", "err", err)
+		return "", fmt.Errorf("server cannot check tokens")
+	}
+	if !ok {
+		return "", fmt.Errorf("invalid or revoked token")
+	}
+	return name, nil
+}
+
+// an authenticated connection
+type session struct {
+	conn   net.Conn
+	friend string // empty for anonymous clients
+}
+
+// the peer as log attributes
+func (sess *session) attrs() []any {
+	attrs := []any{"remote", sess.conn.RemoteAddr().String()}
+	if sess.friend != "" {
+		attrs = append(attrs, "friend", sess.friend)
+	}
+	return attrs
+}
+
+// answer one request after the handshake
+func (s *server) handleRequest(sess *session, req Request) Response {
+	switch req.Type {
+	case RequestList:
+		tasks, err := storage.List()
+		if err != nil {
+			s.cfg.Logger.Error("failed to load tasks", "err", err)
+			return errorResponse(req.Type, ErrInternal, "failed to load tasks")
 		}
-		color.New(color.FgRed).Println("Failed to load local tasks")
-		return
+		rule, err := s.rule(sess)
+		if err != nil {
+			// never fall back to sharing everything
+			s.cfg.Logger.Error("failed to load sharing rules", "err", err)
+			return errorResponse(req.Type, ErrInternal, "failed to load sharing rules")
+		}
+		shared := rule.Filter(tasks)
+		s.cfg.Logger.Debug("shared tasks", append(sess.attrs(), "shared", len(shared), "total", len(tasks))...)
+		return Response{Type: req.Type, Tasks: shared}
+	case RequestAdd, RequestDone, RequestComment:
+		return s.handleChange(sess, req)
+	case RequestSync:
+		return s.handleSync(sess, req)
+	case RequestHello:
+		return errorResponse(req.Type, ErrBadRequest, "handshake already done")
+	default:
+		return errorResponse(req.Type, ErrUnknownRequest, "unknown request type %q", req.Type)
 	}
+}
+
+// the sharing rule of the session's friend, everything is shared and
+// nothing may be changed without a ShareStore
+func (s *server) rule(sess *session) (ShareRule, error) {
+	if s.cfg.Shares == nil {
+		return ShareRule{}, nil
+	}
+	return s.cfg.Shares.RuleFor(sess.friend)
+}
 
-	data, err := json.MarshalIndent(tasks, "", "  ")
+// apply or queue an add, done or comment request
+func (s *server) handleChange(sess *session, req Request) Response {
+	// changes are attributed to the sender, so they need a token
+	if sess.friend == "" {
+		return errorResponse(req.Type, ErrForbidden, "changes need a friend token")
+	}
+	rule, err := s.rule(sess)
 	if err != nil {
-		if _, err := conn.Write([]byte("json error")); err != nil {
-			fmt.Println("write error:", err)
-			return
+		s.cfg.Logger.Error("failed to load sharing rules", "err", err)
+		return errorResponse(req.Type, ErrInternal, "failed to load sharing rules")
+	}
+	if !rule.Can(req.Type) {
+		return errorResponse(req.Type, ErrForbidden, "you may not %s tasks on this list", req.Type)
+	}
+
+	item := storage.InboxItem{From: sess.friend, Action: req.Type}
+	switch req.Type {
+	case RequestAdd:
+		if req.Task == nil || strings.TrimSpace(req.Task.Content) == "" {
+			return errorResponse(req.Type, ErrBadRequest, "task content is empty")
+		}
+		nt := storage.Task{
+			Content:   strings.TrimSpace(req.Task.Content),
+			Tags:      req.Task.Tags,
+			Priority:  req.Task.Priority,
+			Project:   req.Task.Project,
+			Due:       req.Task.Due,
+			Scheduled: req.Task.Scheduled,
+		}
+		// checked now, a task waiting in the inbox is only added later
+		if err := storage.ValidateTask(nt); err != nil {
+			return errorResponse(req.Type, ErrBadRequest, "%v", err)
+		}
+		item.Task = &nt
+	case RequestComment:
+		if strings.TrimSpace(req.Text) == "" {
+			return errorResponse(req.Type, ErrBadRequest, "comment is empty")
+		}
+		item.Text = req.Text
+		fallthrough
+	case RequestDone:
+		// friends can only change tasks they can see
+		t, err := storage.Get(req.ID)
+		if err != nil || !rule.Allows(t) {
+			return errorResponse(req.Type, ErrNotFound, "task %d not found", req.ID)
+		}
+		item.TaskID = req.ID
+	}
+
+	if rule.Review {
+		if _, err := storage.QueueInbox(item); err != nil {
+			s.cfg.Logger.Error("failed to queue change", append(sess.attrs(), "type", req.Type, "err", err)...)
+			return errorResponse(req.Type, ErrInternal, "failed to queue the change")
+		}
+		s.cfg.Logger.Info("change queued for review", append(sess.attrs(), "type", req.Type)...)
+		return Response{Type: req.Type, Queued: true}
+	}
+	t, err := item.Apply()
+	if err != nil {
+		s.cfg.Logger.Error("failed to apply change", append(sess.attrs(), "type", req.Type, "err", err)...)
+		return errorResponse(req.Type, ErrInternal, "failed to apply the change")
+	}
+	s.cfg.Logger.Info("change applied", append(sess.attrs(), "type", req.Type, "task", t.ID)...)
+	shared := rule.apply(t)
+	return Response{Type: req.Type, Task: &shared}
+}
+
+// merge a friend's changes and answer with ours
+func (s *server) handleSync(sess *session, req Request) Response {
+	if sess.friend == "" {
+		return errorResponse(req.Type, ErrForbidden, "sync needs a friend token")
+	}
+	rule, err := s.rule(sess)
+	if err != nil {
+		s.cfg.Logger.Error("failed to load sharing rules", "err", err)
+		return errorResponse(req.Type, ErrInternal, "failed to load sharing rules")
+	}
+	if !rule.Can(RequestSync) {
+		return errorResponse(req.Type, ErrForbidden, "you may not sync with this list")
+	}
+	// a redacted copy would overwrite the real tasks on the next sync
+	if rule.Redact || len(rule.Hide) > 0 {
+		return errorResponse(req.Type, ErrForbidden, "sync needs unredacted access to the shared tasks")
+	}
+	if req.Sync == nil {
+		return errorResponse(req.Type, ErrBadRequest, "sync request without changes")
+	}
+
+	now := storage.Stamp()
+	tasks, tombs, err := storage.Changes(req.Sync.Since)
+	if err != nil {
+		s.cfg.Logger.Error("failed to load changes", "err", err)
+		return errorResponse(req.Type, ErrInternal, "failed to load changes")
+	}
+	// what the friend could have seen: tasks as of its last sync, and as
+	// they were when deleted
+	events, err := storage.History()
+	if err != nil {
+		s.cfg.Logger.Error("failed to read the change log", "err", err)
+		return errorResponse(req.Type, ErrInternal, "failed to read the change log")
+	}
+	before, last := versionsAt(events, req.Sync.Since), versionsAt(events, now)
+	// friends cannot touch tasks they don't get to see
+	hidden := map[string]bool{}
+	current, err := storage.List()
+	if err != nil {
+		s.cfg.Logger.Error("failed to load tasks", "err", err)
+		return errorResponse(req.Type, ErrInternal, "failed to load tasks")
+	}
+	local := map[string]storage.Task{}
+	for _, t := range current {
+		local[t.UUID] = t
+		if !rule.Allows(t) {
+			hidden[t.UUID] = true
+		}
+	}
+	// nor add tasks outside what they see, or move shared ones out of it
+	var incoming []storage.Task
+	dropped := 0
+	for _, t := range req.Sync.Tasks {
+		mine, ok := local[t.UUID]
+		if hidden[t.UUID] || !rule.Allows(t) ||
+			ok && !rule.Allows(storage.Merged(mine, t, req.Sync.Since, req.Sync.Base)) {
+			dropped++
+			continue
+		}
+		incoming = append(incoming, stampComments(t, mine, sess.friend))
+	}
+	var buried []storage.Tombstone
+	for _, tb := range req.Sync.Tombstones {
+		if !hidden[tb.UUID] {
+			buried = append(buried, tb)
+		}
+	}
+	report, err := storage.Merge(incoming, buried, req.Sync.Since, req.Sync.Base)
+	if err != nil {
+		s.cfg.Logger.Error("failed to merge changes", append(sess.attrs(), "err", err)...)
+		return errorResponse(req.Type, ErrInternal, "failed to merge changes")
+	}
+	shared := []storage.Task{}
+	removed := []storage.Tombstone{}
+	for _, t := range tasks {
+		if rule.Allows(t) {
+			shared = append(shared, t)
+		} else if prev, ok := before[t.UUID]; ok && rule.Allows(prev) {
+			// moved out of what the friend sees since the last sync, so it
+			// goes from their copy as if deleted
+			removed = append(removed, storage.Tombstone{UUID: t.UUID, DeletedAt: t.Updated, Updated: t.Updated})
+		}
+	}
+	for _, tb := range tombs {
+		// a task missing from the change log only passes rules without
+		// tags or projects
+		if rule.Allows(last[tb.UUID]) {
+			removed = append(removed, tb)
+		}
+	}
+	s.cfg.Logger.Info("synced", append(sess.attrs(), "added", report.Added, "updated", report.Updated,
+		"deleted", report.Deleted, "conflicts", len(report.Conflicts), "dropped", dropped, "sent", len(shared),
+		"removed", len(removed))...)
+	return Response{Type: req.Type, Sync: &SyncBatch{Now: now, Tasks: shared, Tombstones: removed}}
+}
+
+// the last version of each task the change log has from no later than the
+// sync Stamp at, deleted tasks included
+func versionsAt(events []storage.Event, at string) map[string]storage.Task {
+	versions := map[string]storage.Task{}
+	for _, e := range events {
+		if e.Task.UUID != "" && e.Task.Updated <= at {
+			versions[e.Task.UUID] = e.Task
+		}
+	}
+	return versions
+}
+
+// the task with the friend as author of its new comments that have none,
+// so they don't show up as the owner's
+func stampComments(t, local storage.Task, friend string) storage.Task {
+	comments := make([]storage.Comment, len(t.Comments))
+	for i, c := range t.Comments {
+		if c.Author == "" && !slices.Contains(local.Comments, c) {
+			c.Author = friend
 		}
-		color.New(color.FgRed).Println("JSON marshal error")
+		comments[i] = c
+	}
+	t.Comments = comments
+	return t
+}
+
+// how often the change log is checked for subscribers, and how often an
+// idle subscription is pinged so dead clients are noticed
+const (
+	subscribePoll = 500 * time.Millisecond
+	subscribePing = 30 * time.Second
+)
+
+// send the current tasks, then stream changes until the client goes away
+func (s *server) subscribe(c *codec, sess *session, start time.Time) {
+	// take the offset first so no change between it and the list is lost
+	offset, err := storage.HistoryOffset()
+	if err != nil {
+		s.cfg.Logger.Error("failed to read the change log", "err", err)
+		s.reply(c, sess, start, errorResponse(RequestSubscribe, ErrInternal, "failed to read the change log"))
 		return
 	}
+	tasks, err := storage.List()
+	if err != nil {
+		s.cfg.Logger.Error("failed to load tasks", "err", err)
+		s.reply(c, sess, start, errorResponse(RequestSubscribe, ErrInternal, "failed to load tasks"))
+		return
+	}
+	rule, err := s.rule(sess)
+	if err != nil {
+		s.cfg.Logger.Error("failed to load sharing rules", "err", err)
+		s.reply(c, sess, start, errorResponse(RequestSubscribe, ErrInternal, "failed to load sharing rules"))
+		return
+	}
+	initial := rule.Filter(tasks)
+	if !s.reply(c, sess, start, Response{Type: RequestSubscribe, Tasks: initial}) {
+		return
+	}
+	// tasks the friend has been sent, only these are reported when hidden
+	seen := map[int]bool{}
+	for _, t := range initial {
+		seen[t.ID] = true
+	}
+	s.cfg.Logger.Info("friend started watching", sess.attrs()...)
+	s.metrics.watching(1)
+	defer s.metrics.watching(-1)
 
-	if _, err := conn.Write(data); err != nil {
-		fmt.Println("write error:", err)
+	// nothing more is expected from the client, reading only notices it
+	// leave, so there is no idle timeout; pings notice dead clients instead
+	if !s.awaitRequest(sess.conn, 0) {
 		return
 	}
-	color.New(color.FgGreen).Printf("Shared %d tasks with %s\n", len(tasks), conn.RemoteAddr())
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, err := c.readLine(); err != nil {
+				return
+			}
+		}
+	}()
+
+	poll := time.NewTicker(subscribePoll)
+	defer poll.Stop()
+	ping := time.NewTicker(subscribePing)
+	defer ping.Stop()
+	for {
+		select {
+		case <-gone:
+			s.cfg.Logger.Info("friend stopped watching", append(sess.attrs(), "duration", time.Since(start))...)
+			return
+		case <-ping.C:
+			if !s.writeResponse(c, sess.conn, Response{Type: ResponsePing}) {
+				return
+			}
+		case <-poll.C:
+			var events []storage.Event
+			events, offset, err = storage.HistorySince(offset)
+			if err != nil {
+				s.cfg.Logger.Error("failed to read the change log", "err", err)
+			}
+			if len(events) == 0 {
+				continue
+			}
+			// rules may have changed since the subscription started
+			if rule, err = s.rule(sess); err != nil {
+				s.cfg.Logger.Error("failed to load sharing rules", "err", err)
+				return
+			}
+			for _, e := range events {
+				if shared, ok := rule.FilterEvent(e, seen[e.Task.ID]); ok {
+					if shared.Action == storage.ActionDelete {
+						delete(seen, e.Task.ID)
+					} else {
+						seen[e.Task.ID] = true
+					}
+					if !s.writeResponse(c, sess.conn, Response{Type: ResponseEvent, Event: &shared}) {
+						return
+					}
+					s.cfg.Logger.Debug("sent event", append(sess.attrs(), "action", shared.Action, "task", shared.Task.ID)...)
+				}
+			}
+		}
+	}
+}
+
+// answer a request and record it in the access log
+func (s *server) reply(c *codec, sess *session, start time.Time, resp Response) bool {
+	ok := s.writeResponse(c, sess.conn, resp)
+	result := "ok"
+	switch {
+	case resp.Error != nil:
+		result = resp.Error.Code
+	case resp.Queued:
+		result = "queued"
+	case !ok:
+		result = "write_failed"
+	}
+	tasks := len(resp.Tasks)
+	if resp.Task != nil {
+		tasks = 1
+	}
+	if resp.Sync != nil {
+		tasks = len(resp.Sync.Tasks)
+	}
+	took := time.Since(start)
+	s.metrics.observe(resp.Type, result, took)
+	s.cfg.AccessLog.Info("request", append(sess.attrs(),
+		"type", resp.Type,
+		"tasks", tasks,
+		"duration", took,
+		"result", result)...)
+	return ok
+}
+
+func (s *server) writeResponse(c *codec, conn net.Conn, resp Response) bool {
+	conn.SetWriteDeadline(time.Now().Add(s.cfg.WriteTimeout))
+	if err := c.write(resp); err != nil {
+		s.cfg.Logger.Debug("write failed", "remote", conn.RemoteAddr().String(), "err", err)
+		return false
+	}
+	return true
 }
@@ -0,0 +1,245 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+// key of the rule used for friends without a rule of their own
+const DefaultShareRule = "*"
+
+// content shown instead of a redacted task
+const redactedContent = "(private)"
+
+// task fields a rule can hide from friends
+var HideableFields = []string{"tags", "project", "priority", "due", "dates", "comments"}
+
+// changes a rule can allow friends to make
+var Permissions = []string{RequestAdd, RequestDone, RequestComment, RequestSync}
+
+// what one friend gets to see and change of the task list
+type ShareRule struct {
+	// share only tasks with one of these tags or projects, all when both are empty
+	Tags     []string `json:"tags,omitempty"`
+	Projects []string `json:"projects,omitempty"`
+	// never share tasks with one of these tags
+	ExcludeTags []string `json:"exclude_tags,omitempty"`
+	// replace the content of shared tasks and drop their comments
+	Redact bool `json:"redact,omitempty"`
+	// fields removed from shared tasks, see HideableFields
+	Hide []string `json:"hide,omitempty"`
+	// changes the friend may make, see Permissions
+	Allow []string `json:"allow,omitempty"`
+	// changes wait in the inbox for approval instead of being applied
+	Review bool `json:"review,omitempty"`
+}
+
+// check that the rule only names known fields and permissions
+func (r ShareRule) Validate() error {
+	for _, f := range r.Hide {
+		if !contains(HideableFields, f) {
+			return fmt.Errorf("cannot hide %q (want one of %s)", f, strings.Join(HideableFields, ", "))
+		}
+	}
+	for _, p := range r.Allow {
+		if !contains(Permissions, p) {
+			return fmt.Errorf("unknown permission %q (want one of %s)", p, strings.Join(Permissions, ", "))
+		}
+	}
+	return nil
+}
+
+// report whether the rule allows the change op, one of Permissions
+func (r ShareRule) Can(op string) bool {
+	return contains(r.Allow, op)
+}
+
+// report whether the rule lets a task through
+func (r ShareRule) Allows(t storage.Task) bool {
+	for _, tag := range t.Tags {
+		if contains(r.ExcludeTags, tag) {
+			return false
+		}
+	}
+	if len(r.Tags) == 0 && len(r.Projects) == 0 {
+		return true
+	}
+	for _, tag := range t.Tags {
+		if contains(r.Tags, tag) {
+			return true
+		}
+	}
+	return t.Project != "" && contains(r.Projects, t.Project)
+}
+
+// the shared version of a task
+func (r ShareRule) apply(t storage.Task) storage.Task {
+	if r.Redact {
+		t.Content = redactedContent
+		t.Comments = nil
+	}
+	for _, f := range r.Hide {
+		switch f {
+		case "tags":
+			t.Tags = nil
+		case "project":
+			t.Project = ""
+		case "priority":
+			t.Priority = ""
+		case "due":
+			t.Due = ""
+			t.Scheduled = ""
+		case "dates":
+			t.CreatedAt = ""
+			t.CompletedAt = ""
+			t.Modified = nil
+			t.Updated = ""
+		case "comments":
+			t.Comments = nil
+		}
+	}
+	return t
+}
+
+// the shared version of a change log event, ok is false when the friend
+// must not see it. shared reports whether the friend has been sent the task
+// so far; such a task that became hidden is reported as deleted, any other
+// hidden task not at all.
+func (r ShareRule) FilterEvent(e storage.Event, shared bool) (storage.Event, bool) {
+	if r.Allows(e.Task) {
+		e.Task = r.apply(e.Task)
+		if contains(r.Hide, "dates") {
+			e.Time = ""
+		}
+		return e, true
+	}
+	if shared {
+		return storage.Event{Action: storage.ActionDelete, Task: storage.Task{ID: e.Task.ID}}, true
+	}
+	return storage.Event{}, false
+}
+
+// the shared version of tasks
+func (r ShareRule) Filter(tasks []storage.Task) []storage.Task {
+	out := make([]storage.Task, 0, len(tasks))
+	for _, t := range tasks {
+		if r.Allows(t) {
+			out = append(out, r.apply(t))
+		}
+	}
+	return out
+}
+
+// sharing rules per friend kept in a JSON file, read on every call like
+// the TokenStore so changes apply to a running server
+type ShareStore struct {
+	path string
+}
+
+func NewShareStore(path string) *ShareStore {
+	return &ShareStore{path: path}
+}
+
+func (s *ShareStore) load() (map[string]ShareRule, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]ShareRule{}, nil
+		}
+		return nil, err
+	}
+	rules := map[string]ShareRule{}
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse share file: %v", err)
+	}
+	return rules, nil
+}
+
+func (s *ShareStore) save(rules map[string]ShareRule) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(rules, "", " ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0600)
+}
+
+// all rules by friend name, DefaultShareRule included
+func (s *ShareStore) Rules() (map[string]ShareRule, error) {
+	return s.load()
+}
+
+// set the rule of friend, DefaultShareRule for everyone else
+func (s *ShareStore) Set(friend string, rule ShareRule) error {
+	if friend == "" {
+		return fmt.Errorf("friend name is empty")
+	}
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	rules, err := s.load()
+	if err != nil {
+		return err
+	}
+	rules[friend] = rule
+	return s.save(rules)
+}
+
+// remove the rule of friend
+func (s *ShareStore) Remove(friend string) error {
+	rules, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := rules[friend]; !ok {
+		return fmt.Errorf("no sharing rule for %q", friend)
+	}
+	delete(rules, friend)
+	return s.save(rules)
+}
+
+// the rule for friend ("" for anonymous clients): their own one, else the
+// default one, else everything is shared
+func (s *ShareStore) RuleFor(friend string) (ShareRule, error) {
+	rules, err := s.load()
+	if err != nil {
+		return ShareRule{}, err
+	}
+	if r, ok := rules[friend]; ok && friend != "" {
+		return r, nil
+	}
+	return rules[DefaultShareRule], nil
+}
+
+// names with a rule, DefaultShareRule first
+func SortedRuleNames(rules map[string]ShareRule) []string {
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == DefaultShareRule) != (names[j] == DefaultShareRule) {
+			return names[i] == DefaultShareRule
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
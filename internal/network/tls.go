@@ -0,0 +1,182 @@
+package network
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// load the server certificate, generating a self-signed one on first use
+func LoadOrCreateCertificate(certPath, keyPath string) (tls.Certificate, error) {
+	if _, err := os.Stat(certPath); err == nil {
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to load certificate: %v", err)
+		}
+		return cert, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "gotodo friend server"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(20, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.MkdirAll(filepath.Dir(certPath), 0755); err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to save key: %v", err)
+	}
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to save certificate: %v", err)
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// SSH-style fingerprint of a DER encoded certificate, "SHA256:<base64>"
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// remembers the certificate fingerprint seen for each friend address
+type Pinner interface {
+	Pinned(addr string) (fingerprint string, ok bool, err error)
+	Pin(addr, fingerprint string) error
+}
+
+// returned by Dial when a friend's certificate differs from the pinned one
+type FingerprintMismatchError struct {
+	Addr     string
+	Expected string
+	Got      string
+}
+
+func (e *FingerprintMismatchError) Error() string {
+	return fmt.Sprintf(`the certificate of %s has CHANGED
+  pinned:    %s
+  presented: %s
+Someone may be intercepting the connection, or your friend regenerated
+their certificate. Check the fingerprint with them, then run
+'gotodo friend forget %s' to trust the new one.`, e.Addr, e.Expected, e.Got, e.Addr)
+}
+
+// fingerprints in an SSH known_hosts style file, one "addr fingerprint"
+// pair per line
+type KnownFriends struct {
+	path string
+}
+
+func NewKnownFriends(path string) *KnownFriends {
+	return &KnownFriends{path: path}
+}
+
+func (k *KnownFriends) load() (map[string]string, error) {
+	f, err := os.Open(k.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	pins := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && !strings.HasPrefix(fields[0], "#") {
+			pins[fields[0]] = fields[1]
+		}
+	}
+	return pins, scanner.Err()
+}
+
+func (k *KnownFriends) save(pins map[string]string) error {
+	if err := os.MkdirAll(filepath.Dir(k.path), 0755); err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, addr := range sortedAddrs(pins) {
+		fmt.Fprintf(&b, "%s %s\n", addr, pins[addr])
+	}
+	return os.WriteFile(k.path, []byte(b.String()), 0644)
+}
+
+func (k *KnownFriends) Pinned(addr string) (string, bool, error) {
+	pins, err := k.load()
+	if err != nil {
+		return "", false, err
+	}
+	fp, ok := pins[addr]
+	return fp, ok, nil
+}
+
+func (k *KnownFriends) Pin(addr, fingerprint string) error {
+	pins, err := k.load()
+	if err != nil {
+		return err
+	}
+	pins[addr] = fingerprint
+	return k.save(pins)
+}
+
+// drop the pinned fingerprint of addr
+func (k *KnownFriends) Forget(addr string) error {
+	pins, err := k.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := pins[addr]; !ok {
+		return fmt.Errorf("no pinned certificate for %s", addr)
+	}
+	delete(pins, addr)
+	return k.save(pins)
+}
+
+func sortedAddrs(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
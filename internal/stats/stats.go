@@ -0,0 +1,251 @@
+package stats
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+// a task as far as statistics are concerned, including deleted ones
+type Record struct {
+	Created   time.Time
+	Completed time.Time // zero while open or when never recorded
+	Deleted   time.Time // zero unless the task was deleted
+	Tags      []string
+	Project   string
+}
+
+// completion counts for one tag or project
+type Breakdown struct {
+	Name  string
+	Total int
+	Done  int
+}
+
+// everything shown by `gotodo stats`
+type Report struct {
+	Total     int
+	Done      int
+	AvgToDone time.Duration // zero when no task has both timestamps
+
+	// oldest bucket first, the last one contains now
+	DailyCreated    []int
+	DailyCompleted  []int
+	WeeklyCreated   []int
+	WeeklyCompleted []int
+
+	CurrentStreak int // consecutive days with a completion, ending today or yesterday
+	LongestStreak int
+
+	ByTag     []Breakdown
+	ByProject []Breakdown
+}
+
+// build records from the current tasks plus the tasks removed according
+// to the change log
+func Collect(tasks []storage.Task, events []storage.Event) []Record {
+	records := make([]Record, 0, len(tasks))
+	add := func(t storage.Task, deleted time.Time) {
+		r := Record{Tags: t.Tags, Project: t.Project, Deleted: deleted}
+		r.Created, _ = t.Created()
+		if t.Done {
+			r.Completed, _ = t.Completed()
+		}
+		records = append(records, r)
+	}
+	for _, t := range tasks {
+		add(t, time.Time{})
+	}
+	for _, e := range events {
+		if e.Action == storage.ActionDelete {
+			deleted, _ := storage.ParseTime(e.Time)
+			add(e.Task, deleted)
+		}
+	}
+	return records
+}
+
+// compute the report for the given number of days and weeks up to now
+func Compute(records []Record, days, weeks int, now time.Time) Report {
+	today := startOfDay(now)
+	// weeks start on Monday
+	thisWeek := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+
+	r := Report{
+		DailyCreated:    make([]int, days),
+		DailyCompleted:  make([]int, days),
+		WeeklyCreated:   make([]int, weeks),
+		WeeklyCompleted: make([]int, weeks),
+	}
+
+	var total time.Duration
+	var timed int
+	completionDays := map[time.Time]bool{}
+	tags := map[string]*Breakdown{}
+	projects := map[string]*Breakdown{}
+
+	for _, rec := range records {
+		r.Total++
+		done := !rec.Completed.IsZero()
+		if done {
+			r.Done++
+			completionDays[startOfDay(rec.Completed)] = true
+			if !rec.Created.IsZero() && rec.Completed.After(rec.Created) {
+				total += rec.Completed.Sub(rec.Created)
+				timed++
+			}
+		}
+
+		if !rec.Created.IsZero() {
+			bucket(r.DailyCreated, today, startOfDay(rec.Created), 1)
+			bucket(r.WeeklyCreated, thisWeek, startOfDay(rec.Created), 7)
+		}
+		if done {
+			bucket(r.DailyCompleted, today, startOfDay(rec.Completed), 1)
+			bucket(r.WeeklyCompleted, thisWeek, startOfDay(rec.Completed), 7)
+		}
+
+		for _, tag := range rec.Tags {
+			count(tags, tag, done)
+		}
+		if rec.Project != "" {
+			count(projects, rec.Project, done)
+		}
+	}
+
+	if timed > 0 {
+		r.AvgToDone = total / time.Duration(timed)
+	}
+	r.CurrentStreak, r.LongestStreak = streaks(completionDays, today)
+	r.ByTag = sorted(tags)
+	r.ByProject = sorted(projects)
+	return r
+}
+
+// increment the bucket of buckets that day falls in. The last bucket starts
+// at last and each one spans size days.
+func bucket(buckets []int, last, day time.Time, size int) {
+	if len(buckets) == 0 || day.After(last.AddDate(0, 0, size-1)) {
+		return
+	}
+	diff := int(math.Round(last.Sub(day).Hours() / 24))
+	back := (diff + size - 1) / size
+	idx := len(buckets) - 1 - back
+	if idx >= 0 {
+		buckets[idx]++
+	}
+}
+
+func count(m map[string]*Breakdown, name string, done bool) {
+	b, ok := m[name]
+	if !ok {
+		b = &Breakdown{Name: name}
+		m[name] = b
+	}
+	b.Total++
+	if done {
+		b.Done++
+	}
+}
+
+// largest groups first, then by name
+func sorted(m map[string]*Breakdown) []Breakdown {
+	out := make([]Breakdown, 0, len(m))
+	for _, b := range m {
+		out = append(out, *b)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Total != out[j].Total {
+			return out[i].Total > out[j].Total
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// current and longest run of consecutive days with a completion. The
+// current streak is still alive when the last completion was yesterday.
+func streaks(days map[time.Time]bool, today time.Time) (current, longest int) {
+	list := make([]time.Time, 0, len(days))
+	for d := range days {
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Before(list[j]) })
+
+	run := 0
+	for i, d := range list {
+		if i > 0 && list[i-1].AddDate(0, 0, 1).Equal(d) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+	}
+
+	start := today
+	if !days[start] {
+		start = today.AddDate(0, 0, -1)
+	}
+	for d := start; days[d]; d = d.AddDate(0, 0, -1) {
+		current++
+	}
+	return current, longest
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Local().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// state of the task list at the end of one day
+type DayPoint struct {
+	Day   time.Time
+	Total int // tasks created so far
+	Done  int // tasks completed so far
+	Open  int
+}
+
+// end-of-day totals for every day from since through until, used by
+// burndown and burnup charts. A task deleted while still open leaves the
+// totals on the day it was deleted, one deleted after completion stays done.
+func Daily(records []Record, since, until time.Time) []DayPoint {
+	var points []DayPoint
+	for d := startOfDay(since); !d.After(startOfDay(until)); d = d.AddDate(0, 0, 1) {
+		end := d.AddDate(0, 0, 1)
+		p := DayPoint{Day: d}
+		for _, rec := range records {
+			if rec.Created.IsZero() || !rec.Created.Before(end) {
+				continue
+			}
+			done := !rec.Completed.IsZero() && rec.Completed.Before(end)
+			if !done && !rec.Deleted.IsZero() && rec.Deleted.Before(end) {
+				continue
+			}
+			p.Total++
+			if done {
+				p.Done++
+			}
+		}
+		p.Open = p.Total - p.Done
+		points = append(points, p)
+	}
+	return points
+}
+
+// records carrying the given tag
+func WithTag(records []Record, tag string) []Record {
+	var out []Record
+	for _, rec := range records {
+		for _, t := range rec.Tags {
+			if t == tag {
+				out = append(out, rec)
+				break
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,146 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+func day(y int, m time.Month, d, h int) time.Time {
+	return time.Date(y, m, d, h, 0, 0, 0, time.Local)
+}
+
+func TestCollect(t *testing.T) {
+	tasks := []storage.Task{
+		{ID: 1, Done: true, CreatedAt: day(2026, 10, 1, 9).String(), CompletedAt: day(2026, 10, 2, 9).String()},
+		{ID: 2, CreatedAt: day(2026, 10, 3, 9).String()},
+	}
+	events := []storage.Event{
+		{Action: storage.ActionAdd, Task: storage.Task{ID: 3}},
+		{Action: storage.ActionDelete, Time: day(2026, 10, 5, 9).String(), Task: storage.Task{ID: 3, CreatedAt: day(2026, 10, 4, 9).String()}},
+	}
+
+	records := Collect(tasks, events)
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+	if !records[0].Completed.Equal(day(2026, 10, 2, 9)) {
+		t.Errorf("Expected completion time to be parsed, got %v", records[0].Completed)
+	}
+	if !records[2].Created.Equal(day(2026, 10, 4, 9)) || !records[2].Deleted.Equal(day(2026, 10, 5, 9)) {
+		t.Errorf("Expected deleted task to be included with its deletion time, got %+v", records[2])
+	}
+}
+
+func TestCompute(t *testing.T) {
+	// Wednesday
+	now := day(2026, 10, 21, 12)
+	records := []Record{
+		{Created: day(2026, 10, 18, 9), Completed: day(2026, 10, 19, 9), Tags: []string{"ops"}},
+		{Created: day(2026, 10, 19, 9), Completed: day(2026, 10, 20, 21), Tags: []string{"ops"}, Project: "web"},
+		{Created: day(2026, 10, 21, 9), Completed: day(2026, 10, 21, 10)},
+		{Created: day(2026, 10, 10, 9), Completed: day(2026, 10, 12, 9)},
+		{Created: day(2026, 10, 21, 8), Project: "web"},
+	}
+
+	r := Compute(records, 7, 3, now)
+
+	// Test totals and average time to complete
+	t.Run("Totals", func(t *testing.T) {
+		if r.Total != 5 || r.Done != 4 {
+			t.Errorf("Expected 5 total and 4 done, got %d and %d", r.Total, r.Done)
+		}
+		expected := (24*time.Hour + 36*time.Hour + time.Hour + 48*time.Hour) / 4
+		if r.AvgToDone != expected {
+			t.Errorf("Expected average %v, got %v", expected, r.AvgToDone)
+		}
+	})
+
+	// Test daily and weekly buckets
+	t.Run("Buckets", func(t *testing.T) {
+		daily := []int{0, 0, 0, 1, 1, 0, 2}
+		for i, v := range daily {
+			if r.DailyCreated[i] != v {
+				t.Errorf("Expected daily created %v, got %v", daily, r.DailyCreated)
+				break
+			}
+		}
+		// weeks starting Oct 5, Oct 12, Oct 19
+		weekly := []int{0, 1, 3}
+		for i, v := range weekly {
+			if r.WeeklyCompleted[i] != v {
+				t.Errorf("Expected weekly completed %v, got %v", weekly, r.WeeklyCompleted)
+				break
+			}
+		}
+	})
+
+	// Test completion streaks
+	t.Run("Streaks", func(t *testing.T) {
+		if r.CurrentStreak != 3 {
+			t.Errorf("Expected current streak 3, got %d", r.CurrentStreak)
+		}
+		if r.LongestStreak != 3 {
+			t.Errorf("Expected longest streak 3, got %d", r.LongestStreak)
+		}
+	})
+
+	// Test per-tag and per-project breakdowns
+	t.Run("Breakdowns", func(t *testing.T) {
+		if len(r.ByTag) != 1 || r.ByTag[0] != (Breakdown{Name: "ops", Total: 2, Done: 2}) {
+			t.Errorf("Unexpected tag breakdown: %v", r.ByTag)
+		}
+		if len(r.ByProject) != 1 || r.ByProject[0] != (Breakdown{Name: "web", Total: 2, Done: 1}) {
+			t.Errorf("Unexpected project breakdown: %v", r.ByProject)
+		}
+	})
+}
+
+func TestDaily(t *testing.T) {
+	records := []Record{
+		{Created: day(2026, 10, 1, 9), Completed: day(2026, 10, 2, 9), Tags: []string{"sprint-12"}},
+		{Created: day(2026, 10, 1, 10), Tags: []string{"sprint-12"}},
+		{Created: day(2026, 10, 3, 9), Completed: day(2026, 10, 3, 18)},
+	}
+
+	points := Daily(records, day(2026, 10, 1, 0), day(2026, 10, 3, 12))
+	expected := []DayPoint{
+		{Day: day(2026, 10, 1, 0), Total: 2, Done: 0, Open: 2},
+		{Day: day(2026, 10, 2, 0), Total: 2, Done: 1, Open: 1},
+		{Day: day(2026, 10, 3, 0), Total: 3, Done: 2, Open: 1},
+	}
+	if len(points) != len(expected) {
+		t.Fatalf("Expected %d points, got %d", len(expected), len(points))
+	}
+	for i := range expected {
+		if !points[i].Day.Equal(expected[i].Day) || points[i].Total != expected[i].Total ||
+			points[i].Done != expected[i].Done || points[i].Open != expected[i].Open {
+			t.Errorf("Expected point %d to be %+v, got %+v", i, expected[i], points[i])
+		}
+	}
+
+	if tagged := WithTag(records, "sprint-12"); len(tagged) != 2 {
+		t.Errorf("Expected 2 tagged records, got %d", len(tagged))
+	}
+
+	// Test a task deleted while open leaves the scope and burndown reaches zero
+	t.Run("Deleted", func(t *testing.T) {
+		records := []Record{
+			{Created: day(2026, 10, 1, 9), Completed: day(2026, 10, 2, 9)},
+			{Created: day(2026, 10, 1, 10), Deleted: day(2026, 10, 3, 9)},
+			{Created: day(2026, 10, 1, 11), Completed: day(2026, 10, 1, 12), Deleted: day(2026, 10, 2, 9)},
+		}
+		points := Daily(records, day(2026, 10, 1, 0), day(2026, 10, 3, 12))
+		expected := []DayPoint{
+			{Total: 3, Done: 1, Open: 2},
+			{Total: 3, Done: 2, Open: 1},
+			{Total: 2, Done: 2, Open: 0},
+		}
+		for i := range expected {
+			if points[i].Total != expected[i].Total || points[i].Done != expected[i].Done || points[i].Open != expected[i].Open {
+				t.Errorf("Expected point %d to be %+v, got %+v", i, expected[i], points[i])
+			}
+		}
+	})
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// actions recorded in the change log
+const (
+	ActionAdd     = "add"
+	ActionDone    = "done"
+	ActionUndone  = "undone"
+	ActionDelete  = "delete"
+	ActionComment = "comment"
+	ActionUpdate  = "update"
+)
+
+// one entry of the change log, Task is the task after the change
+// (or just before it, for deletes)
+type Event struct {
+	Time   string `json:"time"`
+	Action string `json:"action"`
+	Task   Task   `json:"task"`
+}
+
+// layouts Task.CreatedAt and Task.CompletedAt have been written in
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05 -0700 MST",
+	time.RFC3339Nano,
+}
+
+// parse a timestamp written by this package
+func ParseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised time %q", s)
+}
+
+// current time in the format used for task timestamps
+func now() string {
+	return time.Now().Local().String()
+}
+
+// the change log lives next to the tasks file, tasks.json -> tasks.log
+func (s *Store) historyPath() string {
+	return strings.TrimSuffix(s.path, filepath.Ext(s.path)) + ".log"
+}
+
+// append events to the change log and tell the observer. The tasks file
+// has already been saved when this runs, so a failure is only reported as
+// a warning.
+func (s *Store) record(action string, tasks ...Task) {
+	at := now()
+	if s.observer != nil {
+		defer func() {
+			for _, t := range tasks {
+				s.observer(Event{Time: at, Action: action, Task: t})
+			}
+		}()
+	}
+	f, err := os.OpenFile(s.historyPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record change log: %v\n", err)
+		return
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, t := range tasks {
+		if err := enc.Encode(Event{Time: at, Action: action, Task: t}); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to record change log: %v\n", err)
+			return
+		}
+	}
+}
+
+// read the whole change log, oldest first
+func (s *Store) History() ([]Event, error) {
+	f, err := os.Open(s.historyPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Event{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	events := []Event{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("corrupt change log entry: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+// size of the change log, where HistorySince starts reading new events
+func (s *Store) HistoryOffset() (int64, error) {
+	info, err := os.Stat(s.historyPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// events appended to the change log after offset, and the offset to
+// continue from. An entry still being written is left for the next call.
+// When the log has shrunk it is read again from the start.
+func (s *Store) HistorySince(offset int64) ([]Event, int64, error) {
+	f, err := os.Open(s.historyPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Event{}, 0, nil
+		}
+		return nil, offset, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, offset, err
+	}
+	if info.Size() < offset {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, err
+	}
+
+	events := []Event{}
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// incomplete last line
+			return events, offset, nil
+		}
+		if err != nil {
+			return events, offset, err
+		}
+		offset += int64(len(line))
+		if len(line) <= 1 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return events, offset, fmt.Errorf("corrupt change log entry: %w", err)
+		}
+		events = append(events, e)
+	}
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// a change sent by a friend. It is either applied at once or waits in the
+// inbox until the owner accepts it.
+type InboxItem struct {
+	ID     int    `json:"id"`
+	From   string `json:"from"`
+	Action string `json:"action"` // ActionAdd, ActionDone or ActionComment
+	// the new task, for ActionAdd
+	Task *Task `json:"task,omitempty"`
+	// the task changed, for ActionDone and ActionComment
+	TaskID     int    `json:"task_id,omitempty"`
+	Text       string `json:"text,omitempty"`
+	ReceivedAt string `json:"received_at,omitempty"`
+}
+
+// tag put on tasks added by a friend, e.g. "from:alice"
+func SenderTag(friend string) string {
+	return "from:" + friend
+}
+
+// apply the change to the task list and return the task it touched
+func (it InboxItem) Apply() (Task, error) {
+	return it.applyTo(std)
+}
+
+func (it InboxItem) applyTo(s *Store) (Task, error) {
+	switch it.Action {
+	case ActionAdd:
+		if it.Task == nil {
+			return Task{}, fmt.Errorf("inbox item %d has no task", it.ID)
+		}
+		nt := *it.Task
+		// drop every sender tag so a friend cannot pass as another one
+		tags := []string{}
+		for _, t := range nt.Tags {
+			if !strings.HasPrefix(t, SenderTag("")) {
+				tags = append(tags, t)
+			}
+		}
+		nt.Tags = append(tags, SenderTag(it.From))
+		nt.Comments = nil
+		return s.AddTask(nt)
+	case ActionDone:
+		if err := s.SetDone(it.TaskID, true); err != nil {
+			return Task{}, err
+		}
+		return s.Get(it.TaskID)
+	case ActionComment:
+		return s.AddComment(it.TaskID, Comment{Author: it.From, Text: it.Text})
+	default:
+		return Task{}, fmt.Errorf("unknown inbox action %q", it.Action)
+	}
+}
+
+// the inbox lives next to the tasks file, tasks.json -> tasks.inbox.json
+func (s *Store) inboxPath() string {
+	return strings.TrimSuffix(s.path, filepath.Ext(s.path)) + ".inbox.json"
+}
+
+func (s *Store) loadInbox() ([]InboxItem, error) {
+	b, err := os.ReadFile(s.inboxPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []InboxItem{}, nil
+		}
+		return nil, err
+	}
+	var items []InboxItem
+	if err := json.Unmarshal(b, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (s *Store) saveInbox(items []InboxItem) error {
+	data, err := json.MarshalIndent(items, "", " ")
+	if err != nil {
+		return err
+	}
+	return writeFile(s.inboxPath(), data)
+}
+
+// changes waiting for approval, oldest first
+func (s *Store) Inbox() ([]InboxItem, error) {
+	return s.loadInbox()
+}
+
+// put a change in the inbox, the ID and receive time are assigned here
+func (s *Store) QueueInbox(it InboxItem) (InboxItem, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return InboxItem{}, err
+	}
+	defer unlock()
+	items, err := s.loadInbox()
+	if err != nil {
+		return InboxItem{}, err
+	}
+	it.ID = 1
+	for _, existing := range items {
+		if existing.ID >= it.ID {
+			it.ID = existing.ID + 1
+		}
+	}
+	it.ReceivedAt = now()
+	if err := s.saveInbox(append(items, it)); err != nil {
+		return InboxItem{}, err
+	}
+	return it, nil
+}
+
+// apply an inbox item and remove it. It stays in the inbox when it cannot
+// be applied.
+func (s *Store) AcceptInbox(id int) (Task, error) {
+	// taken out first so that no one else applies it too
+	it, err := s.RejectInbox(id)
+	if err != nil {
+		return Task{}, err
+	}
+	t, err := it.applyTo(s)
+	if err != nil {
+		if qerr := s.requeueInbox(it); qerr != nil {
+			return Task{}, fmt.Errorf("%v, and failed to put it back in the inbox: %v", err, qerr)
+		}
+		return Task{}, err
+	}
+	return t, nil
+}
+
+// drop an inbox item without applying it
+func (s *Store) RejectInbox(id int) (InboxItem, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return InboxItem{}, err
+	}
+	defer unlock()
+	items, err := s.loadInbox()
+	if err != nil {
+		return InboxItem{}, err
+	}
+	for i, it := range items {
+		if it.ID == id {
+			return it, s.saveInbox(append(items[:i], items[i+1:]...))
+		}
+	}
+	return InboxItem{}, fmt.Errorf("inbox item %d not found", id)
+}
+
+// put an item taken out by AcceptInbox back in its place
+func (s *Store) requeueInbox(it InboxItem) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	items, err := s.loadInbox()
+	if err != nil {
+		return err
+	}
+	i, _ := slices.BinarySearchFunc(items, it.ID, func(o InboxItem, id int) int { return o.ID - id })
+	return s.saveInbox(slices.Insert(items, i, it))
+}
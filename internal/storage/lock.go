@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// the lock file lives next to the tasks file, tasks.json -> tasks.lock
+func (s *Store) lockPath() string {
+	return strings.TrimSuffix(s.path, filepath.Ext(s.path)) + ".lock"
+}
+
+// keep other goroutines and processes from changing the list until the
+// returned function is called. Reads need no lock, files are replaced
+// whole by writeFile.
+func (s *Store) lock() (func(), error) {
+	s.mu.Lock()
+	f, err := os.OpenFile(s.lockPath(), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		s.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		// closing the file releases its lock
+		f.Close()
+		s.mu.Unlock()
+	}, nil
+}
+
+// write a file under a temporary name and rename it into place, so that
+// readers see either the old or the new content
+func writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+
+package storage
+
+import "os"
+
+// other platforms only get the lock of this process
+func lockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,38 @@
+package storage
+
+// a task list kept somewhere other than the local file, e.g. on a hosted
+// gotodo server
+type Remote interface {
+	List() ([]Task, error)
+	AddTask(t Task) (Task, error)
+	Update(id int, p TaskPatch) (Task, error)
+	Delete(id int) error
+	AddComment(id int, c Comment) (Task, error)
+}
+
+// delete the tasks of the remote list one by one
+func (s *Store) clearRemote() error {
+	tasks, err := s.remote.List()
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		if err := s.remote.Delete(t.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run check on the current remote copy of a task. The server may change
+// the task before the request that follows.
+func (s *Store) checkRemote(id int, check func(Task) error) error {
+	if check == nil {
+		return nil
+	}
+	t, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	return check(t)
+}
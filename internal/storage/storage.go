@@ -5,41 +5,99 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
+	"strings"
 	"time"
 )
 
 // defination of a basic task
 type Task struct {
-	ID        int    `json:"id"`
-	Content   string `json:"content"`
-	Done      bool   `json:"done"`
+	ID        int      `json:"id"`
+	Content   string   `json:"content"`
+	Done      bool     `json:"done"`
+	CreatedAt string   `json:"created_at"`
+	Tags      []string `json:"tags,omitempty"`
+	Priority  string   `json:"priority,omitempty"`
+	Project   string   `json:"project,omitempty"`
+	Due       string   `json:"due,omitempty"`
+	// the day work on the task is planned for, same layout as Due
+	Scheduled string `json:"scheduled,omitempty"`
+	// set when the task is marked done, same format as CreatedAt
+	CompletedAt string    `json:"completed_at,omitempty"`
+	Comments    []Comment `json:"comments,omitempty"`
+
+	// stable identity across synced lists, see sync.go
+	UUID string `json:"uuid,omitempty"`
+	// when each of SyncFields was last changed, wherever that happened
+	Modified map[string]string `json:"modified,omitempty"`
+	// when this copy of the task last changed, by the local clock
+	Updated string `json:"updated,omitempty"`
+}
+
+// a note left on a task, Author is empty for the owner of the list
+type Comment struct {
+	Author    string `json:"author,omitempty"`
+	Text      string `json:"text"`
 	CreatedAt string `json:"created_at"`
 }
 
-var filePath string
+// creation time of the task, ok is false when it is missing or unreadable
+func (t Task) Created() (time.Time, bool) {
+	c, err := ParseTime(t.CreatedAt)
+	return c, err == nil
+}
 
-// default save path is ~/.gotodo/tasks.json
-func init() {
-	home, _ := os.UserHomeDir()
-	dir := filepath.Join(home, ".gotodo")
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to create directory: %v\n", err)
+// completion time of the task, ok is false when it is not recorded
+func (t Task) Completed() (time.Time, bool) {
+	if t.CompletedAt == "" {
+		return time.Time{}, false
 	}
-	filePath = filepath.Join(dir, "tasks.json")
+	c, err := ParseTime(t.CompletedAt)
+	return c, err == nil
 }
 
-func SetPath(p string) {
-	filePath = p
+// layout of Task.Due and Task.Scheduled
+const DateLayout = "2006-01-02"
+
+// due date of the task in local time, ok is false when it has none
+func (t Task) DueDate() (time.Time, bool) {
+	return localDate(t.Due)
+}
+
+// scheduled date of the task in local time, ok is false when it has none
+func (t Task) ScheduledDate() (time.Time, bool) {
+	return localDate(t.Scheduled)
 }
 
-func GetCurrentPath() string {
-	return filePath
+func localDate(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	d, err := time.ParseInLocation(DateLayout, s, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return d, true
+}
+
+// valid values for Task.Priority, highest first
+var Priorities = []string{"high", "medium", "low"}
+
+// check that p is empty or one of Priorities
+func ValidatePriority(p string) error {
+	if p == "" {
+		return nil
+	}
+	for _, v := range Priorities {
+		if p == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid priority %q (want one of %s)", p, strings.Join(Priorities, ", "))
 }
 
 // load all tasks from json file
-func load() ([]Task, error) {
-	b, err := os.ReadFile(filePath)
+func (s *Store) load() ([]Task, error) {
+	b, err := os.ReadFile(s.path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return []Task{}, nil
@@ -57,59 +115,325 @@ func load() ([]Task, error) {
 }
 
 // save tasks to file
-func save(tasks []Task) error {
+func (s *Store) save(tasks []Task) error {
 	data, err := json.MarshalIndent(tasks, "", " ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(filePath, data, 0644)
+	return writeFile(s.path, data)
 }
 
 // list all tasks
-func List() ([]Task, error) {
-	return load()
+func (s *Store) List() ([]Task, error) {
+	if s.remote != nil {
+		return s.remote.List()
+	}
+	return s.load()
 }
 
 // add a new task
-func Add(content string) (Task, error) {
-	tasks, err := load()
+func (s *Store) Add(content string) (Task, error) {
+	return s.AddTask(Task{Content: content})
+}
+
+// add a new task with its optional fields filled in,
+// the ID and creation time are assigned here
+func (s *Store) AddTask(nt Task) (Task, error) {
+	return s.addTask(nt, "")
+}
+
+// add a new task under a UUID chosen elsewhere, e.g. by a calendar app,
+// instead of a new one. Not available for remote lists.
+func (s *Store) AddTaskWithUUID(nt Task, uuid string) (Task, error) {
+	if uuid == "" {
+		return Task{}, fmt.Errorf("task UUID is empty")
+	}
+	if s.remote != nil {
+		return Task{}, fmt.Errorf("remote lists choose their own task UUIDs")
+	}
+	return s.addTask(nt, uuid)
+}
+
+// returned by AddTaskWithUUID for a UUID another task has
+var ErrUUIDTaken = errors.New("a task with this UUID already exists")
+
+// check the fields of a task about to be added, AddTask refuses it with
+// the same error
+func ValidateTask(nt Task) error {
+	if err := ValidatePriority(nt.Priority); err != nil {
+		return err
+	}
+	if err := validateDate("due", nt.Due); err != nil {
+		return err
+	}
+	return validateDate("scheduled", nt.Scheduled)
+}
+
+func (s *Store) addTask(nt Task, uuid string) (Task, error) {
+	if err := ValidateTask(nt); err != nil {
+		return Task{}, err
+	}
+	if s.remote != nil {
+		return s.remote.AddTask(nt)
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return Task{}, err
+	}
+	defer unlock()
+	tasks, err := s.load()
 	if err != nil {
 		return Task{}, err
 	}
 	nextID := 1
 	for _, t := range tasks {
+		if uuid != "" && t.UUID == uuid {
+			return Task{}, fmt.Errorf("%w: %s", ErrUUIDTaken, uuid)
+		}
 		if t.ID >= nextID {
 			nextID = t.ID + 1
 		}
 	}
-	nt := Task{
-		ID:        nextID,
-		Content:   content,
-		Done:      false,
-		CreatedAt: time.Now().Local().String(),
+	nt.ID = nextID
+	nt.Done = false
+	nt.CreatedAt = now()
+	nt.CompletedAt = ""
+	nt.UUID = uuid
+	if nt.UUID == "" {
+		nt.UUID = newUUID()
 	}
+	nt.Modified = nil
+	touch(&nt, SyncFields...)
 	tasks = append(tasks, nt)
-	return nt, save(tasks)
+	if err := s.save(tasks); err != nil {
+		return nt, err
+	}
+	s.record(ActionAdd, nt)
+	return nt, nil
 }
 
 // set the status of a task
-func SetDone(id int, done bool) error {
-	tasks, err := load()
+func (s *Store) SetDone(id int, done bool) error {
+	_, err := s.Update(id, TaskPatch{Done: &done})
+	return err
+}
+
+// set or clear (with "") the due date of a task
+func (s *Store) SetDue(id int, due string) error {
+	_, err := s.Update(id, TaskPatch{Due: &due})
+	return err
+}
+
+// set or clear (with "") the scheduled date of a task
+func (s *Store) SetScheduled(id int, day string) error {
+	_, err := s.Update(id, TaskPatch{Scheduled: &day})
+	return err
+}
+
+// a partial change of a task, nil fields are left alone
+type TaskPatch struct {
+	Content   *string   `json:"content,omitempty"`
+	Done      *bool     `json:"done,omitempty"`
+	Tags      *[]string `json:"tags,omitempty"`
+	Priority  *string   `json:"priority,omitempty"`
+	Project   *string   `json:"project,omitempty"`
+	Due       *string   `json:"due,omitempty"`
+	Scheduled *string   `json:"scheduled,omitempty"`
+	// when the task was completed, same format as CreatedAt, now when nil.
+	// Only used when the patch marks the task done.
+	CompletedAt *string `json:"completed_at,omitempty"`
+}
+
+// apply a patch to a task and return the updated task
+func (s *Store) Update(id int, p TaskPatch) (Task, error) {
+	return s.UpdateIf(id, p, nil)
+}
+
+// Update, unless check refuses the task as it is now; its error is
+// returned. Nothing changes the task between check and the update.
+func (s *Store) UpdateIf(id int, p TaskPatch, check func(Task) error) (Task, error) {
+	if p.Content != nil && strings.TrimSpace(*p.Content) == "" {
+		return Task{}, fmt.Errorf("task content is empty")
+	}
+	if p.Priority != nil {
+		if err := ValidatePriority(*p.Priority); err != nil {
+			return Task{}, err
+		}
+	}
+	if p.Due != nil {
+		if err := validateDate("due", *p.Due); err != nil {
+			return Task{}, err
+		}
+	}
+	if p.Scheduled != nil {
+		if err := validateDate("scheduled", *p.Scheduled); err != nil {
+			return Task{}, err
+		}
+	}
+	if p.CompletedAt != nil {
+		if _, err := ParseTime(*p.CompletedAt); err != nil {
+			return Task{}, fmt.Errorf("invalid completion time %q", *p.CompletedAt)
+		}
+	}
+	if s.remote != nil {
+		if err := s.checkRemote(id, check); err != nil {
+			return Task{}, err
+		}
+		return s.remote.Update(id, p)
+	}
+	unlock, err := s.lock()
 	if err != nil {
-		return err
+		return Task{}, err
+	}
+	defer unlock()
+	tasks, err := s.load()
+	if err != nil {
+		return Task{}, err
+	}
+	for i := range tasks {
+		if tasks[i].ID != id {
+			continue
+		}
+		if check != nil {
+			if err := check(tasks[i]); err != nil {
+				return Task{}, err
+			}
+		}
+		t := &tasks[i]
+		var fields []string
+		if p.Content != nil {
+			t.Content = strings.TrimSpace(*p.Content)
+			fields = append(fields, FieldContent)
+		}
+		if p.Done != nil && *p.Done != t.Done {
+			t.Done = *p.Done
+			t.CompletedAt = ""
+			if t.Done {
+				t.CompletedAt = now()
+				if p.CompletedAt != nil {
+					t.CompletedAt = *p.CompletedAt
+				}
+			}
+			fields = append(fields, FieldDone)
+		}
+		if p.Tags != nil {
+			t.Tags = *p.Tags
+			fields = append(fields, FieldTags)
+		}
+		if p.Priority != nil {
+			t.Priority = *p.Priority
+			fields = append(fields, FieldPriority)
+		}
+		if p.Project != nil {
+			t.Project = *p.Project
+			fields = append(fields, FieldProject)
+		}
+		if p.Due != nil {
+			t.Due = *p.Due
+			fields = append(fields, FieldDue)
+		}
+		if p.Scheduled != nil {
+			t.Scheduled = *p.Scheduled
+			fields = append(fields, FieldScheduled)
+		}
+		if len(fields) == 0 {
+			return *t, nil
+		}
+		touch(t, fields...)
+		if err := s.save(tasks); err != nil {
+			return Task{}, err
+		}
+		action := ActionUpdate
+		if len(fields) == 1 && fields[0] == FieldDone {
+			action = ActionUndone
+			if t.Done {
+				action = ActionDone
+			}
+		}
+		s.record(action, *t)
+		return *t, nil
+	}
+	return Task{}, fmt.Errorf("task %d not found", id)
+}
+
+// add a comment to a task and return the updated task
+func (s *Store) AddComment(id int, c Comment) (Task, error) {
+	c.Text = strings.TrimSpace(c.Text)
+	if c.Text == "" {
+		return Task{}, fmt.Errorf("comment is empty")
+	}
+	if s.remote != nil {
+		return s.remote.AddComment(id, c)
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return Task{}, err
+	}
+	defer unlock()
+	tasks, err := s.load()
+	if err != nil {
+		return Task{}, err
 	}
 	for i := range tasks {
 		if tasks[i].ID == id {
-			tasks[i].Done = done
-			return save(tasks)
+			c.CreatedAt = now()
+			tasks[i].Comments = append(tasks[i].Comments, c)
+			touch(&tasks[i])
+			if err := s.save(tasks); err != nil {
+				return Task{}, err
+			}
+			s.record(ActionComment, tasks[i])
+			return tasks[i], nil
 		}
 	}
-	return fmt.Errorf("task %d not found", id)
+	return Task{}, fmt.Errorf("task %d not found", id)
+}
+
+// find a task by ID
+func (s *Store) Get(id int) (Task, error) {
+	tasks, err := s.List()
+	if err != nil {
+		return Task{}, err
+	}
+	for _, t := range tasks {
+		if t.ID == id {
+			return t, nil
+		}
+	}
+	return Task{}, fmt.Errorf("task %d not found", id)
+}
+
+// check that date is empty or in DateLayout, kind names it in the error
+func validateDate(kind, date string) error {
+	if date == "" {
+		return nil
+	}
+	if _, err := time.Parse(DateLayout, date); err != nil {
+		return fmt.Errorf("invalid %s date %q (want YYYY-MM-DD)", kind, date)
+	}
+	return nil
 }
 
 // delete a task
-func Delete(id int) error {
-	tasks, err := load()
+func (s *Store) Delete(id int) error {
+	return s.DeleteIf(id, nil)
+}
+
+// Delete, unless check refuses the task as it is now; its error is
+// returned. Nothing changes the task between check and the delete.
+func (s *Store) DeleteIf(id int, check func(Task) error) error {
+	if s.remote != nil {
+		if err := s.checkRemote(id, check); err != nil {
+			return err
+		}
+		return s.remote.Delete(id)
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	tasks, err := s.load()
 	if err != nil {
 		return err
 	}
@@ -123,11 +447,37 @@ func Delete(id int) error {
 	if idx < 0 {
 		return fmt.Errorf("task %d not found", id)
 	}
+	if check != nil {
+		if err := check(tasks[idx]); err != nil {
+			return err
+		}
+	}
+	removed := tasks[idx]
 	tasks = append(tasks[:idx], tasks[idx+1:]...)
-	return save(tasks)
+	if err := s.save(tasks); err != nil {
+		return err
+	}
+	s.record(ActionDelete, removed)
+	s.bury(removed)
+	return nil
 }
 
 // overwrite file by an empty list
-func Clear() error {
-	return save([]Task{})
+func (s *Store) Clear() error {
+	if s.remote != nil {
+		return s.clearRemote()
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// an unreadable file is still cleared, there is just nothing to record
+	tasks, _ := s.load()
+	if err := s.save([]Task{}); err != nil {
+		return err
+	}
+	s.record(ActionDelete, tasks...)
+	s.bury(tasks...)
+	return nil
 }
@@ -1,8 +1,10 @@
 package storage
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 )
@@ -281,3 +283,442 @@ func TestFilePermissions(t *testing.T) {
 		t.Fatalf("Failed to restore directory permissions: %v", err)
 	}
 }
+
+func TestAddTaskFields(t *testing.T) {
+	// Create temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "gotodo-test-fields")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Set temporary file path
+	testFile := filepath.Join(tempDir, "test_tasks_fields.json")
+	SetPath(testFile)
+
+	// Test tags, priority and project are stored
+	task, err := AddTask(Task{Content: "Tagged task", Tags: []string{"ops"}, Priority: "high", Project: "infra"})
+	if err != nil {
+		t.Fatalf("Failed to add task: %v", err)
+	}
+
+	tasks, err := List()
+	if err != nil {
+		t.Fatalf("Failed to list tasks: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != task.ID {
+		t.Fatalf("Expected the added task, got %v", tasks)
+	}
+	if len(tasks[0].Tags) != 1 || tasks[0].Tags[0] != "ops" || tasks[0].Priority != "high" || tasks[0].Project != "infra" {
+		t.Errorf("Task fields were not stored: %+v", tasks[0])
+	}
+
+	// Test invalid priority is rejected
+	if _, err := AddTask(Task{Content: "Bad priority", Priority: "urgent"}); err == nil {
+		t.Error("Expected error for invalid priority")
+	}
+
+	// Test scheduled dates are validated, set and cleared
+	if _, err := AddTask(Task{Content: "Bad schedule", Scheduled: "next week"}); err == nil {
+		t.Error("Expected error for invalid scheduled date")
+	}
+	if err := SetScheduled(task.ID, "2026-11-02"); err != nil {
+		t.Fatalf("Failed to set scheduled date: %v", err)
+	}
+	if got, _ := Get(task.ID); got.Scheduled != "2026-11-02" || got.Modified[FieldScheduled] == "" {
+		t.Errorf("Expected task scheduled for 2026-11-02, got %+v", got)
+	}
+	if err := SetScheduled(task.ID, ""); err != nil {
+		t.Fatalf("Failed to clear scheduled date: %v", err)
+	}
+	if got, _ := Get(task.ID); got.Scheduled != "" {
+		t.Errorf("Expected no scheduled date, got %q", got.Scheduled)
+	}
+
+	// Test a UUID given elsewhere is kept, and only once
+	task, err = AddTaskWithUUID(Task{Content: "From a calendar"}, "ABC-123")
+	if err != nil || task.UUID != "ABC-123" {
+		t.Errorf("Expected UUID ABC-123, got %q (%v)", task.UUID, err)
+	}
+	if _, err := AddTaskWithUUID(Task{Content: "Again"}, "ABC-123"); err == nil {
+		t.Error("Expected error for a UUID already in use")
+	}
+}
+
+func TestChangeLog(t *testing.T) {
+	// Create temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "gotodo-test-history")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Set temporary file path
+	testFile := filepath.Join(tempDir, "test_tasks_history.json")
+	SetPath(testFile)
+
+	if _, err := Add("Logged task"); err != nil {
+		t.Fatalf("Failed to add task: %v", err)
+	}
+	if err := SetDone(1, true); err != nil {
+		t.Fatalf("Failed to mark task as done: %v", err)
+	}
+
+	// Test completion time is recorded
+	tasks, err := List()
+	if err != nil {
+		t.Fatalf("Failed to list tasks: %v", err)
+	}
+	if _, ok := tasks[0].Completed(); !ok {
+		t.Error("Done task should have a completion time")
+	}
+
+	if err := Delete(1); err != nil {
+		t.Fatalf("Failed to delete task: %v", err)
+	}
+
+	// Test every change is in the log
+	events, err := History()
+	if err != nil {
+		t.Fatalf("Failed to read change log: %v", err)
+	}
+	actions := []string{ActionAdd, ActionDone, ActionDelete}
+	if len(events) != len(actions) {
+		t.Fatalf("Expected %d events, got %d", len(actions), len(events))
+	}
+	for i, a := range actions {
+		if events[i].Action != a || events[i].Task.ID != 1 {
+			t.Errorf("Expected event %d to be %s of task 1, got %s of task %d", i, a, events[i].Action, events[i].Task.ID)
+		}
+	}
+}
+
+func TestInbox(t *testing.T) {
+	// Create temporary directory for testing
+	tempDir, err := os.MkdirTemp("", "gotodo-test-inbox")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Set temporary file path
+	testFile := filepath.Join(tempDir, "test_tasks_inbox.json")
+	SetPath(testFile)
+
+	if _, err := Add("Existing task"); err != nil {
+		t.Fatalf("Failed to add task: %v", err)
+	}
+
+	queued := []InboxItem{
+		{From: "alice", Action: ActionAdd, Task: &Task{Content: "From alice", Tags: []string{"team", "from:bob"}}},
+		{From: "bob", Action: ActionComment, TaskID: 1, Text: "Looks good"},
+		{From: "bob", Action: ActionDone, TaskID: 1},
+	}
+	for i, it := range queued {
+		got, err := QueueInbox(it)
+		if err != nil {
+			t.Fatalf("Failed to queue item: %v", err)
+		}
+		if got.ID != i+1 || got.ReceivedAt == "" {
+			t.Errorf("Expected ID %d with receive time, got %+v", i+1, got)
+		}
+	}
+
+	// Test accepting an add tags the task with the sender only
+	t.Run("AcceptAdd", func(t *testing.T) {
+		task, err := AcceptInbox(1)
+		if err != nil {
+			t.Fatalf("Failed to accept item: %v", err)
+		}
+		if task.ID != 2 || len(task.Tags) != 2 || task.Tags[0] != "team" || task.Tags[1] != SenderTag("alice") {
+			t.Errorf("Expected task 2 tagged from alice, got %+v", task)
+		}
+	})
+
+	// Test accepting a comment keeps the author
+	t.Run("AcceptComment", func(t *testing.T) {
+		task, err := AcceptInbox(2)
+		if err != nil {
+			t.Fatalf("Failed to accept item: %v", err)
+		}
+		if len(task.Comments) != 1 || task.Comments[0].Author != "bob" || task.Comments[0].Text != "Looks good" {
+			t.Errorf("Expected bob's comment, got %+v", task.Comments)
+		}
+	})
+
+	// Test rejected items are dropped without being applied
+	t.Run("Reject", func(t *testing.T) {
+		if _, err := RejectInbox(3); err != nil {
+			t.Fatalf("Failed to reject item: %v", err)
+		}
+		task, err := Get(1)
+		if err != nil {
+			t.Fatalf("Failed to get task: %v", err)
+		}
+		if task.Done {
+			t.Error("Rejected completion should not be applied")
+		}
+		items, err := Inbox()
+		if err != nil || len(items) != 0 {
+			t.Errorf("Expected empty inbox, got %v (%v)", items, err)
+		}
+		if _, err := AcceptInbox(3); err == nil {
+			t.Error("Expected error for a removed item")
+		}
+	})
+}
+
+func TestSyncMerge(t *testing.T) {
+	tempDir := t.TempDir()
+	listA := filepath.Join(tempDir, "a.json")
+	listB := filepath.Join(tempDir, "b.json")
+
+	// exchange changes since the given stamps both ways, like 'gotodo sync'
+	sync := func(sinceA, sinceB string) MergeReport {
+		t.Helper()
+		SetPath(listA)
+		tasksA, tombsA, err := Changes(sinceA)
+		if err != nil {
+			t.Fatalf("Failed to get changes: %v", err)
+		}
+		SetPath(listB)
+		tasksB, tombsB, err := Changes(sinceB)
+		if err != nil {
+			t.Fatalf("Failed to get changes: %v", err)
+		}
+		if _, err := Merge(tasksA, tombsA, sinceB, sinceA); err != nil {
+			t.Fatalf("Failed to merge: %v", err)
+		}
+		SetPath(listA)
+		report, err := Merge(tasksB, tombsB, sinceA, sinceB)
+		if err != nil {
+			t.Fatalf("Failed to merge: %v", err)
+		}
+		return report
+	}
+
+	// ID of a task on the current list, IDs differ between lists
+	idOf := func(content string) int {
+		t.Helper()
+		tasks, _ := List()
+		for _, task := range tasks {
+			if task.Content == content {
+				return task.ID
+			}
+		}
+		t.Fatalf("Task %q not found", content)
+		return 0
+	}
+
+	SetPath(listA)
+	if _, err := AddTask(Task{Content: "Shared task", Tags: []string{"team"}}); err != nil {
+		t.Fatalf("Failed to add task: %v", err)
+	}
+	SetPath(listB)
+	if _, err := Add("Task from B"); err != nil {
+		t.Fatalf("Failed to add task: %v", err)
+	}
+
+	// Test new tasks reach the other list with a local ID
+	sync("", "")
+	last := Stamp()
+	SetPath(listA)
+	tasks, _ := List()
+	if len(tasks) != 2 || tasks[1].Content != "Task from B" || tasks[1].ID != 2 {
+		t.Fatalf("Expected B's task as task 2, got %+v", tasks)
+	}
+
+	// Test the newer of two concurrent edits wins and is reported
+	t.Run("Conflict", func(t *testing.T) {
+		SetPath(listA)
+		if err := SetDue(1, "2025-01-01"); err != nil {
+			t.Fatalf("Failed to set due date: %v", err)
+		}
+		SetPath(listB)
+		if err := SetDue(idOf("Shared task"), "2025-02-02"); err != nil {
+			t.Fatalf("Failed to set due date: %v", err)
+		}
+		report := sync(last, last)
+		if len(report.Conflicts) != 1 || report.Conflicts[0].Field != FieldDue || report.Conflicts[0].Winner != "remote" {
+			t.Errorf("Expected one due conflict won by B, got %+v", report.Conflicts)
+		}
+		for _, path := range []string{listA, listB} {
+			SetPath(path)
+			tasks, _ := List()
+			for _, task := range tasks {
+				if task.Content == "Shared task" && task.Due != "2025-02-02" {
+					t.Errorf("Expected due date from B in %s, got %q", filepath.Base(path), task.Due)
+				}
+			}
+		}
+	})
+
+	// Test deletions travel as tombstones
+	t.Run("Delete", func(t *testing.T) {
+		last = Stamp()
+		SetPath(listB)
+		if err := Delete(idOf("Shared task")); err != nil {
+			t.Fatalf("Failed to delete task: %v", err)
+		}
+		sync(last, last)
+		SetPath(listA)
+		tasks, _ := List()
+		if len(tasks) != 1 || tasks[0].Content != "Task from B" {
+			t.Errorf("Expected only B's task left, got %+v", tasks)
+		}
+	})
+}
+
+// a Remote keeping its tasks in memory
+type memoryRemote struct {
+	tasks []Task
+}
+
+func (m *memoryRemote) List() ([]Task, error) { return m.tasks, nil }
+
+func (m *memoryRemote) AddTask(t Task) (Task, error) {
+	t.ID = len(m.tasks) + 1
+	m.tasks = append(m.tasks, t)
+	return t, nil
+}
+
+func (m *memoryRemote) Update(id int, p TaskPatch) (Task, error) {
+	for i := range m.tasks {
+		if m.tasks[i].ID == id {
+			if p.Done != nil {
+				m.tasks[i].Done = *p.Done
+			}
+			if p.Due != nil {
+				m.tasks[i].Due = *p.Due
+			}
+			return m.tasks[i], nil
+		}
+	}
+	return Task{}, os.ErrNotExist
+}
+
+func (m *memoryRemote) Delete(id int) error {
+	for i := range m.tasks {
+		if m.tasks[i].ID == id {
+			m.tasks = append(m.tasks[:i], m.tasks[i+1:]...)
+			return nil
+		}
+	}
+	return os.ErrNotExist
+}
+
+func (m *memoryRemote) AddComment(id int, c Comment) (Task, error) {
+	return Task{}, nil
+}
+
+func TestRemote(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "tasks.json")
+	SetPath(local)
+	remote := &memoryRemote{}
+	SetRemote(remote)
+	defer SetRemote(nil)
+
+	// Test changes go to the remote and leave the local file alone
+	t.Run("Dispatch", func(t *testing.T) {
+		if _, err := Add("Remote task"); err != nil {
+			t.Fatalf("Failed to add task: %v", err)
+		}
+		if _, err := AddTask(Task{Content: "Bad", Priority: "urgent"}); err == nil {
+			t.Error("Expected validation before reaching the remote")
+		}
+		if err := SetDone(1, true); err != nil || !remote.tasks[0].Done {
+			t.Errorf("Expected the remote task done, got %+v (%v)", remote.tasks, err)
+		}
+		if err := SetDue(1, "2026-11-05"); err != nil || remote.tasks[0].Due != "2026-11-05" {
+			t.Errorf("Expected the remote due date set, got %+v (%v)", remote.tasks, err)
+		}
+		if task, err := Get(1); err != nil || task.Content != "Remote task" {
+			t.Errorf("Expected to get the remote task, got %+v (%v)", task, err)
+		}
+		if _, err := os.Stat(local); !os.IsNotExist(err) {
+			t.Errorf("Expected no local file, got %v", err)
+		}
+		Add("Another")
+		if err := Clear(); err != nil || len(remote.tasks) != 0 {
+			t.Errorf("Expected the remote list cleared, got %+v (%v)", remote.tasks, err)
+		}
+	})
+
+	// Test a store of its own works on its file even with a remote set on
+	// the default one, without telling its observer
+	t.Run("NewStore", func(t *testing.T) {
+		other := filepath.Join(t.TempDir(), "other.json")
+		var events []Event
+		SetObserver(func(e Event) { events = append(events, e) })
+		defer SetObserver(nil)
+		s := NewStore(other)
+		if _, err := s.Add("Local task"); err != nil {
+			t.Errorf("Failed to add task: %v", err)
+		}
+		if s.Path() != other || GetCurrentPath() != local || GetRemote() != remote {
+			t.Error("Expected the default path and remote to be left alone")
+		}
+		if len(events) != 0 {
+			t.Errorf("Expected no events from another list, got %+v", events)
+		}
+		if h, _ := s.History(); len(h) != 1 || h[0].Task.Content != "Local task" {
+			t.Errorf("Expected the change logged next to the other file, got %+v", h)
+		}
+		SetRemote(nil)
+		SetPath(other)
+		if tasks, _ := List(); len(tasks) != 1 || tasks[0].Content != "Local task" {
+			t.Errorf("Expected the task in the other file, got %+v", tasks)
+		}
+	})
+}
+
+func TestLocking(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+
+	// Test stores on the same file, as in separate processes, do not lose
+	// each other's changes
+	t.Run("Concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			s := NewStore(path)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					if _, err := s.Add("Task"); err != nil {
+						t.Errorf("Failed to add task: %v", err)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		tasks, _ := NewStore(path).List()
+		ids := map[int]bool{}
+		for _, task := range tasks {
+			ids[task.ID] = true
+		}
+		if len(tasks) != 40 || len(ids) != 40 {
+			t.Errorf("Expected 40 tasks with distinct IDs, got %d with %d IDs", len(tasks), len(ids))
+		}
+	})
+
+	// Test a check refusing the current task stops the change
+	t.Run("UpdateIf", func(t *testing.T) {
+		s := NewStore(path)
+		refused := errors.New("refused")
+		content := "Changed"
+		if _, err := s.UpdateIf(1, TaskPatch{Content: &content}, func(Task) error { return refused }); err != refused {
+			t.Errorf("Expected the check's error, got %v", err)
+		}
+		if err := s.DeleteIf(1, func(Task) error { return refused }); err != refused {
+			t.Errorf("Expected the check's error, got %v", err)
+		}
+		task, _ := s.Get(1)
+		if task.Content != "Task" {
+			t.Errorf("Expected the task unchanged, got %+v", task)
+		}
+		if _, err := s.AddTaskWithUUID(Task{Content: "Twin"}, task.UUID); !errors.Is(err, ErrUUIDTaken) {
+			t.Errorf("Expected ErrUUIDTaken, got %v", err)
+		}
+	})
+}
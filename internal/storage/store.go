@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// a task list kept in one file, with its change log, tombstones, inbox and
+// sync state next to it. The package level functions work on the default
+// store, servers that keep a list per user open one Store for each.
+type Store struct {
+	// guards changes within the process, see lock
+	mu   sync.Mutex
+	path string
+	// where List, AddTask, Update, ... go instead of the file, see SetRemote
+	remote Remote
+	// called with every event recorded in the change log
+	observer func(Event)
+}
+
+// a store for the list at path, without a remote or an observer
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// path of the tasks file
+func (s *Store) Path() string {
+	return s.path
+}
+
+// the list used by the package level functions
+var std = &Store{}
+
+// default save path is ~/.gotodo/tasks.json
+func init() {
+	home, _ := os.UserHomeDir()
+	dir := filepath.Join(home, ".gotodo")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to create directory: %v\n", err)
+	}
+	std.path = filepath.Join(dir, "tasks.json")
+}
+
+// the store used by the package level functions
+func Default() *Store {
+	return std
+}
+
+func SetPath(p string) {
+	std.path = p
+}
+
+func GetCurrentPath() string {
+	return std.path
+}
+
+// send List, AddTask, SetDone, SetDue, Update, AddComment, Get, Delete and
+// Clear to r instead of the local file, nil switches back. History, the
+// inbox and sync always work on the local file.
+func SetRemote(r Remote) {
+	std.remote = r
+}
+
+func GetRemote() Remote {
+	return std.remote
+}
+
+// set the function told about every change, nil for none. It runs on the
+// goroutine making the change and must not block.
+func SetObserver(f func(Event)) {
+	std.observer = f
+}
+
+func List() ([]Task, error) { return std.List() }
+
+func Add(content string) (Task, error) { return std.Add(content) }
+
+func AddTask(nt Task) (Task, error) { return std.AddTask(nt) }
+
+func AddTaskWithUUID(nt Task, uuid string) (Task, error) { return std.AddTaskWithUUID(nt, uuid) }
+
+func SetDone(id int, done bool) error { return std.SetDone(id, done) }
+
+func SetDue(id int, due string) error { return std.SetDue(id, due) }
+
+func SetScheduled(id int, day string) error { return std.SetScheduled(id, day) }
+
+func Update(id int, p TaskPatch) (Task, error) { return std.Update(id, p) }
+
+func UpdateIf(id int, p TaskPatch, check func(Task) error) (Task, error) {
+	return std.UpdateIf(id, p, check)
+}
+
+func AddComment(id int, c Comment) (Task, error) { return std.AddComment(id, c) }
+
+func Get(id int) (Task, error) { return std.Get(id) }
+
+func Delete(id int) error { return std.Delete(id) }
+
+func DeleteIf(id int, check func(Task) error) error { return std.DeleteIf(id, check) }
+
+func Clear() error { return std.Clear() }
+
+func History() ([]Event, error) { return std.History() }
+
+func HistoryOffset() (int64, error) { return std.HistoryOffset() }
+
+func HistorySince(offset int64) ([]Event, int64, error) { return std.HistorySince(offset) }
+
+func Changes(since string) ([]Task, []Tombstone, error) { return std.Changes(since) }
+
+func Merge(remote []Task, remoteTombs []Tombstone, localSince, remoteSince string) (MergeReport, error) {
+	return std.Merge(remote, remoteTombs, localSince, remoteSince)
+}
+
+func GetSyncState(peer string) (SyncState, error) { return std.GetSyncState(peer) }
+
+func SetSyncState(peer string, st SyncState) error { return std.SetSyncState(peer, st) }
+
+func Inbox() ([]InboxItem, error) { return std.Inbox() }
+
+func QueueInbox(it InboxItem) (InboxItem, error) { return std.QueueInbox(it) }
+
+func AcceptInbox(id int) (Task, error) { return std.AcceptInbox(id) }
+
+func RejectInbox(id int) (InboxItem, error) { return std.RejectInbox(id) }
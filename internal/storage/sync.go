@@ -0,0 +1,499 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Synced lists identify tasks by UUID, since numeric IDs are only unique
+// within one list. Every synced field carries the time it was last changed
+// (Task.Modified) and the copy with the newer time wins. Deleted tasks
+// leave a Tombstone behind so the deletion reaches the other lists too.
+
+// fields merged by Merge, comments are merged as a union instead
+const (
+	FieldContent   = "content"
+	FieldDone      = "done"
+	FieldTags      = "tags"
+	FieldPriority  = "priority"
+	FieldProject   = "project"
+	FieldDue       = "due"
+	FieldScheduled = "scheduled"
+)
+
+var SyncFields = []string{FieldContent, FieldDone, FieldTags, FieldPriority, FieldProject, FieldDue, FieldScheduled}
+
+// fixed width UTC layout of sync timestamps, so they compare as strings
+const stampLayout = "2006-01-02T15:04:05.000000000Z"
+
+// the current time as a sync timestamp
+func Stamp() string {
+	return time.Now().UTC().Format(stampLayout)
+}
+
+func stampOf(t time.Time) string {
+	return t.UTC().Format(stampLayout)
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate task UUID: %v", err))
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// note a local change of the given fields
+func touch(t *Task, fields ...string) {
+	at := Stamp()
+	if t.Modified == nil {
+		t.Modified = map[string]string{}
+	}
+	for _, f := range fields {
+		t.Modified[f] = at
+	}
+	t.Updated = at
+}
+
+// fill in the sync metadata of tasks written before it existed, using the
+// creation and completion times. Reports whether anything changed.
+func ensureSync(tasks []Task) bool {
+	changed := false
+	for i := range tasks {
+		t := &tasks[i]
+		if t.UUID != "" && t.Modified != nil && t.Updated != "" {
+			continue
+		}
+		changed = true
+		created := stampOf(time.Unix(0, 0))
+		if c, ok := t.Created(); ok {
+			created = stampOf(c)
+		}
+		if t.UUID == "" {
+			t.UUID = newUUID()
+		}
+		if t.Modified == nil {
+			t.Modified = map[string]string{}
+		}
+		for _, f := range SyncFields {
+			if t.Modified[f] == "" {
+				t.Modified[f] = created
+			}
+		}
+		if c, ok := t.Completed(); ok && t.Done {
+			t.Modified[FieldDone] = stampOf(c)
+		}
+		if t.Updated == "" {
+			t.Updated = latest(t.Modified)
+		}
+	}
+	return changed
+}
+
+func latest(stamps map[string]string) string {
+	max := ""
+	for _, s := range stamps {
+		if s > max {
+			max = s
+		}
+	}
+	return max
+}
+
+// a deleted task, kept so the deletion can be synced
+type Tombstone struct {
+	UUID      string `json:"uuid"`
+	DeletedAt string `json:"deleted_at"`
+	// when the tombstone was stored here, by the local clock
+	Updated string `json:"updated,omitempty"`
+}
+
+// tombstones live next to the tasks file, tasks.json -> tasks.tombstones.json
+func (s *Store) tombstonePath() string {
+	return strings.TrimSuffix(s.path, filepath.Ext(s.path)) + ".tombstones.json"
+}
+
+func (s *Store) loadTombstones() ([]Tombstone, error) {
+	b, err := os.ReadFile(s.tombstonePath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Tombstone{}, nil
+		}
+		return nil, err
+	}
+	var tombs []Tombstone
+	if err := json.Unmarshal(b, &tombs); err != nil {
+		return nil, err
+	}
+	return tombs, nil
+}
+
+func (s *Store) saveTombstones(tombs []Tombstone) error {
+	data, err := json.MarshalIndent(tombs, "", " ")
+	if err != nil {
+		return err
+	}
+	return writeFile(s.tombstonePath(), data)
+}
+
+// leave tombstones for deleted tasks. The tasks file has already been
+// saved when this runs, so a failure is only reported as a warning.
+func (s *Store) bury(tasks ...Task) {
+	if len(tasks) == 0 {
+		return
+	}
+	tombs, err := s.loadTombstones()
+	if err == nil {
+		at := Stamp()
+		for _, t := range tasks {
+			if t.UUID != "" {
+				tombs = append(tombs, Tombstone{UUID: t.UUID, DeletedAt: at, Updated: at})
+			}
+		}
+		err = s.saveTombstones(tombs)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record deletion for sync: %v\n", err)
+	}
+}
+
+// tasks and tombstones changed here after since (a Stamp, "" for all)
+func (s *Store) Changes(since string) ([]Task, []Tombstone, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+	tasks, err := s.load()
+	if err != nil {
+		return nil, nil, err
+	}
+	if ensureSync(tasks) {
+		if err := s.save(tasks); err != nil {
+			return nil, nil, err
+		}
+	}
+	tombs, err := s.loadTombstones()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changed := []Task{}
+	for _, t := range tasks {
+		if t.Updated > since {
+			changed = append(changed, t)
+		}
+	}
+	buried := []Tombstone{}
+	for _, tb := range tombs {
+		if tb.Updated > since {
+			buried = append(buried, tb)
+		}
+	}
+	return changed, buried, nil
+}
+
+// a field both sides changed since the last sync
+type Conflict struct {
+	ID      int // local ID, 0 when the task is not on this list
+	Content string
+	Field   string // one of SyncFields, or "deleted"
+	Local   string
+	Remote  string
+	// the side whose value was kept, "local" or "remote"
+	Winner string
+}
+
+// outcome of merging a batch of remote changes
+type MergeReport struct {
+	Added     int
+	Updated   int
+	Deleted   int
+	Conflicts []Conflict
+}
+
+// merge tasks and tombstones changed on another list into this one.
+// localSince and remoteSince are the times of the previous sync by the
+// local and the remote clock, changes after them on both sides are
+// reported as conflicts. The result does not depend on which side merges,
+// so both lists end up the same.
+func (s *Store) Merge(remote []Task, remoteTombs []Tombstone, localSince, remoteSince string) (MergeReport, error) {
+	var report MergeReport
+	unlock, err := s.lock()
+	if err != nil {
+		return report, err
+	}
+	defer unlock()
+	tasks, err := s.load()
+	if err != nil {
+		return report, err
+	}
+	ensureSync(tasks)
+	tombs, err := s.loadTombstones()
+	if err != nil {
+		return report, err
+	}
+	at := Stamp()
+
+	buried := map[string]Tombstone{}
+	for _, tb := range tombs {
+		buried[tb.UUID] = tb
+	}
+	index := map[string]int{}
+	nextID := 1
+	for i, t := range tasks {
+		index[t.UUID] = i
+		if t.ID >= nextID {
+			nextID = t.ID + 1
+		}
+	}
+
+	// deletions win over edits
+	var added, updated, deleted []Task
+	for _, tb := range remoteTombs {
+		if _, ok := buried[tb.UUID]; ok {
+			continue
+		}
+		tb.Updated = at
+		tombs = append(tombs, tb)
+		buried[tb.UUID] = tb
+		if i, ok := index[tb.UUID]; ok {
+			t := tasks[i]
+			if latest(t.Modified) > localSince {
+				report.Conflicts = append(report.Conflicts, Conflict{ID: t.ID, Content: t.Content, Field: "deleted", Local: "edited", Remote: "deleted", Winner: "remote"})
+			}
+			deleted = append(deleted, t)
+		}
+	}
+
+	for _, r := range remote {
+		if r.UUID == "" {
+			continue
+		}
+		if _, ok := buried[r.UUID]; ok {
+			if latest(r.Modified) > remoteSince && !containsTask(deleted, r.UUID) {
+				report.Conflicts = append(report.Conflicts, Conflict{Content: r.Content, Field: "deleted", Local: "deleted", Remote: "edited", Winner: "local"})
+			}
+			continue
+		}
+		i, ok := index[r.UUID]
+		if !ok {
+			r.ID = nextID
+			nextID++
+			r.Updated = at
+			index[r.UUID] = len(tasks)
+			tasks = append(tasks, r)
+			added = append(added, r)
+			report.Added++
+			continue
+		}
+		merged, conflicts := mergeTask(tasks[i], r, localSince, remoteSince)
+		report.Conflicts = append(report.Conflicts, conflicts...)
+		if !sameTask(tasks[i], merged) {
+			merged.Updated = at
+			tasks[i] = merged
+			updated = append(updated, merged)
+			report.Updated++
+		}
+	}
+
+	kept := tasks[:0]
+	for _, t := range tasks {
+		if _, ok := buried[t.UUID]; !ok {
+			kept = append(kept, t)
+		}
+	}
+	report.Deleted = len(deleted)
+	if err := s.save(kept); err != nil {
+		return report, err
+	}
+	if err := s.saveTombstones(tombs); err != nil {
+		return report, err
+	}
+	s.record(ActionAdd, added...)
+	s.record(ActionUpdate, updated...)
+	s.record(ActionDelete, deleted...)
+	return report, nil
+}
+
+// the task local becomes when Merge takes remote into it, so a merge can be
+// checked before it is made
+func Merged(local, remote Task, localSince, remoteSince string) Task {
+	merged, _ := mergeTask(local, remote, localSince, remoteSince)
+	return merged
+}
+
+// merge one remote copy of a task into the local one, field by field
+func mergeTask(local, remote Task, localSince, remoteSince string) (Task, []Conflict) {
+	var conflicts []Conflict
+	merged := local
+	merged.Modified = map[string]string{}
+	for f, s := range local.Modified {
+		merged.Modified[f] = s
+	}
+	for _, f := range SyncFields {
+		lv, rv := fieldValue(local, f), fieldValue(remote, f)
+		ls, rs := local.Modified[f], remote.Modified[f]
+		if lv == rv {
+			if rs > ls {
+				merged.Modified[f] = rs
+			}
+			continue
+		}
+		// newer change wins, ties go to the larger value so both sides agree
+		useRemote := rs > ls || (rs == ls && rv > lv)
+		if useRemote {
+			setField(&merged, remote, f)
+			merged.Modified[f] = rs
+		}
+		if ls > localSince && rs > remoteSince {
+			c := Conflict{ID: local.ID, Content: local.Content, Field: f, Local: lv, Remote: rv, Winner: "local"}
+			if useRemote {
+				c.Winner = "remote"
+			}
+			conflicts = append(conflicts, c)
+		}
+	}
+	merged.Comments = mergeComments(local.Comments, remote.Comments)
+	return merged, conflicts
+}
+
+// field f of t as a string, for comparing and reporting
+func fieldValue(t Task, f string) string {
+	switch f {
+	case FieldContent:
+		return t.Content
+	case FieldDone:
+		if t.Done {
+			return "done"
+		}
+		return "open"
+	case FieldTags:
+		return strings.Join(t.Tags, ",")
+	case FieldPriority:
+		return t.Priority
+	case FieldProject:
+		return t.Project
+	case FieldDue:
+		return t.Due
+	case FieldScheduled:
+		return t.Scheduled
+	}
+	return ""
+}
+
+func setField(t *Task, from Task, f string) {
+	switch f {
+	case FieldContent:
+		t.Content = from.Content
+	case FieldDone:
+		t.Done = from.Done
+		t.CompletedAt = from.CompletedAt
+	case FieldTags:
+		t.Tags = from.Tags
+	case FieldPriority:
+		t.Priority = from.Priority
+	case FieldProject:
+		t.Project = from.Project
+	case FieldDue:
+		t.Due = from.Due
+	case FieldScheduled:
+		t.Scheduled = from.Scheduled
+	}
+}
+
+// union of two comment lists, oldest first
+func mergeComments(a, b []Comment) []Comment {
+	seen := map[Comment]bool{}
+	var out []Comment
+	for _, c := range append(append([]Comment{}, a...), b...) {
+		if !seen[c] {
+			seen[c] = true
+			out = append(out, c)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		ti, _ := ParseTime(out[i].CreatedAt)
+		tj, _ := ParseTime(out[j].CreatedAt)
+		return ti.Before(tj)
+	})
+	return out
+}
+
+func sameTask(a, b Task) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return string(ja) == string(jb)
+}
+
+func containsTask(tasks []Task, uuid string) bool {
+	for _, t := range tasks {
+		if t.UUID == uuid {
+			return true
+		}
+	}
+	return false
+}
+
+// times of the last sync with one peer
+type SyncState struct {
+	Local  string `json:"local"`  // local clock
+	Remote string `json:"remote"` // the peer's clock
+}
+
+// sync state lives next to the tasks file, tasks.json -> tasks.sync.json
+func (s *Store) syncStatePath() string {
+	return strings.TrimSuffix(s.path, filepath.Ext(s.path)) + ".sync.json"
+}
+
+func (s *Store) loadSyncStates() (map[string]SyncState, error) {
+	b, err := os.ReadFile(s.syncStatePath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]SyncState{}, nil
+		}
+		return nil, err
+	}
+	states := map[string]SyncState{}
+	if err := json.Unmarshal(b, &states); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// the state of the last sync with peer, zero before the first one
+func (s *Store) GetSyncState(peer string) (SyncState, error) {
+	states, err := s.loadSyncStates()
+	if err != nil {
+		return SyncState{}, err
+	}
+	return states[peer], nil
+}
+
+// remember a finished sync with peer
+func (s *Store) SetSyncState(peer string, st SyncState) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	states, err := s.loadSyncStates()
+	if err != nil {
+		return err
+	}
+	states[peer] = st
+	data, err := json.MarshalIndent(states, "", " ")
+	if err != nil {
+		return err
+	}
+	return writeFile(s.syncStatePath(), data)
+}
@@ -0,0 +1,174 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// a single card on the board
+type BoardCard struct {
+	Text string
+	Done bool
+}
+
+// a board column, Limit of 0 means no WIP limit
+type BoardColumn struct {
+	Title string
+	Cards []BoardCard
+	Limit int
+}
+
+const (
+	boardGap         = 2
+	boardMinColWidth = 18
+)
+
+// width of the terminal, from $COLUMNS or stdout, defaulting to 80
+func TerminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	if n, err := terminalWidth(int(os.Stdout.Fd())); err == nil && n > 0 {
+		return n
+	}
+	return 80
+}
+
+// print columns side by side, wrapping onto more rows when they don't fit
+func PrintBoard(columns []BoardColumn, width int) {
+	perRow := (width + boardGap) / (boardMinColWidth + boardGap)
+	if perRow < 1 {
+		perRow = 1
+	}
+	if perRow > len(columns) {
+		perRow = len(columns)
+	}
+	colWidth := (width - boardGap*(perRow-1)) / perRow
+
+	for start := 0; start < len(columns); start += perRow {
+		end := start + perRow
+		if end > len(columns) {
+			end = len(columns)
+		}
+		printBoardRow(columns[start:end], colWidth)
+		fmt.Println()
+	}
+
+	for _, c := range columns {
+		if c.Limit > 0 && len(c.Cards) > c.Limit {
+			color.New(color.FgRed).Printf("  ⚠ %s has %d tasks, WIP limit is %d\n", c.Title, len(c.Cards), c.Limit)
+		}
+	}
+}
+
+func printBoardRow(columns []BoardColumn, colWidth int) {
+	gap := strings.Repeat(" ", boardGap)
+
+	// headers with per-column counts
+	for i, c := range columns {
+		if i > 0 {
+			fmt.Print(gap)
+		}
+		header := fmt.Sprintf("%s (%d)", strings.ToUpper(c.Title), len(c.Cards))
+		style := color.New(color.FgBlue, color.Bold)
+		if c.Limit > 0 {
+			header = fmt.Sprintf("%s (%d/%d)", strings.ToUpper(c.Title), len(c.Cards), c.Limit)
+			if len(c.Cards) > c.Limit {
+				header += " ⚠"
+				style = color.New(color.FgRed, color.Bold)
+			}
+		}
+		style.Print(pad(Truncate(header, colWidth), colWidth))
+	}
+	fmt.Println()
+	for i := range columns {
+		if i > 0 {
+			fmt.Print(gap)
+		}
+		color.New(color.FgWhite, color.Faint).Print(strings.Repeat("─", colWidth))
+	}
+	fmt.Println()
+
+	rows := 0
+	for _, c := range columns {
+		if len(c.Cards) > rows {
+			rows = len(c.Cards)
+		}
+	}
+	for r := 0; r < rows; r++ {
+		for i, c := range columns {
+			if i > 0 {
+				fmt.Print(gap)
+			}
+			if r >= len(c.Cards) {
+				fmt.Print(strings.Repeat(" ", colWidth))
+				continue
+			}
+			card := c.Cards[r]
+			text := pad(Truncate(card.Text, colWidth), colWidth)
+			if card.Done {
+				color.New(color.FgGreen, color.Faint).Print(text)
+			} else {
+				color.New(color.FgWhite).Print(text)
+			}
+		}
+		fmt.Println()
+	}
+}
+
+// shorten s to at most width display columns, marking the cut with "…"
+func Truncate(s string, width int) string {
+	if DisplayWidth(s) <= width {
+		return s
+	}
+	var b strings.Builder
+	w := 0
+	for _, r := range s {
+		rw := runeWidth(r)
+		if w+rw > width-1 {
+			break
+		}
+		b.WriteRune(r)
+		w += rw
+	}
+	b.WriteString("…")
+	return b.String()
+}
+
+// number of terminal columns s occupies
+func DisplayWidth(s string) int {
+	w := 0
+	for _, r := range s {
+		w += runeWidth(r)
+	}
+	return w
+}
+
+func pad(s string, width int) string {
+	if n := width - DisplayWidth(s); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return s
+}
+
+// east asian wide characters and emoji take two columns
+func runeWidth(r rune) int {
+	switch {
+	case r >= 0x1100 && r <= 0x115F,
+		r >= 0x2E80 && r <= 0xA4CF,
+		r >= 0xAC00 && r <= 0xD7A3,
+		r >= 0xF900 && r <= 0xFAFF,
+		r >= 0xFE30 && r <= 0xFE4F,
+		r >= 0xFF00 && r <= 0xFF60,
+		r >= 0xFFE0 && r <= 0xFFE6,
+		r >= 0x1F300 && r <= 0x1F64F,
+		r >= 0x1F900 && r <= 0x1F9FF,
+		r >= 0x20000 && r <= 0x3FFFD:
+		return 2
+	}
+	return 1
+}
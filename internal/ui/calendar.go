@@ -0,0 +1,54 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+const calendarCellWidth = 7
+
+// print a Monday-first month grid, showing counts[day] next to each day
+// that has tasks and highlighting today
+func PrintCalendar(month time.Time, counts map[int]int, today time.Time) {
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	days := first.AddDate(0, 1, -1).Day()
+	// Monday = 0 ... Sunday = 6
+	offset := (int(first.Weekday()) + 6) % 7
+
+	title := first.Format("January 2006")
+	width := calendarCellWidth * 7
+	fmt.Print(strings.Repeat(" ", (width-len(title))/2))
+	color.New(color.FgBlue, color.Bold).Println(title)
+	for _, wd := range []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"} {
+		color.New(color.FgWhite, color.Faint).Printf("%-*s", calendarCellWidth, " "+wd)
+	}
+	fmt.Println()
+
+	fmt.Print(strings.Repeat(" ", calendarCellWidth*offset))
+	for day := 1; day <= days; day++ {
+		cell := fmt.Sprintf("%3d", day)
+		if n := counts[day]; n > 0 {
+			cell += fmt.Sprintf("(%d)", n)
+		}
+		cell = fmt.Sprintf("%-*s", calendarCellWidth, cell)
+
+		isToday := today.Year() == first.Year() && today.Month() == first.Month() && today.Day() == day
+		switch {
+		case isToday:
+			color.New(color.FgBlack, color.BgCyan).Print(cell)
+		case counts[day] > 0:
+			color.New(color.FgYellow).Print(cell)
+		default:
+			color.New(color.FgWhite).Print(cell)
+		}
+		if (offset+day)%7 == 0 {
+			fmt.Println()
+		}
+	}
+	if (offset+days)%7 != 0 {
+		fmt.Println()
+	}
+}
@@ -0,0 +1,230 @@
+package ui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInterrupted is returned by ReadLine when the user presses Ctrl-C
+var ErrInterrupted = errors.New("interrupted")
+
+// LineEditor reads lines from the terminal with history and tab completion.
+// When stdin is not a terminal it falls back to plain line reading.
+type LineEditor struct {
+	Prompt  string
+	History []string
+	// Complete returns the candidates for the last word of line
+	Complete func(line string) []string
+
+	in     *os.File
+	out    io.Writer
+	reader *bufio.Reader
+}
+
+func NewLineEditor(prompt string) *LineEditor {
+	return &LineEditor{
+		Prompt: prompt,
+		in:     os.Stdin,
+		out:    os.Stdout,
+		reader: bufio.NewReader(os.Stdin),
+	}
+}
+
+// AddHistory appends a line to the history, skipping blanks and repeats
+func (e *LineEditor) AddHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(e.History); n > 0 && e.History[n-1] == line {
+		return
+	}
+	e.History = append(e.History, line)
+}
+
+// ReadLine reads a single line, returning io.EOF on Ctrl-D or end of input
+func (e *LineEditor) ReadLine() (string, error) {
+	fd := int(e.in.Fd())
+	if !isTerminal(fd) {
+		return e.readPlain()
+	}
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return e.readPlain()
+	}
+	defer restore()
+	return e.readRaw()
+}
+
+func (e *LineEditor) readPlain() (string, error) {
+	fmt.Fprint(e.out, e.Prompt)
+	line, err := e.reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (e *LineEditor) readRaw() (string, error) {
+	var buf []rune
+	cursor := 0
+	histIdx := len(e.History)
+	pending := ""
+
+	e.redraw(buf, cursor)
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(buf), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", ErrInterrupted
+		case 4: // Ctrl-D
+			if len(buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if cursor < len(buf) {
+				buf = append(buf[:cursor], buf[cursor+1:]...)
+			}
+		case 1: // Ctrl-A
+			cursor = 0
+		case 5: // Ctrl-E
+			cursor = len(buf)
+		case 21: // Ctrl-U
+			buf = buf[cursor:]
+			cursor = 0
+		case 127, 8: // Backspace
+			if cursor > 0 {
+				buf = append(buf[:cursor-1], buf[cursor:]...)
+				cursor--
+			}
+		case '\t':
+			buf, cursor = e.complete(buf, cursor)
+		case 27: // escape sequence
+			seq, err := e.readEscape()
+			if err != nil {
+				return "", err
+			}
+			switch seq {
+			case "[A": // up
+				if histIdx > 0 {
+					if histIdx == len(e.History) {
+						pending = string(buf)
+					}
+					histIdx--
+					buf = []rune(e.History[histIdx])
+					cursor = len(buf)
+				}
+			case "[B": // down
+				if histIdx < len(e.History) {
+					histIdx++
+					if histIdx == len(e.History) {
+						buf = []rune(pending)
+					} else {
+						buf = []rune(e.History[histIdx])
+					}
+					cursor = len(buf)
+				}
+			case "[C": // right
+				if cursor < len(buf) {
+					cursor++
+				}
+			case "[D": // left
+				if cursor > 0 {
+					cursor--
+				}
+			case "[H":
+				cursor = 0
+			case "[F":
+				cursor = len(buf)
+			case "[3~": // delete
+				if cursor < len(buf) {
+					buf = append(buf[:cursor], buf[cursor+1:]...)
+				}
+			}
+		default:
+			if r < 32 {
+				continue
+			}
+			buf = append(buf[:cursor], append([]rune{r}, buf[cursor:]...)...)
+			cursor++
+		}
+		e.redraw(buf, cursor)
+	}
+}
+
+// read the rest of an ANSI escape sequence after ESC
+func (e *LineEditor) readEscape() (string, error) {
+	var seq []rune
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		seq = append(seq, r)
+		// sequences end with a letter or '~'
+		if len(seq) > 1 && (r == '~' || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z')) {
+			return string(seq), nil
+		}
+		if len(seq) == 1 && r != '[' && r != 'O' {
+			return string(seq), nil
+		}
+	}
+}
+
+func (e *LineEditor) complete(buf []rune, cursor int) ([]rune, int) {
+	if e.Complete == nil {
+		return buf, cursor
+	}
+	line := string(buf[:cursor])
+	start := strings.LastIndex(line, " ") + 1
+	word := line[start:]
+
+	candidates := e.Complete(line)
+	if len(candidates) == 0 {
+		return buf, cursor
+	}
+
+	replacement := commonPrefix(candidates)
+	if len(candidates) == 1 {
+		replacement += " "
+	} else if len(replacement) <= len(word) {
+		// nothing more to fill in, show the options instead
+		fmt.Fprint(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+		return buf, cursor
+	}
+
+	head := []rune(line[:start] + replacement)
+	tail := buf[cursor:]
+	return append(head, tail...), len(head)
+}
+
+func (e *LineEditor) redraw(buf []rune, cursor int) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", e.Prompt, string(buf))
+	if back := len(buf) - cursor; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		// whole runes, a byte cut would leave half a character to insert
+		for !strings.HasPrefix(w, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// shared so that several passwords piped in on stdin are read in turn
+var passwordReader = bufio.NewReader(os.Stdin)
+
+// ReadPassword prompts for a password without echoing it. When stdin is
+// not a terminal it reads a plain line, so passwords can be piped in.
+func ReadPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stdout, prompt)
+	fd := int(os.Stdin.Fd())
+	if isTerminal(fd) {
+		if restore, err := makeRaw(fd); err == nil {
+			defer restore()
+			return readHidden()
+		}
+	}
+	line, err := passwordReader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func readHidden() (string, error) {
+	var buf []rune
+	for {
+		r, _, err := passwordReader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(os.Stdout, "\r\n")
+			return string(buf), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(os.Stdout, "^C\r\n")
+			return "", ErrInterrupted
+		case 4: // Ctrl-D
+			if len(buf) == 0 {
+				fmt.Fprint(os.Stdout, "\r\n")
+				return "", io.EOF
+			}
+		case 21: // Ctrl-U
+			buf = buf[:0]
+		case 127, 8: // Backspace
+			if len(buf) > 0 {
+				buf = buf[:len(buf)-1]
+			}
+		default:
+			if r >= 32 {
+				buf = append(buf, r)
+			}
+		}
+	}
+}
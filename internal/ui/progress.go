@@ -8,13 +8,17 @@ import (
 )
 
 func PrintProgressBar(progress float64) {
-	width := 40
-	filled := int(math.Round(float64(width) * progress / 100))
-
 	// Animated progress bar
 	fmt.Print("  ")
 	// color.New(color.FgBlue).Print("[")
+	PrintBar(progress, 40)
+	// color.New(color.FgBlue).Print("]")
+	color.New(color.FgWhite).Printf(" %5.1f%%\n", progress)
+}
 
+// print just the cells of a progress bar, for lines with more on them
+func PrintBar(progress float64, width int) {
+	filled := int(math.Round(float64(width) * progress / 100))
 	for i := 0; i < width; i++ {
 		if i < filled {
 			color.New(color.FgGreen).Print("█")
@@ -22,9 +26,6 @@ func PrintProgressBar(progress float64) {
 			color.New(color.FgWhite, color.Faint).Print("░")
 		}
 	}
-
-	// color.New(color.FgBlue).Print("]")
-	color.New(color.FgWhite).Printf(" %5.1f%%\n", progress)
 }
 
 func PrintProgressSummary(done, total int, progress float64) {
@@ -0,0 +1,25 @@
+package ui
+
+import "strings"
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// render values as a one-line bar chart scaled to the largest value
+func Sparkline(values []int) string {
+	max := 0
+	for _, v := range values {
+		if v > max {
+			max = v
+		}
+	}
+	var b strings.Builder
+	for _, v := range values {
+		if max == 0 || v <= 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		idx := v * (len(sparkBlocks) - 1) / max
+		b.WriteRune(sparkBlocks[idx])
+	}
+	return b.String()
+}
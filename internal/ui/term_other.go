@@ -0,0 +1,17 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+
+package ui
+
+import "errors"
+
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func terminalWidth(fd int) (int, error) {
+	return 0, errors.New("terminal size is not supported on this platform")
+}
@@ -0,0 +1,302 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+// sends the webhooks of a Store for every change it is told about. Each
+// delivery runs on its own goroutine and is retried with exponential
+// backoff while the receiver is down, answers 408, 429 or 5xx. With a
+// Queue, deliveries still failing then are kept for Drain to send again.
+type Dispatcher struct {
+	store  *Store
+	client *http.Client
+
+	// tries per delivery, and the wait before the first retry, doubled
+	// for every further one up to MaxBackoff
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	// keeps unfinished deliveries for later processes, nil for none
+	Queue *Queue
+	// how long a queued delivery waits before Drain sends it again, and
+	// how long it is kept before it is given up
+	RetryLater time.Duration
+	KeepFor    time.Duration
+	// reports failed deliveries, to stderr when nil
+	Logf func(format string, args ...any)
+
+	wg sync.WaitGroup
+}
+
+func NewDispatcher(store *Store) *Dispatcher {
+	return &Dispatcher{
+		store:       store,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 4,
+		Backoff:     500 * time.Millisecond,
+		MaxBackoff:  30 * time.Second,
+		RetryLater:  time.Minute,
+		KeepFor:     24 * time.Hour,
+	}
+}
+
+func (d *Dispatcher) logf(format string, args ...any) {
+	if d.Logf != nil {
+		d.Logf(format, args...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+}
+
+// send the webhooks that want a change, used as the storage observer.
+// It does not wait for the deliveries.
+func (d *Dispatcher) Notify(e storage.Event) {
+	subs, err := d.store.List()
+	if err != nil {
+		d.logf("failed to load webhooks: %v", err)
+		return
+	}
+	event := EventOf(e.Action)
+	var p *Payload
+	var wanted []Subscription
+	for _, sub := range subs {
+		if !sub.Wants(event, e.Task) {
+			continue
+		}
+		if p == nil {
+			at := time.Now()
+			if t, err := storage.ParseTime(e.Time); err == nil {
+				at = t
+			}
+			p = &Payload{ID: newEventID(), Event: event, Time: at.Format(time.RFC3339), Task: e.Task}
+		}
+		wanted = append(wanted, sub)
+	}
+	if len(wanted) == 0 {
+		return
+	}
+	// queued before sending, the process may exit before the receivers answer
+	if d.Queue != nil {
+		now := time.Now()
+		err := d.Queue.update(func(items []pending) []pending {
+			for _, sub := range wanted {
+				items = append(items, pending{Webhook: sub.Name, Payload: *p, QueuedAt: now, NextAt: now.Add(d.RetryLater)})
+			}
+			return items
+		})
+		if err != nil {
+			d.logf("failed to queue webhooks: %v", err)
+		}
+	}
+	for _, sub := range wanted {
+		d.wg.Add(1)
+		go d.run(sub, *p)
+	}
+}
+
+// send the deliveries queued by earlier processes that are due, without
+// waiting for them
+func (d *Dispatcher) Drain() error {
+	if d.Queue == nil {
+		return nil
+	}
+	now := time.Now()
+	var due, expired []pending
+	err := d.Queue.update(func(items []pending) []pending {
+		var kept []pending
+		for _, it := range items {
+			if !it.NextAt.After(now) {
+				if now.Sub(it.QueuedAt) > d.KeepFor {
+					expired = append(expired, it)
+					continue
+				}
+				// put off so other processes leave it to this one
+				it.NextAt = now.Add(d.RetryLater)
+				due = append(due, it)
+			}
+			kept = append(kept, it)
+		}
+		return kept
+	})
+	if err != nil {
+		return err
+	}
+	for _, it := range expired {
+		d.logf("webhook %s: giving up on %s of task %d queued at %s", it.Webhook, it.Payload.Event, it.Payload.Task.ID, it.QueuedAt.Format(time.RFC3339))
+	}
+	if len(due) == 0 {
+		return nil
+	}
+	subs, err := d.store.List()
+	if err != nil {
+		return err
+	}
+	for _, it := range due {
+		i := slices.IndexFunc(subs, func(s Subscription) bool { return s.Name == it.Webhook })
+		if i < 0 {
+			// the webhook was removed since
+			d.dequeue(it)
+			continue
+		}
+		d.wg.Add(1)
+		go d.run(subs[i], it.Payload)
+	}
+	return nil
+}
+
+// deliver a payload and take it off the queue, or leave it there for a
+// later Drain while the receiver cannot take it
+func (d *Dispatcher) run(sub Subscription, p Payload) {
+	defer d.wg.Done()
+	retry, err := d.deliver(sub, p)
+	it := pending{Webhook: sub.Name, Payload: p}
+	if err == nil || !retry || d.Queue == nil {
+		if d.Queue != nil {
+			d.dequeue(it)
+		}
+		if err != nil {
+			d.logf("webhook %s: giving up on %s of task %d: %v", sub.Name, p.Event, p.Task.ID, err)
+		}
+		return
+	}
+	expired := false
+	qerr := d.Queue.update(func(items []pending) []pending {
+		i := slices.IndexFunc(items, it.is)
+		if i < 0 {
+			return items
+		}
+		if time.Since(items[i].QueuedAt) > d.KeepFor {
+			expired = true
+			return slices.Delete(items, i, i+1)
+		}
+		items[i].NextAt = time.Now().Add(d.RetryLater)
+		return items
+	})
+	switch {
+	case qerr != nil:
+		d.logf("webhook %s: giving up on %s of task %d: %v, and failed to queue it: %v", sub.Name, p.Event, p.Task.ID, err, qerr)
+	case expired:
+		d.logf("webhook %s: giving up on %s of task %d: %v", sub.Name, p.Event, p.Task.ID, err)
+	default:
+		d.logf("webhook %s: %s of task %d queued for later: %v", sub.Name, p.Event, p.Task.ID, err)
+	}
+}
+
+func (d *Dispatcher) dequeue(it pending) {
+	err := d.Queue.update(func(items []pending) []pending {
+		return slices.DeleteFunc(items, it.is)
+	})
+	if err != nil {
+		d.logf("failed to update the webhook queue: %v", err)
+	}
+}
+
+// wait up to timeout for deliveries in progress, report whether all ended
+func (d *Dispatcher) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// send a payload, retrying while the receiver cannot take it
+func (d *Dispatcher) Deliver(sub Subscription, p Payload) error {
+	_, err := d.deliver(sub, p)
+	return err
+}
+
+// Deliver, also reporting whether a failure is worth another try later
+func (d *Dispatcher) deliver(sub Subscription, p Payload) (bool, error) {
+	delay := d.Backoff
+	for attempt := 1; ; attempt++ {
+		retry, retryAfter, err := d.send(sub, p)
+		if err == nil || !retry || attempt >= d.MaxAttempts {
+			return retry, err
+		}
+		wait := max(delay, retryAfter)
+		time.Sleep(min(wait, d.MaxBackoff))
+		delay *= 2
+	}
+}
+
+// a failed delivery
+type StatusError struct {
+	Status int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("receiver answered %d %s", e.Status, http.StatusText(e.Status))
+}
+
+// make one attempt, reporting whether a failure is worth retrying and how
+// long the receiver asked to wait
+func (d *Dispatcher) send(sub Subscription, p Payload) (bool, time.Duration, error) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return false, 0, err
+	}
+	req, err := http.NewRequest("POST", sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gotodo-webhook")
+	req.Header.Set("X-Gotodo-Event", p.Event)
+	req.Header.Set("X-Gotodo-Delivery", p.ID)
+	req.Header.Set("X-Gotodo-Signature", Sign(sub.Secret, body))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, 0, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	resp.Body.Close()
+	if resp.StatusCode < 300 {
+		return false, 0, nil
+	}
+	retry := resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode >= 500
+	var after time.Duration
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		after = time.Duration(secs) * time.Second
+	}
+	return retry, after, &StatusError{Status: resp.StatusCode}
+}
+
+// a test payload for 'gotodo webhook test'
+func Ping() Payload {
+	return Payload{
+		ID:    newEventID(),
+		Event: EventPing,
+		Time:  time.Now().Format(time.RFC3339),
+		Task:  storage.Task{ID: 0, Content: "Webhook test from gotodo"},
+	}
+}
+
+func newEventID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate event ID: %v", err))
+	}
+	return "evt_" + hex.EncodeToString(b)
+}
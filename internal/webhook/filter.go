@@ -0,0 +1,277 @@
+package webhook
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+// fields a filter expression can test
+var FilterFields = []string{"content", "tag", "project", "priority", "due", "done"}
+
+// a filter expression choosing the tasks a webhook fires for, e.g.
+//
+//	tag=release and done=true and (priority=high or not project~web)
+//
+// Comparisons are field=value, field!=value, field~text (contains, any
+// case) and, for due dates, field<value and field>value. tag matches when
+// any of the task's tags does, "" is the empty value, and tag="" the tasks
+// without tags. The empty expression matches every task.
+type Filter struct {
+	src  string
+	expr node
+}
+
+// a parsed expression
+type node interface {
+	match(t storage.Task) bool
+}
+
+type andNode struct{ left, right node }
+type orNode struct{ left, right node }
+type notNode struct{ inner node }
+
+type comparison struct {
+	field, op, value string
+}
+
+func (n andNode) match(t storage.Task) bool { return n.left.match(t) && n.right.match(t) }
+func (n orNode) match(t storage.Task) bool  { return n.left.match(t) || n.right.match(t) }
+func (n notNode) match(t storage.Task) bool { return !n.inner.match(t) }
+
+func (c comparison) match(t storage.Task) bool {
+	var values []string
+	switch c.field {
+	case "content":
+		values = []string{t.Content}
+	case "tag":
+		values = t.Tags
+		// tag="" chooses the tasks without tags
+		if len(values) == 0 && (c.op == "=" || c.op == "!=") {
+			values = []string{""}
+		}
+	case "project":
+		values = []string{t.Project}
+	case "priority":
+		values = []string{t.Priority}
+	case "due":
+		values = []string{t.Due}
+	case "done":
+		values = []string{fmt.Sprint(t.Done)}
+	}
+	if c.op == "!=" {
+		return !slices.Contains(values, c.value)
+	}
+	for _, v := range values {
+		switch c.op {
+		case "=":
+			if v == c.value {
+				return true
+			}
+		case "~":
+			if strings.Contains(strings.ToLower(v), strings.ToLower(c.value)) {
+				return true
+			}
+		// dates in DateLayout compare as strings, tasks without one never match
+		case "<":
+			if v != "" && v < c.value {
+				return true
+			}
+		case ">":
+			if v != "" && v > c.value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parse a filter expression
+func ParseFilter(s string) (Filter, error) {
+	f := Filter{src: strings.TrimSpace(s)}
+	if f.src == "" {
+		return f, nil
+	}
+	tokens, err := tokenize(f.src)
+	if err != nil {
+		return Filter{}, err
+	}
+	p := &parser{tokens: tokens}
+	if f.expr, err = p.or(); err != nil {
+		return Filter{}, err
+	}
+	if !p.done() {
+		return Filter{}, fmt.Errorf("unexpected %q in filter", p.peek())
+	}
+	return f, nil
+}
+
+// report whether the task passes the filter
+func (f Filter) Match(t storage.Task) bool {
+	return f.expr == nil || f.expr.match(t)
+}
+
+func (f Filter) String() string {
+	return f.src
+}
+
+// split an expression into words, quoted strings, operators and brackets
+func tokenize(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')' || c == '=' || c == '~' || c == '<' || c == '>':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '!' && i+1 < len(s) && s[i+1] == '=':
+			tokens = append(tokens, "!=")
+			i += 2
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote in filter")
+			}
+			// keep the quote so a quoted "and" stays a value
+			tokens = append(tokens, s[i:i+end+2])
+			i += end + 2
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t()=~<>!\"", rune(s[i])) {
+				i++
+			}
+			if i == start {
+				return nil, fmt.Errorf("unexpected %q in filter", s[i:i+1])
+			}
+			tokens = append(tokens, s[start:i])
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// report whether the next token is the keyword kw, and skip it if so
+func (p *parser) keyword(kw string) bool {
+	if strings.EqualFold(p.peek(), kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) not() (node, error) {
+	if p.keyword("not") {
+		inner, err := p.not()
+		return notNode{inner}, err
+	}
+	if p.peek() == "(" {
+		p.next()
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing ) in filter")
+		}
+		return inner, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (node, error) {
+	field := strings.ToLower(p.next())
+	if !slices.Contains(FilterFields, field) {
+		if field == "" {
+			return nil, fmt.Errorf("filter ends too early")
+		}
+		return nil, fmt.Errorf("unknown filter field %q (want one of %s)", field, strings.Join(FilterFields, ", "))
+	}
+	op := p.next()
+	if !slices.Contains([]string{"=", "!=", "~", "<", ">"}, op) {
+		return nil, fmt.Errorf("expected =, !=, ~, < or > after %s in filter", field)
+	}
+	value := p.next()
+	if value == "" || value == "(" || value == ")" {
+		return nil, fmt.Errorf("missing value after %s%s in filter", field, op)
+	}
+	if strings.HasPrefix(value, `"`) {
+		value = value[1 : len(value)-1]
+	}
+	c := comparison{field: field, op: op, value: value}
+	return c, c.validate()
+}
+
+// check that the value and operator make sense for the field
+func (c comparison) validate() error {
+	if (c.op == "<" || c.op == ">") && c.field != "due" {
+		return fmt.Errorf("%s only works with due dates in filter", c.op)
+	}
+	switch c.field {
+	case "priority":
+		return storage.ValidatePriority(c.value)
+	case "due":
+		if _, ok := (storage.Task{Due: c.value}).DueDate(); !ok && c.value != "" && c.op != "~" {
+			return fmt.Errorf("invalid due date %q in filter (want YYYY-MM-DD)", c.value)
+		}
+	case "done":
+		if c.value != "true" && c.value != "false" || c.op == "~" {
+			return fmt.Errorf("done compares with = or != to true or false in filter")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// a delivery that has not succeeded yet
+type pending struct {
+	Webhook  string    `json:"webhook"`
+	Payload  Payload   `json:"payload"`
+	QueuedAt time.Time `json:"queued_at"`
+	// not tried before this, it is also put off while a process is sending
+	// it so that others leave it alone
+	NextAt time.Time `json:"next_at"`
+}
+
+func (p pending) is(o pending) bool {
+	return p.Webhook == o.Webhook && p.Payload.ID == o.Payload.ID
+}
+
+// deliveries not finished yet, kept in a JSON file so that later commands
+// send the ones a command could not before it exited
+type Queue struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewQueue(path string) *Queue {
+	return &Queue{path: path}
+}
+
+func (q *Queue) load() ([]pending, error) {
+	data, err := os.ReadFile(q.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []pending{}, nil
+		}
+		return nil, err
+	}
+	var items []pending
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", q.path, err)
+	}
+	return items, nil
+}
+
+// change the queued deliveries, f gets them oldest first
+func (q *Queue) update(f func([]pending) []pending) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items, err := q.load()
+	if err != nil {
+		return err
+	}
+	before := len(items)
+	items = f(items)
+	if before == 0 && len(items) == 0 {
+		// nothing to keep, e.g. every command draining an empty queue
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(q.path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(items, "", " ")
+	if err != nil {
+		return err
+	}
+	// written aside and renamed so that a command exiting halfway through
+	// does not lose the queue, tasks can be private
+	tmp := q.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.path)
+}
+
+// number of deliveries waiting
+func (q *Queue) Len() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items, err := q.load()
+	return len(items), err
+}
@@ -0,0 +1,212 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+// event types a webhook can subscribe to
+const (
+	EventAdded     = "task.added"
+	EventDone      = "task.done"
+	EventUndone    = "task.undone"
+	EventUpdated   = "task.updated"
+	EventDeleted   = "task.deleted"
+	EventCommented = "task.commented"
+	// sent by 'gotodo webhook test' only
+	EventPing = "ping"
+)
+
+var Events = []string{EventAdded, EventDone, EventUndone, EventUpdated, EventDeleted, EventCommented}
+
+// the event type of a change log action
+func EventOf(action string) string {
+	switch action {
+	case storage.ActionAdd:
+		return EventAdded
+	case storage.ActionDone:
+		return EventDone
+	case storage.ActionUndone:
+		return EventUndone
+	case storage.ActionDelete:
+		return EventDeleted
+	case storage.ActionComment:
+		return EventCommented
+	}
+	return EventUpdated
+}
+
+// one webhook
+type Subscription struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// event types sent, all of Events when empty
+	Events []string `json:"events,omitempty"`
+	// filter expression choosing the tasks, see Filter
+	Filter string `json:"filter,omitempty"`
+	// key of the X-Gotodo-Signature HMAC
+	Secret string `json:"secret"`
+}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// check the name, URL, events and filter
+func (s Subscription) Validate() error {
+	if !namePattern.MatchString(s.Name) {
+		return fmt.Errorf("invalid webhook name %q (letters, digits, '.', '_' and '-')", s.Name)
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q (want http:// or https://)", s.URL)
+	}
+	for _, e := range s.Events {
+		if !slices.Contains(Events, e) {
+			return fmt.Errorf("unknown event %q (want one of %s)", e, strings.Join(Events, ", "))
+		}
+	}
+	if s.Secret == "" {
+		return fmt.Errorf("webhook %s has no secret", s.Name)
+	}
+	_, err = ParseFilter(s.Filter)
+	return err
+}
+
+// report whether the subscription wants the event
+func (s Subscription) Wants(event string, t storage.Task) bool {
+	if len(s.Events) > 0 && !slices.Contains(s.Events, event) {
+		return false
+	}
+	f, err := ParseFilter(s.Filter)
+	return err == nil && f.Match(t)
+}
+
+// a random secret for a new webhook
+func NewSecret() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("failed to generate webhook secret: %v", err))
+	}
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+// value of the X-Gotodo-Signature header: the hex HMAC-SHA256 of the body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// report whether signature is the one Sign gives, for receivers written in Go
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// JSON body of a webhook request
+type Payload struct {
+	// unique per event, the same on every retry
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	// RFC 3339 time of the change
+	Time string       `json:"time"`
+	Task storage.Task `json:"task"`
+}
+
+// webhooks kept in a JSON file, read on every call like the ShareStore so
+// changes apply to a running server
+type Store struct {
+	path string
+}
+
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+func (s *Store) load() ([]Subscription, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Subscription{}, nil
+		}
+		return nil, err
+	}
+	var subs []Subscription
+	if err := json.Unmarshal(data, &subs); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", s.path, err)
+	}
+	return subs, nil
+}
+
+func (s *Store) save(subs []Subscription) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(subs, "", " ")
+	if err != nil {
+		return err
+	}
+	// the secrets are in here
+	return os.WriteFile(s.path, data, 0600)
+}
+
+// every webhook, sorted by name
+func (s *Store) List() ([]Subscription, error) {
+	subs, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].Name < subs[j].Name })
+	return subs, nil
+}
+
+func (s *Store) Get(name string) (Subscription, bool, error) {
+	subs, err := s.load()
+	if err != nil {
+		return Subscription{}, false, err
+	}
+	for _, sub := range subs {
+		if sub.Name == name {
+			return sub, true, nil
+		}
+	}
+	return Subscription{}, false, nil
+}
+
+// add a webhook or replace the one with the same name
+func (s *Store) Put(sub Subscription) error {
+	if err := sub.Validate(); err != nil {
+		return err
+	}
+	subs, err := s.load()
+	if err != nil {
+		return err
+	}
+	subs = slices.DeleteFunc(subs, func(o Subscription) bool { return o.Name == sub.Name })
+	return s.save(append(subs, sub))
+}
+
+func (s *Store) Remove(name string) error {
+	subs, err := s.load()
+	if err != nil {
+		return err
+	}
+	n := len(subs)
+	subs = slices.DeleteFunc(subs, func(o Subscription) bool { return o.Name == name })
+	if len(subs) == n {
+		return fmt.Errorf("no webhook named %q", name)
+	}
+	return s.save(subs)
+}
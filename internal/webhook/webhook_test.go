@@ -0,0 +1,222 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethanbao27/gotodo/internal/storage"
+)
+
+func TestFilter(t *testing.T) {
+	release := storage.Task{Content: "Tag v2.0", Tags: []string{"release", "ops"}, Priority: "high", Project: "website", Due: "2026-11-01", Done: true}
+	chore := storage.Task{Content: "Water plants", Project: "home"}
+
+	// Test expressions against two tasks
+	for _, tc := range []struct {
+		expr           string
+		release, chore bool
+	}{
+		{"", true, true},
+		{"tag=release", true, false},
+		{"tag!=release", false, true},
+		{"content~TAG", true, false},
+		{"done=true and priority=high", true, false},
+		{"project=home or tag=ops", true, true},
+		{"not (project=home or tag=ops)", false, false},
+		{"due<2026-12-01", true, false},
+		{"due>2026-12-01", false, false},
+		{`project="" or content~"water plants"`, false, true},
+		{"tag=release and done=true or project=home", true, true},
+		{`tag=""`, false, true},
+		{`tag!=""`, true, false},
+	} {
+		f, err := ParseFilter(tc.expr)
+		if err != nil {
+			t.Errorf("Failed to parse %q: %v", tc.expr, err)
+			continue
+		}
+		if got := f.Match(release); got != tc.release {
+			t.Errorf("Expected %q on the release task to be %v", tc.expr, tc.release)
+		}
+		if got := f.Match(chore); got != tc.chore {
+			t.Errorf("Expected %q on the chore to be %v", tc.expr, tc.chore)
+		}
+	}
+
+	// Test invalid expressions are refused
+	for _, expr := range []string{"tag", "colour=red", "tag=release and", "(tag=a", "priority=urgent", "done=maybe", "tag<b", `content="open`} {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("Expected error for %q", expr)
+		}
+	}
+}
+
+// a receiver that fails the first few requests
+type stub struct {
+	mu       sync.Mutex
+	failures int
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (s *stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, body)
+	if len(s.requests) <= s.failures {
+		w.WriteHeader(s.status)
+	}
+}
+
+func (s *stub) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+func TestDispatcher(t *testing.T) {
+	receiver := &stub{}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+	store := NewStore(filepath.Join(t.TempDir(), "webhooks.json"))
+	d := NewDispatcher(store)
+	d.Backoff = time.Millisecond
+	var failures []string
+	d.Logf = func(format string, args ...any) { failures = append(failures, format) }
+	sub := Subscription{Name: "chat", URL: srv.URL, Events: []string{EventDone}, Filter: "tag=release", Secret: "s3cret"}
+
+	// Test a signed payload arrives after the receiver recovers
+	t.Run("Retry", func(t *testing.T) {
+		receiver.failures, receiver.status = 2, http.StatusServiceUnavailable
+		if err := d.Deliver(sub, Ping()); err != nil {
+			t.Fatalf("Expected delivery after retries, got %v", err)
+		}
+		if receiver.count() != 3 {
+			t.Fatalf("Expected 3 attempts, got %d", receiver.count())
+		}
+		last := receiver.requests[2]
+		if !Verify("s3cret", receiver.bodies[2], last.Header.Get("X-Gotodo-Signature")) {
+			t.Error("Expected a valid signature")
+		}
+		if last.Header.Get("X-Gotodo-Delivery") != receiver.requests[0].Header.Get("X-Gotodo-Delivery") {
+			t.Error("Expected every retry to carry the same delivery ID")
+		}
+	})
+
+	// Test client errors are not retried and attempts are limited
+	t.Run("GiveUp", func(t *testing.T) {
+		receiver.requests, receiver.bodies = nil, nil
+		receiver.failures, receiver.status = 10, http.StatusBadRequest
+		var serr *StatusError
+		if err := d.Deliver(sub, Ping()); !errors.As(err, &serr) || serr.Status != http.StatusBadRequest || receiver.count() != 1 {
+			t.Errorf("Expected one attempt failing with 400, got %v after %d", err, receiver.count())
+		}
+		receiver.requests, receiver.bodies = nil, nil
+		receiver.status = http.StatusInternalServerError
+		if err := d.Deliver(sub, Ping()); err == nil || receiver.count() != d.MaxAttempts {
+			t.Errorf("Expected %d attempts, got %d (%v)", d.MaxAttempts, receiver.count(), err)
+		}
+	})
+
+	// Test changes of the list reach the webhooks that want them
+	t.Run("Storage", func(t *testing.T) {
+		receiver.requests, receiver.bodies = nil, nil
+		receiver.failures = 0
+		if err := store.Put(sub); err != nil {
+			t.Fatalf("Failed to save webhook: %v", err)
+		}
+		storage.SetPath(filepath.Join(t.TempDir(), "tasks.json"))
+		storage.SetObserver(d.Notify)
+		defer storage.SetObserver(nil)
+
+		storage.AddTask(storage.Task{Content: "Tag v2.0", Tags: []string{"release"}})
+		storage.AddTask(storage.Task{Content: "Water plants"})
+		storage.SetDone(1, true)
+		storage.SetDone(2, true)
+		if !d.Wait(5 * time.Second) {
+			t.Fatal("Expected deliveries to finish")
+		}
+		if receiver.count() != 1 {
+			t.Fatalf("Expected only the release task being done to be sent, got %d requests", receiver.count())
+		}
+		var p Payload
+		if err := json.Unmarshal(receiver.bodies[0], &p); err != nil {
+			t.Fatalf("Failed to decode payload: %v", err)
+		}
+		if p.Event != EventDone || p.Task.Content != "Tag v2.0" || !p.Task.Done || p.ID == "" {
+			t.Errorf("Expected task.done of the release task, got %+v", p)
+		}
+		if len(failures) != 0 {
+			t.Errorf("Expected no failed deliveries, got %v", failures)
+		}
+	})
+
+	// Test deliveries that keep failing are queued for a later process
+	t.Run("Queue", func(t *testing.T) {
+		receiver.requests, receiver.bodies = nil, nil
+		receiver.failures, receiver.status = 1, http.StatusServiceUnavailable
+		queue := NewQueue(filepath.Join(t.TempDir(), "webhooks.queue.json"))
+		first := NewDispatcher(store)
+		first.MaxAttempts, first.RetryLater, first.Queue = 1, 0, queue
+		first.Logf = func(format string, args ...any) {}
+		first.Notify(storage.Event{Action: storage.ActionDone, Task: storage.Task{ID: 1, Content: "Tag v2.0", Tags: []string{"release"}, Done: true}})
+		first.Wait(5 * time.Second)
+		if n, err := queue.Len(); n != 1 || err != nil {
+			t.Fatalf("Expected the failed delivery queued, got %d (%v)", n, err)
+		}
+		later := NewDispatcher(store)
+		later.Queue = queue
+		if err := later.Drain(); err != nil {
+			t.Fatalf("Failed to drain the queue: %v", err)
+		}
+		later.Wait(5 * time.Second)
+		if n, _ := queue.Len(); n != 0 || receiver.count() != 2 {
+			t.Fatalf("Expected the queue sent, got %d left after %d requests", n, receiver.count())
+		}
+		if receiver.requests[0].Header.Get("X-Gotodo-Delivery") != receiver.requests[1].Header.Get("X-Gotodo-Delivery") {
+			t.Error("Expected the queued delivery to keep its ID")
+		}
+
+		// Test deliveries are given up after KeepFor
+		receiver.failures = 1
+		first.KeepFor = 0
+		first.Notify(storage.Event{Action: storage.ActionDone, Task: storage.Task{ID: 1, Content: "Tag v2.0", Tags: []string{"release"}, Done: true}})
+		first.Wait(5 * time.Second)
+		if n, _ := queue.Len(); n != 0 {
+			t.Errorf("Expected an expired delivery dropped, got %d queued", n)
+		}
+	})
+}
+
+func TestStore(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "webhooks.json"))
+	sub := Subscription{Name: "chat", URL: "https://chat.example.com/hook", Secret: NewSecret()}
+	if err := store.Put(sub); err != nil {
+		t.Fatalf("Failed to save webhook: %v", err)
+	}
+	for _, bad := range []Subscription{
+		{Name: "x", URL: "chat.example.com", Secret: "s"},
+		{Name: "x", URL: "https://chat.example.com", Events: []string{"task.exploded"}, Secret: "s"},
+		{Name: "x", URL: "https://chat.example.com", Filter: "tag=", Secret: "s"},
+	} {
+		if err := store.Put(bad); err == nil {
+			t.Errorf("Expected error saving %+v", bad)
+		}
+	}
+	if subs, _ := store.List(); len(subs) != 1 {
+		t.Errorf("Expected one webhook, got %+v", subs)
+	}
+	if err := store.Remove("chat"); err != nil {
+		t.Errorf("Failed to remove webhook: %v", err)
+	}
+}